@@ -0,0 +1,147 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"sync"
+	"time"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+)
+
+// access(2) 的mask
+const (
+	accessRead    = 4 // R_OK
+	accessWrite   = 2 // W_OK
+	accessExecute = 1 // X_OK
+)
+
+// 缓存的数量超过时清理过期的结果
+const maxAccessEntries = 4096
+
+// AccessCache 短时间缓存CHECKACCESS的结果，test -w 和shell会对同一个文件反复检查。
+// 修改权限、重命名和删除时清空
+type AccessCache struct {
+	timeout time.Duration
+
+	lock    sync.Mutex
+	entries map[string]accessEntry
+}
+
+type accessEntry struct {
+	result int32
+	expire time.Time
+}
+
+// Init 初始化，timeout 为缓存的秒数，为0时不缓存
+func (cache *AccessCache) Init(timeout int) {
+	cache.timeout = time.Duration(timeout) * time.Second
+	cache.entries = make(map[string]accessEntry)
+}
+
+func accessKey(uid uint32, path string, mask uint32) string {
+	return fmt.Sprintf("%d:%d:%s", uid, mask, path)
+}
+
+// Get 获取没有过期的结果
+func (cache *AccessCache) Get(uid uint32, path string, mask uint32) (int32, bool) {
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	entry, ok := cache.entries[accessKey(uid, path, mask)]
+	if !ok || time.Now().After(entry.expire) {
+		return 0, false
+	}
+
+	return entry.result, true
+}
+
+// Set 缓存结果
+func (cache *AccessCache) Set(uid uint32, path string, mask uint32, result int32) {
+
+	if cache.timeout <= 0 {
+		return
+	}
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	now := time.Now()
+	if len(cache.entries) >= maxAccessEntries {
+		for key, entry := range cache.entries {
+			if now.After(entry.expire) {
+				delete(cache.entries, key)
+			}
+		}
+	}
+
+	cache.entries[accessKey(uid, path, mask)] = accessEntry{result: result, expire: now.Add(cache.timeout)}
+}
+
+// Clear 清空缓存，权限变化会影响目录下所有文件的检查结果
+func (cache *AccessCache) Clear() {
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	if len(cache.entries) > 0 {
+		cache.entries = make(map[string]accessEntry)
+	}
+}
+
+// fsAction 把access(2)的mask转换成CHECKACCESS的fsaction，比如 R_OK|X_OK 是 "r-x"
+func fsAction(mask uint32) string {
+
+	action := []byte("---")
+	if mask&accessRead != 0 {
+		action[0] = 'r'
+	}
+	if mask&accessWrite != 0 {
+		action[1] = 'w'
+	}
+	if mask&accessExecute != 0 {
+		action[2] = 'x'
+	}
+
+	return string(action)
+}
+
+var access = func(req fuse.Req, nodeid uint64, mask uint32) (result int32) {
+
+	path := pathManager.Get(nodeid)
+	if path == "" {
+		return errno.ENOENT
+	}
+
+	if cached, ok := accessCache.Get(req.Uid, path, mask); ok {
+		return cached
+	}
+
+	ctx, done := requestManager.Begin(req)
+	defer done()
+	ctx, err := proxyUsers.WithCaller(ctx, req)
+	if err != nil {
+		return toErrno(err)
+	}
+
+	err = hadoopControler.CheckAccess(ctx, remotePath(path), fsAction(mask))
+
+	switch {
+	case err == nil:
+		result = errno.SUCCESS
+	case errors.Is(err, herr.ErrAccess):
+		result = errno.EACCES
+	case errors.Is(err, herr.ErrNotsup):
+		// NameNode不支持CHECKACCESS，内核之后不再调用access，与没有实现时一样
+		return errno.ENOSYS
+	default:
+		return toErrno(err)
+	}
+
+	accessCache.Set(req.Uid, path, mask, result)
+
+	return result
+}
@@ -0,0 +1,60 @@
+package fs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+)
+
+func TestFsAction(t *testing.T) {
+	cases := map[uint32]string{
+		0:                                        "---",
+		accessRead:                               "r--",
+		accessWrite:                              "-w-",
+		accessRead | accessExecute:               "r-x",
+		accessRead | accessWrite | accessExecute: "rwx",
+	}
+	for mask, want := range cases {
+		if got := fsAction(mask); got != want {
+			t.Errorf("fsAction(%d) = %q, want %q", mask, got, want)
+		}
+	}
+}
+
+func TestAccess(t *testing.T) {
+	memory := newRemoteRootBackend(t)
+	ctx := context.Background()
+	if err := memory.SetPermission(ctx, "/user/alice/file", "444"); err != nil {
+		t.Fatal(err)
+	}
+
+	pathManager.Init()
+	pathManager.Set(2, "/user/alice/file")
+	requestManager.Init()
+	accessCache.Init(5)
+
+	req := fuse.Req{Uid: 1000}
+	if result := access(req, 2, accessRead); result != errno.SUCCESS {
+		t.Errorf("access R_OK: got %d", result)
+	}
+	if result := access(req, 2, accessWrite); result != errno.EACCES {
+		t.Errorf("access W_OK: got %d, want EACCES", result)
+	}
+	if result := access(req, 3, accessRead); result != errno.ENOENT {
+		t.Errorf("access of unknown node: got %d, want ENOENT", result)
+	}
+
+	// 结果被缓存，修改权限后清空
+	if err := memory.SetPermission(ctx, "/user/alice/file", "644"); err != nil {
+		t.Fatal(err)
+	}
+	if result := access(req, 2, accessWrite); result != errno.EACCES {
+		t.Errorf("access W_OK should be cached: got %d", result)
+	}
+	accessCache.Clear()
+	if result := access(req, 2, accessWrite); result != errno.SUCCESS {
+		t.Errorf("access W_OK after clear: got %d", result)
+	}
+}
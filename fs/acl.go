@@ -0,0 +1,265 @@
+package fs
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hadoop-fs/fs/controler"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"hadoop-fs/fs/model"
+	"sort"
+	"strconv"
+)
+
+// ACL对应的xattr，getfacl、setfacl 通过它们读写ACL
+const (
+	xattrAclAccess  = "system.posix_acl_access"
+	xattrAclDefault = "system.posix_acl_default"
+)
+
+// POSIX ACL xattr的二进制格式，与内核的 posix_acl_xattr.h 一致：
+// 4字节的版本号，之后每一项是2字节的tag、2字节的权限和4字节的uid或gid，都是小端序
+const (
+	aclXattrVersion   = 2
+	aclXattrEntrySize = 8
+	aclUndefinedID    = 0xffffffff
+
+	aclTagUserObj  = 0x01
+	aclTagUser     = 0x02
+	aclTagGroupObj = 0x04
+	aclTagGroup    = 0x08
+	aclTagMask     = 0x10
+	aclTagOther    = 0x20
+)
+
+// isAclXattr name 是否是ACL对应的xattr
+func isAclXattr(name string) bool {
+	return name == xattrAclAccess || name == xattrAclDefault
+}
+
+// aclScope 从 AclStatus 中取出access或者default的完整ACL，没有扩展ACL时返回nil。
+// access ACL的owner、mask和other来自权限位
+func aclScope(status controler.AclStatus, defaultAcl bool) ([]model.AclEntry, error) {
+
+	entries := make([]model.AclEntry, 0)
+	for _, spec := range status.Entries {
+		entry, err := model.ParseAclEntry(spec)
+		if err != nil {
+			return nil, err
+		}
+		if entry.Default == defaultAcl {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 || defaultAcl {
+		return entries, nil
+	}
+
+	mode, _ := strconv.ParseUint(status.Permission, 8, 16)
+	entries = append(entries,
+		model.AclEntry{Type: model.AclUser, Perm: uint32(mode>>6) & 7},
+		model.AclEntry{Type: model.AclMask, Perm: uint32(mode>>3) & 7},
+		model.AclEntry{Type: model.AclOther, Perm: uint32(mode) & 7})
+
+	return entries, nil
+}
+
+// encodeAclXattr 把ACL转换成xattr的格式，有名字的项按 uid_map、gid_map 和本地的用户、组转换
+func encodeAclXattr(entries []model.AclEntry) (string, error) {
+
+	type posixEntry struct {
+		tag  uint16
+		perm uint16
+		id   uint32
+	}
+
+	posix := make([]posixEntry, 0, len(entries))
+	for _, entry := range entries {
+		e := posixEntry{perm: uint16(entry.Perm), id: aclUndefinedID}
+		switch {
+		case entry.Type == model.AclUser && entry.Name == "":
+			e.tag = aclTagUserObj
+		case entry.Type == model.AclGroup && entry.Name == "":
+			e.tag = aclTagGroupObj
+		case entry.Type == model.AclMask:
+			e.tag = aclTagMask
+		case entry.Type == model.AclOther:
+			e.tag = aclTagOther
+		case entry.Type == model.AclUser:
+			uid, ok := model.LookupUID(entry.Name)
+			if !ok {
+				return "", fmt.Errorf("ACL entry [%s]: HDFS user has no local uid", entry)
+			}
+			e.tag, e.id = aclTagUser, uid
+		default:
+			gid, ok := model.LookupGID(entry.Name)
+			if !ok {
+				return "", fmt.Errorf("ACL entry [%s]: HDFS group has no local gid", entry)
+			}
+			e.tag, e.id = aclTagGroup, gid
+		}
+		posix = append(posix, e)
+	}
+
+	sort.Slice(posix, func(i, j int) bool {
+		if posix[i].tag != posix[j].tag {
+			return posix[i].tag < posix[j].tag
+		}
+		return posix[i].id < posix[j].id
+	})
+
+	buf := make([]byte, 4+aclXattrEntrySize*len(posix))
+	binary.LittleEndian.PutUint32(buf, aclXattrVersion)
+	for i, e := range posix {
+		b := buf[4+aclXattrEntrySize*i:]
+		binary.LittleEndian.PutUint16(b, e.tag)
+		binary.LittleEndian.PutUint16(b[2:], e.perm)
+		binary.LittleEndian.PutUint32(b[4:], e.id)
+	}
+
+	return string(buf), nil
+}
+
+// decodeAclXattr 解析xattr格式的ACL，uid、gid按 identities 转换成HDFS的用户和组
+func decodeAclXattr(value string, defaultAcl bool) ([]model.AclEntry, error) {
+
+	buf := []byte(value)
+	if len(buf) < 4 || (len(buf)-4)%aclXattrEntrySize != 0 || binary.LittleEndian.Uint32(buf) != aclXattrVersion {
+		return nil, fmt.Errorf("invalid POSIX ACL xattr: %w", herr.ErrInvalid)
+	}
+
+	entries := make([]model.AclEntry, 0)
+	for b := buf[4:]; len(b) > 0; b = b[aclXattrEntrySize:] {
+		tag := binary.LittleEndian.Uint16(b)
+		perm := binary.LittleEndian.Uint16(b[2:])
+		id := binary.LittleEndian.Uint32(b[4:])
+		if perm > 7 {
+			return nil, fmt.Errorf("invalid POSIX ACL permission [%o]: %w", perm, herr.ErrInvalid)
+		}
+
+		entry := model.AclEntry{Default: defaultAcl, Perm: uint32(perm)}
+		var err error
+		switch tag {
+		case aclTagUserObj:
+			entry.Type = model.AclUser
+		case aclTagUser:
+			entry.Type = model.AclUser
+			entry.Name, err = identities.User(id)
+		case aclTagGroupObj:
+			entry.Type = model.AclGroup
+		case aclTagGroup:
+			entry.Type = model.AclGroup
+			entry.Name, err = identities.Group(id)
+		case aclTagMask:
+			entry.Type = model.AclMask
+		case aclTagOther:
+			entry.Type = model.AclOther
+		default:
+			return nil, fmt.Errorf("invalid POSIX ACL tag [%#x]: %w", tag, herr.ErrInvalid)
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// getAclXattr 读取ACL并转换成xattr，没有ACL时返回 herr.ErrNoAttr
+func getAclXattr(ctx context.Context, filepath, name string) (string, error) {
+
+	status, err := hadoopControler.GetAclStatus(ctx, filepath)
+	if err != nil {
+		return "", err
+	}
+
+	entries, err := aclScope(status, name == xattrAclDefault)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "", herr.ErrNoAttr
+	}
+
+	return encodeAclXattr(entries)
+}
+
+// setAclXattr 把xattr转换成aclspec后用SETACL替换access或者default ACL，另一类保持不变
+func setAclXattr(ctx context.Context, filepath, name, value string) error {
+
+	defaultAcl := name == xattrAclDefault
+
+	entries, err := decodeAclXattr(value, defaultAcl)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		if defaultAcl {
+			return hadoopControler.RemoveDefaultAcl(ctx, filepath)
+		}
+		return fmt.Errorf("empty access ACL: %w", herr.ErrInvalid)
+	}
+
+	return hadoopControler.SetAcl(ctx, filepath, model.AclSpec(entries))
+}
+
+// removeAclXattr 删除access ACL时用REMOVEACLENTRIES删除有名字的access项，权限位的group恢复为group项的权限，
+// default ACL不变。删除default ACL时用REMOVEDEFAULTACL
+func removeAclXattr(ctx context.Context, filepath, name string) error {
+
+	if name == xattrAclDefault {
+		return hadoopControler.RemoveDefaultAcl(ctx, filepath)
+	}
+
+	status, err := hadoopControler.GetAclStatus(ctx, filepath)
+	if err != nil {
+		return err
+	}
+
+	entries, err := aclScope(status, false)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return herr.ErrNoAttr
+	}
+
+	named := make([]model.AclEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Name != "" {
+			named = append(named, entry)
+		}
+	}
+	if len(named) == 0 {
+		return nil
+	}
+
+	return hadoopControler.RemoveAclEntries(ctx, filepath, model.AclRemoveSpec(named))
+}
+
+// listAclXattrs 返回存在的ACL xattr的名字，只发送一次GETACLSTATUS。ACL没有开启时没有ACL xattr
+func listAclXattrs(ctx context.Context, filepath string) ([]string, error) {
+
+	status, err := hadoopControler.GetAclStatus(ctx, filepath)
+	if errors.Is(err, herr.ErrNotsup) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, 2)
+	for _, name := range []string{xattrAclAccess, xattrAclDefault} {
+		entries, err := aclScope(status, name == xattrAclDefault)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) > 0 {
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
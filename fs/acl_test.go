@@ -0,0 +1,189 @@
+package fs
+
+import (
+	"context"
+	"hadoop-fs/fs/controler"
+	"hadoop-fs/fs/controler/webhdfstest"
+	"hadoop-fs/fs/model"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+)
+
+func setTestIDMap(t *testing.T) {
+	t.Helper()
+
+	users := map[uint32]string{1001: "bob"}
+	groups := map[uint32]string{100: "analysts"}
+	identities.Init(users, groups)
+	model.SetIDMap(users, groups)
+	t.Cleanup(func() { model.SetIDMap(nil, nil) })
+}
+
+func TestAclXattrEncoding(t *testing.T) {
+	setTestIDMap(t)
+
+	entries, err := model.ParseAclSpec("user::rwx,user:bob:rw-,group::r--,group:analysts:r-x,mask::rwx,other::---")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := encodeAclXattr(entries)
+	if err != nil {
+		t.Fatalf("encodeAclXattr: %v", err)
+	}
+	if len(value) != 4+6*aclXattrEntrySize {
+		t.Fatalf("encodeAclXattr: got %d bytes", len(value))
+	}
+
+	decoded, err := decodeAclXattr(value, false)
+	if err != nil {
+		t.Fatalf("decodeAclXattr: %v", err)
+	}
+	// 按tag排序：user::, user:bob, group::, group:analysts, mask, other
+	if got := model.AclSpec(decoded); got != "user::rwx,user:bob:rw-,group::r--,group:analysts:r-x,mask::rwx,other::---" {
+		t.Errorf("decodeAclXattr: got %s", got)
+	}
+
+	if _, err = encodeAclXattr([]model.AclEntry{{Type: model.AclUser, Name: "no-such-user-in-test"}}); err == nil {
+		t.Errorf("encodeAclXattr of an unknown user: expected error")
+	}
+	for _, invalid := range []string{"", "\x01\x00\x00\x00", value[:len(value)-1]} {
+		if _, err = decodeAclXattr(invalid, false); err == nil {
+			t.Errorf("decodeAclXattr(%q): expected error", invalid)
+		}
+	}
+}
+
+func TestAclXattr(t *testing.T) {
+	memory := newRemoteRootBackend(t)
+	setTestIDMap(t)
+	ctx := context.Background()
+
+	pathManager.Init()
+	pathManager.Set(2, "/user/alice/data")
+	requestManager.Init()
+	accessCache.Init(5)
+
+	req := fuse.Req{}
+	if _, result := getxattr(req, 2, xattrAclAccess, 0); result != errno.ENODATA {
+		t.Errorf("getxattr without ACL: got %d, want ENODATA", result)
+	}
+
+	entries, _ := model.ParseAclSpec("user::rwx,user:bob:rw-,group::r-x,mask::rwx,other::---")
+	value, _ := encodeAclXattr(entries)
+	if result := setxattr(req, 2, xattrAclAccess, value, 0); result != errno.SUCCESS {
+		t.Fatalf("setxattr %s: got %d", xattrAclAccess, result)
+	}
+	status, _ := memory.GetAclStatus(ctx, "/user/alice/data")
+	if status.Permission != "770" || !reflect.DeepEqual(status.Entries, []string{"user:bob:rw-", "group::r-x"}) {
+		t.Errorf("ACL after setxattr: got %+v", status)
+	}
+	if got, result := getxattr(req, 2, xattrAclAccess, 0); result != errno.SUCCESS || got != value {
+		t.Errorf("getxattr %s: got %q, %d, want %q", xattrAclAccess, got, result, value)
+	}
+
+	defaults, _ := model.ParseAclSpec("user::rwx,group::r-x,other::---")
+	value, _ = encodeAclXattr(defaults)
+	if result := setxattr(req, 2, xattrAclDefault, value, 0); result != errno.SUCCESS {
+		t.Fatalf("setxattr %s: got %d", xattrAclDefault, result)
+	}
+	if list, result := listxattr(req, 2, 0); result != errno.SUCCESS || list != strings.Join([]string{xattrAclAccess, xattrAclDefault}, "\x00") {
+		t.Errorf("listxattr: got %q, %d", list, result)
+	}
+
+	// 普通文件只可能有access ACL，不需要读取ACL
+	if err := memory.Create(ctx, "/user/alice/data/a", "644"); err != nil {
+		t.Fatal(err)
+	}
+	if err := memory.SetAcl(ctx, "/user/alice/data/a", "user::rw-,user:bob:r--,group::r--,other::---"); err != nil {
+		t.Fatal(err)
+	}
+	pathManager.Set(3, "/user/alice/data/a")
+	if list, result := listxattr(req, 3, 0); result != errno.SUCCESS || list != xattrAclAccess {
+		t.Errorf("listxattr of a file: got %q, %d", list, result)
+	}
+
+	// 删除access ACL后权限位的group恢复为group项的权限，default ACL不变
+	if result := removexattr(req, 2, xattrAclAccess); result != errno.SUCCESS {
+		t.Fatalf("removexattr %s: got %d", xattrAclAccess, result)
+	}
+	status, _ = memory.GetAclStatus(ctx, "/user/alice/data")
+	want := []string{"default:user::rwx", "default:group::r-x", "default:other::---"}
+	if status.Permission != "750" || !reflect.DeepEqual(status.Entries, want) {
+		t.Errorf("ACL after removexattr: got %+v", status)
+	}
+	if result := removexattr(req, 2, xattrAclAccess); result != errno.ENODATA {
+		t.Errorf("removexattr without access ACL: got %d, want ENODATA", result)
+	}
+
+	if result := setxattr(req, 2, xattrAclAccess, "invalid", 0); result != errno.EINVAL {
+		t.Errorf("setxattr of invalid ACL: got %d, want EINVAL", result)
+	}
+}
+
+func TestAclXattrRequests(t *testing.T) {
+	server := webhdfstest.NewServer()
+	defer server.Close()
+	server.AddDir("/dir")
+
+	hadoop := &controler.HadoopController{}
+	hadoop.Init(false, server.Host(), server.Port(), "alice")
+	saved := hadoopControler
+	hadoopControler = hadoop
+	defer func() { hadoopControler = saved }()
+	setTestIDMap(t)
+
+	pathManager.Init()
+	pathManager.Set(2, "/dir")
+	requestManager.Init()
+	accessCache.Init(0)
+
+	ctx := context.Background()
+	spec := "user::rwx,user:bob:rwx,group::r-x,other::---,default:user::rwx,default:group::r-x,default:other::---"
+	if err := hadoop.SetAcl(ctx, "/dir", spec); err != nil {
+		t.Fatal(err)
+	}
+
+	// listxattr 只发送GETACLSTATUS
+	before := len(server.Requests())
+	if list, result := listxattr(fuse.Req{}, 2, 0); result != errno.SUCCESS || list != xattrAclAccess+"\x00"+xattrAclDefault {
+		t.Errorf("listxattr: got %q, %d", list, result)
+	}
+	for _, request := range server.Requests()[before:] {
+		if request.Op == "GETFILESTATUS" {
+			t.Errorf("listxattr sent GETFILESTATUS")
+		}
+	}
+
+	// 删除access ACL只删除有名字的项，不会修改default ACL
+	before = len(server.Requests())
+	if result := removexattr(fuse.Req{}, 2, xattrAclAccess); result != errno.SUCCESS {
+		t.Fatalf("removexattr: got %d", result)
+	}
+	for _, request := range server.Requests()[before:] {
+		if request.Op == "REMOVEACL" || request.Op == "SETACL" {
+			t.Errorf("removexattr sent %s", request.Op)
+		}
+	}
+	if request, ok := server.LastRequest("REMOVEACLENTRIES"); !ok || request.Query.Get("aclspec") != "user:bob" {
+		t.Errorf("REMOVEACLENTRIES: got %+v, %v", request, ok)
+	}
+	status, _ := hadoop.GetAclStatus(ctx, "/dir")
+	want := []string{"default:user::rwx", "default:group::r-x", "default:other::---"}
+	if status.Permission != "750" || !reflect.DeepEqual(status.Entries, want) {
+		t.Errorf("ACL after removexattr: got %+v", status)
+	}
+}
+
+func TestAdjustNormalAclBit(t *testing.T) {
+	// Hadoop 2.x 在permission中用 1<<12 表示有ACL
+	file := model.FileModel{HadoopType: model.HadoopFile, HadoopPermission: "10644"}
+	file.AdjustNormal()
+	if !file.AclBit || file.StMode != 0644 {
+		t.Errorf("AdjustNormal: got aclBit %v, mode %o", file.AclBit, file.StMode)
+	}
+}
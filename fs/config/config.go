@@ -1,50 +1,236 @@
 package config
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"net"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// HadoopConfig 一个HDFS集群的配置，JSON的字段名与命令行参数相同，用于挂载表中的集群配置
 type HadoopConfig struct {
-	IsSSL bool
-	Host  string
-	Port  int
+	IsSSL bool   `json:"hadoop_ssl"`
+	Host  string `json:"hadoop_host"`
+	Port  int    `json:"hadoop_port"`
 
-	Username string
+	// https(swebhdfs) 相关的配置
+	SSLCACert     string `json:"hadoop_ssl_ca"`          // CA证书，为空时使用系统的CA证书
+	SSLClientCert string `json:"hadoop_ssl_cert"`        // 双向认证时客户端的证书
+	SSLClientKey  string `json:"hadoop_ssl_key"`         // 双向认证时客户端的私钥
+	SSLServerName string `json:"hadoop_ssl_server_name"` // 验证NameNode证书时使用的名字
+	SSLInsecure   bool   `json:"hadoop_ssl_insecure"`    // 不验证服务端的证书
 
-	Delegation string
+	// NameNodes HA时所有NameNode的地址(host:port)，设置后忽略Host和Port
+	NameNodes []string `json:"hadoop_namenodes"`
+
+	// DataNodeMap DataNode地址的改写规则，key和value是host或者host:port
+	DataNodeMap map[string]string `json:"hadoop_datanode_map"`
+
+	// Gateway 服务端的类型，auto、webhdfs 或者 httpfs
+	Gateway string `json:"hadoop_gateway"`
+
+	Username string `json:"hadoop_username"`
+
+	Delegation        string `json:"hadoop_delegation"`
+	DelegationFetch   bool   `json:"hadoop_delegation_fetch"`   // 是否自己通过 GETDELEGATIONTOKEN 获取token
+	DelegationRenewer string `json:"hadoop_delegation_renewer"` // 获取token时指定的renewer
+
+	// Kerberos 认证，设置了 KerberosKeytab 或者 KerberosCCache 时启用
+	KerberosPrincipal string `json:"kerberos_principal"`
+	KerberosKeytab    string `json:"kerberos_keytab"`
+	KerberosCCache    string `json:"kerberos_ccache"`
+	KerberosKrb5Conf  string `json:"krb5_conf"`
+	KerberosSPN       string `json:"kerberos_spn"`
+
+	// OAuth2 认证，设置了 OAuth2TokenURL 时启用，有refresh token时使用refresh_token授权，否则使用client_credentials。
+	// client secret和refresh token从文件或者环境变量读取，不从命令行传入
+	OAuth2TokenURL         string `json:"oauth2_token_url"`
+	OAuth2ClientID         string `json:"oauth2_client_id"`
+	OAuth2ClientSecret     string `json:"-"`
+	OAuth2ClientSecretFile string `json:"oauth2_client_secret_file"`
+	OAuth2RefreshToken     string `json:"-"`
+	OAuth2RefreshTokenFile string `json:"oauth2_refresh_token_file"`
+	OAuth2Scope            string `json:"oauth2_scope"`
+
+	// BasePath WebHDFS REST API 的路径前缀，通过Apache Knox访问时是 /gateway/<topology>/webhdfs/v1
+	BasePath string `json:"hadoop_base_path"`
+
+	// Headers 所有请求都带上的请求头
+	Headers map[string]string `json:"hadoop_header"`
+	// CSRFHeader 修改操作带上的CSRF请求头，为空时不发送
+	CSRFHeader string `json:"hadoop_csrf_header"`
+
+	// HTTP Basic 认证，设置了 BasicUser 时启用，密码从 BasicPasswordFile 或者环境变量读取，不从命令行传入
+	BasicUser         string `json:"basic_auth_user"`
+	BasicPassword     string `json:"-"`
+	BasicPasswordFile string `json:"basic_auth_password_file"`
+
+	// HTTP连接相关的配置，为0时使用默认值
+	HTTPMaxIdleConns    int           `json:"http_max_idle_conns"`   // 每个节点保持的空闲连接数
+	HTTPMaxConns        int           `json:"http_max_conns"`        // 每个节点的最大连接数，0表示不限制
+	HTTPIdleTimeout     time.Duration `json:"http_idle_timeout"`     // 空闲连接保持的时间
+	HTTPDialTimeout     time.Duration `json:"http_dial_timeout"`     // 建立连接的超时时间
+	HTTPResponseTimeout time.Duration `json:"http_response_timeout"` // 等待响应头的超时时间
+	HTTPMetadataTimeout time.Duration `json:"http_metadata_timeout"` // 元数据操作的总超时时间
+	HTTPDataTimeout     time.Duration `json:"http_data_timeout"`     // 读写数据操作的总超时时间
+
+	// NameNodeConcurrency 同时发往NameNode的最大请求数，0表示不限制
+	NameNodeConcurrency int `json:"namenode_concurrency"`
+	// NameNodeStatsInterval 输出NameNode请求统计的间隔，0表示不输出
+	NameNodeStatsInterval time.Duration `json:"namenode_stats_interval"`
+
+	// 请求遇到暂时性的错误时的重试配置
+	RetryMax       int           `json:"retry_max"`        // 最多重试的次数，0表示不重试
+	RetryBaseDelay time.Duration `json:"retry_base_delay"` // 第一次重试前等待的时间，之后每次翻倍
+	RetryMaxDelay  time.Duration `json:"retry_max_delay"`  // 两次重试之间最长的等待时间
+}
+
+// IsKerberos 是否使用Kerberos认证
+func (hadoop *HadoopConfig) IsKerberos() bool {
+	return hadoop.KerberosKeytab != "" || hadoop.KerberosCCache != ""
+}
+
+// IsBasic 是否使用HTTP Basic认证
+func (hadoop *HadoopConfig) IsBasic() bool {
+	return hadoop.BasicUser != ""
+}
+
+// IsOAuth2 是否使用OAuth2认证
+func (hadoop *HadoopConfig) IsOAuth2() bool {
+	return hadoop.OAuth2TokenURL != ""
 }
 
 type Config struct {
 	Mountpoint  string
 	Attrtimeout float64
 
-	Debug                bool // 是否是debug模式
-	NotExistCacheTimeout int  // 文件不存在会缓存的时间，单位秒
+	Debug                bool   // 是否是debug模式
+	NotExistCacheTimeout int    // 文件不存在会缓存的时间，单位秒
+	StatfsCacheTimeout   int    // statfs的结果缓存的时间，单位秒
+	AccessCacheTimeout   int    // access的结果缓存的时间，单位秒
+	Backend              string // 存储后端, hadoop 或者 memory
+	RemoteRoot           string // 挂载的HDFS目录，"~" 开头时相对于用户的home目录
+	ReadOnly             bool   // 只读挂载，所有修改操作返回EROFS
+	SymlinkEmulation     bool   // 用带有标记xattr的普通文件模拟软连接，用于没有开启软连接的HDFS
+
+	// 以调用者的身份(doas)访问HDFS
+	ProxyUser     bool
+	ProxyUserMap  map[uint32]string // uid对应的HDFS用户，没有配置的uid使用本地的用户名
+	ProxyUserDeny []string          // 不允许代理的uid或者用户名
+
+	// chown/chgrp时本地的uid/gid对应的HDFS用户和组，没有配置的使用本地的用户名和组名
+	UIDMap map[uint32]string
+	GIDMap map[uint32]string
 
 	Hadoop HadoopConfig
+
+	// 多集群的挂载表，为空时只挂载 Hadoop 一个集群
+	Clusters map[string]HadoopConfig // 集群名对应的配置
+	Mounts   []MountConfig
 }
 
 var config = Config{}
 
+// BasicPasswordEnv 保存HTTP Basic认证密码的环境变量
+const BasicPasswordEnv = "HADOOP_FS_BASIC_PASSWORD"
+
+// 保存OAuth2 client secret和refresh token的环境变量
+const (
+	OAuth2ClientSecretEnv = "HADOOP_FS_OAUTH2_CLIENT_SECRET"
+	OAuth2RefreshTokenEnv = "HADOOP_FS_OAUTH2_REFRESH_TOKEN"
+)
+
+// headerFlags 可以多次设置的请求头参数，格式是 "Name: value"
+type headerFlags []string
+
+func (headers *headerFlags) String() string {
+	return strings.Join(*headers, ", ")
+}
+
+func (headers *headerFlags) Set(value string) error {
+	*headers = append(*headers, value)
+	return nil
+}
+
+var namenodes string
+var mountTable string
+var headers headerFlags
+var datanodeMap string
+var proxyUserMap string
+var proxyUserDeny string
+var uidMap string
+var gidMap string
+
 func init() {
 	flag.StringVar(&config.Mountpoint, "mp", "", "mountpoint")
 	flag.Float64Var(&config.Attrtimeout, "attr_timeout", 10, "file attr timeout")
 
-	flag.BoolVar(&config.Hadoop.IsSSL, "hadoop_ssl", false, "If Hadoop WebHDFS REST API use HTTP?")
+	flag.BoolVar(&config.Hadoop.IsSSL, "hadoop_ssl", false, "If Hadoop WebHDFS REST API use HTTPS?")
+	flag.StringVar(&config.Hadoop.SSLCACert, "hadoop_ssl_ca", "", "CA certificates file(PEM) to verify the server, default is the system CA")
+	flag.StringVar(&config.Hadoop.SSLClientCert, "hadoop_ssl_cert", "", "Client certificate file(PEM) for mutual TLS")
+	flag.StringVar(&config.Hadoop.SSLClientKey, "hadoop_ssl_key", "", "Client private key file(PEM) for mutual TLS")
+	flag.StringVar(&config.Hadoop.SSLServerName, "hadoop_ssl_server_name", "", "Server name to verify the NameNode certificate, default is the NameNode host")
+	flag.BoolVar(&config.Hadoop.SSLInsecure, "hadoop_ssl_insecure", false, "Skip server certificate verification, INSECURE, for testing only")
 	flag.StringVar(&config.Hadoop.Host, "hadoop_host", "", "Hadoop WebHDFS REST API hostname or IP")
 	flag.IntVar(&config.Hadoop.Port, "hadoop_port", -1, "Hadoop WebHDFS REST API port")
+	flag.StringVar(&namenodes, "hadoop_namenodes", "", "All NameNodes of a HA nameservice, such as nn1:50070,nn2:50070, instead of -hadoop_host and -hadoop_port")
+	flag.StringVar(&datanodeMap, "hadoop_datanode_map", "", "Rewrite DataNode addresses in redirects, such as dn1.internal:9864=127.0.0.1:19864,dn2.internal=10.0.0.2")
+	flag.StringVar(&config.Hadoop.Gateway, "hadoop_gateway", "auto", "Type of the REST API server, \"webhdfs\", \"httpfs\" or \"auto\"(detect HttpFS by its redirect on upload)")
 	flag.StringVar(&config.Hadoop.Username, "hadoop_username", "", "Hadoop WebHDFS REST API username")
 	flag.StringVar(&config.Hadoop.Delegation, "hadoop_delegation", "", "Hadoop WebHDFS REST API delegation")
+	flag.BoolVar(&config.Hadoop.DelegationFetch, "hadoop_delegation_fetch", false, "Fetch a delegation token on mount, renew it in background and cancel it on umount")
+	flag.StringVar(&config.Hadoop.DelegationRenewer, "hadoop_delegation_renewer", "", "Renewer of the fetched delegation token, default is the login user")
+	flag.StringVar(&config.Hadoop.KerberosPrincipal, "kerberos_principal", "", "Kerberos principal, such as user@EXAMPLE.COM, used with -kerberos_keytab")
+	flag.StringVar(&config.Hadoop.KerberosKeytab, "kerberos_keytab", "", "Kerberos keytab file, enable SPNEGO authentication")
+	flag.StringVar(&config.Hadoop.KerberosCCache, "kerberos_ccache", "", "Kerberos ticket cache file, enable SPNEGO authentication, such as /tmp/krb5cc_1000")
+	flag.StringVar(&config.Hadoop.KerberosKrb5Conf, "krb5_conf", "/etc/krb5.conf", "Kerberos config file")
+	flag.StringVar(&config.Hadoop.KerberosSPN, "kerberos_spn", "", "Kerberos service principal of WebHDFS, default is HTTP/<hadoop_host>")
+	flag.StringVar(&config.Hadoop.BasePath, "hadoop_base_path", "/webhdfs/v1", "Path prefix of WebHDFS REST API, such as /gateway/default/webhdfs/v1 for Apache Knox")
+	flag.Var(&headers, "hadoop_header", "Custom header sent with every request, such as \"X-Request-Source: hadoop-fs\", can be repeated")
+	flag.StringVar(&config.Hadoop.CSRFHeader, "hadoop_csrf_header", "X-XSRF-HEADER", "Header sent with PUT, POST and DELETE requests for dfs.webhdfs.rest-csrf.enabled clusters, empty to disable")
+	flag.StringVar(&config.Hadoop.BasicUser, "basic_auth_user", "", "Username of HTTP Basic authentication, such as for Apache Knox, the password is read from -basic_auth_password_file or $"+BasicPasswordEnv)
+	flag.StringVar(&config.Hadoop.BasicPasswordFile, "basic_auth_password_file", "", "File containing the password of HTTP Basic authentication")
+	flag.StringVar(&config.Hadoop.OAuth2TokenURL, "oauth2_token_url", "", "OAuth2 token endpoint, enable OAuth2 bearer token authentication")
+	flag.StringVar(&config.Hadoop.OAuth2ClientID, "oauth2_client_id", "", "OAuth2 client id")
+	flag.StringVar(&config.Hadoop.OAuth2ClientSecretFile, "oauth2_client_secret_file", "", "File containing the OAuth2 client secret, or set $"+OAuth2ClientSecretEnv)
+	flag.StringVar(&config.Hadoop.OAuth2RefreshTokenFile, "oauth2_refresh_token_file", "", "File containing the OAuth2 refresh token, or set $"+OAuth2RefreshTokenEnv+", use refresh_token grant instead of client_credentials")
+	flag.StringVar(&config.Hadoop.OAuth2Scope, "oauth2_scope", "", "OAuth2 scope of the access token")
+	flag.IntVar(&config.Hadoop.HTTPMaxIdleConns, "http_max_idle_conns", 16, "Max idle connections kept to each NameNode or DataNode")
+	flag.IntVar(&config.Hadoop.HTTPMaxConns, "http_max_conns", 0, "Max connections to each NameNode or DataNode, 0 means no limit")
+	flag.DurationVar(&config.Hadoop.HTTPIdleTimeout, "http_idle_timeout", 90*time.Second, "How long an idle connection is kept")
+	flag.DurationVar(&config.Hadoop.HTTPDialTimeout, "http_dial_timeout", 10*time.Second, "Timeout of connecting to NameNode or DataNode")
+	flag.DurationVar(&config.Hadoop.HTTPResponseTimeout, "http_response_timeout", 60*time.Second, "Timeout of waiting for the response headers")
+	flag.DurationVar(&config.Hadoop.HTTPMetadataTimeout, "http_metadata_timeout", 60*time.Second, "Total timeout of a metadata operation, such as GETFILESTATUS")
+	flag.DurationVar(&config.Hadoop.HTTPDataTimeout, "http_data_timeout", 10*time.Minute, "Total timeout of a data operation, OPEN, CREATE or APPEND")
+	flag.IntVar(&config.Hadoop.NameNodeConcurrency, "namenode_concurrency", 32, "Max concurrent requests to NameNode, others are queued, 0 means no limit")
+	flag.DurationVar(&config.Hadoop.NameNodeStatsInterval, "namenode_stats_interval", 10*time.Minute, "Interval of logging the NameNode request statistics, 0 means never")
+	flag.IntVar(&config.Hadoop.RetryMax, "retry_max", 3, "Max retries of a request on transient failures, 0 means no retry")
+	flag.DurationVar(&config.Hadoop.RetryBaseDelay, "retry_base_delay", 200*time.Millisecond, "Delay before the first retry, doubled on each retry")
+	flag.DurationVar(&config.Hadoop.RetryMaxDelay, "retry_max_delay", 5*time.Second, "Max delay between two retries")
+	flag.BoolVar(&config.ProxyUser, "proxy_user", false, "Access HDFS as the calling local user with doas, -hadoop_username must be allowed to impersonate them")
+	flag.StringVar(&proxyUserMap, "proxy_user_map", "", "Map uid to HDFS user for -proxy_user, such as 1000=alice,1001=bob, default is the local username")
+	flag.StringVar(&proxyUserDeny, "proxy_user_deny", "0", "Uids or usernames not allowed to access with -proxy_user, such as 0,hdfs")
+	flag.StringVar(&mountTable, "mount_table", "", "JSON file of the mount table, mounting directories of several clusters, see README")
+	flag.StringVar(&uidMap, "uid_map", "", "Map local uid to HDFS user for chown and file owners, such as 1000=alice, default is the local username")
+	flag.StringVar(&gidMap, "gid_map", "", "Map local gid to HDFS group for chgrp and file groups, such as 100=analysts, default is the local group name")
+	flag.StringVar(&config.RemoteRoot, "remote_root", "/", "HDFS directory to mount, \"~\" or \"~/path\" is relative to the home directory of the user(GETHOMEDIRECTORY)")
+	flag.BoolVar(&config.ReadOnly, "read_only", false, "Mount read-only, all modifications fail with EROFS")
+	flag.BoolVar(&config.SymlinkEmulation, "symlink_emulation", false, "Emulate symlinks with small files marked by an xattr, for clusters without HDFS symlink support")
 	flag.BoolVar(&config.Debug, "debug", false, "Debug Mode")
 	flag.IntVar(&config.NotExistCacheTimeout, "not_exist_cache", 200, "How long for not exist file cache, default is 200s")
-
-	flag.Parse()
+	flag.IntVar(&config.StatfsCacheTimeout, "statfs_cache", 30, "How long the result of statfs(capacity and quota) is cached, in seconds")
+	flag.IntVar(&config.AccessCacheTimeout, "access_cache", 5, "How long the result of access(CHECKACCESS) is cached, in seconds")
+	flag.StringVar(&config.Backend, "backend", "hadoop", "Storage backend, \"hadoop\" or \"memory\"(in-memory, for testing)")
 }
 
 func ParseFromCmd() Config {
 
+	flag.Parse()
+
 	// mountpoint是必填的
 	if config.Mountpoint == "" {
 		fmt.Println("Please input mountpoint!")
@@ -62,16 +248,179 @@ func ParseFromCmd() Config {
 		os.Exit(-1)
 	}
 
-	// host和port是必填的
-	if config.Hadoop.Host == "" {
-		fmt.Println("Please input Hadoop WebHDFS REST API hostname or IP!")
+	if config.Backend != "hadoop" && config.Backend != "memory" {
+		fmt.Println("Backend must be \"hadoop\" or \"memory\"!")
 		os.Exit(-1)
+	}
+	config.ProxyUserMap = parseIDMap("proxy user map", proxyUserMap)
+	config.UIDMap = parseIDMap("uid map", uidMap)
+	config.GIDMap = parseIDMap("gid map", gidMap)
+	for _, deny := range strings.Split(proxyUserDeny, ",") {
+		if deny = strings.TrimSpace(deny); deny != "" {
+			config.ProxyUserDeny = append(config.ProxyUserDeny, deny)
+		}
+	}
 
+	for _, header := range headers {
+		kv := strings.SplitN(header, ":", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			fmt.Printf("Invalid header: %s, must be \"Name: value\"\n", header)
+			os.Exit(-1)
+		}
+		if config.Hadoop.Headers == nil {
+			config.Hadoop.Headers = make(map[string]string)
+		}
+		config.Hadoop.Headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
 	}
-	if config.Hadoop.Port < 0 {
-		fmt.Println("Please input Hadoop WebHDFS REST API port!")
-		os.Exit(-1)
+
+	if mountTable != "" {
+		// 挂载表中的集群以命令行的Hadoop配置为基础
+		config.Clusters, config.Mounts, err = LoadMountTable(mountTable, config.Hadoop)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(-1)
+		}
+	}
+
+	if config.Backend == "memory" {
+		// 内存后端不需要连接Hadoop
+		return config
+	}
+
+	for _, namenode := range strings.Split(namenodes, ",") {
+		if namenode = strings.TrimSpace(namenode); namenode != "" {
+			config.Hadoop.NameNodes = append(config.Hadoop.NameNodes, namenode)
+		}
+	}
+
+	for _, rewrite := range strings.Split(datanodeMap, ",") {
+		if rewrite = strings.TrimSpace(rewrite); rewrite == "" {
+			continue
+		}
+		kv := strings.SplitN(rewrite, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" || strings.TrimSpace(kv[1]) == "" {
+			fmt.Printf("Invalid DataNode map: %s, must be from=to\n", rewrite)
+			os.Exit(-1)
+		}
+		if config.Hadoop.DataNodeMap == nil {
+			config.Hadoop.DataNodeMap = make(map[string]string)
+		}
+		config.Hadoop.DataNodeMap[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+
+	if len(config.Mounts) == 0 {
+		if err = config.Hadoop.check(config.ProxyUser); err != nil {
+			fmt.Println(err)
+			os.Exit(-1)
+		}
+		return config
+	}
+
+	for name, cluster := range config.Clusters {
+		if err = cluster.check(config.ProxyUser); err != nil {
+			fmt.Printf("Cluster %s: %s\n", name, err)
+			os.Exit(-1)
+		}
+		config.Clusters[name] = cluster
 	}
 
 	return config
 }
+
+// parseIDMap 解析 "1000=alice,1001=bob" 格式的uid或者gid的对应关系
+func parseIDMap(name, value string) map[uint32]string {
+
+	var ids map[uint32]string
+
+	for _, mapping := range strings.Split(value, ",") {
+		if mapping = strings.TrimSpace(mapping); mapping == "" {
+			continue
+		}
+		kv := strings.SplitN(mapping, "=", 2)
+		id, err := strconv.ParseUint(strings.TrimSpace(kv[0]), 10, 32)
+		if len(kv) != 2 || err != nil || strings.TrimSpace(kv[1]) == "" {
+			fmt.Printf("Invalid %s: %s, must be id=name\n", name, mapping)
+			os.Exit(-1)
+		}
+		if ids == nil {
+			ids = make(map[uint32]string)
+		}
+		ids[uint32(id)] = strings.TrimSpace(kv[1])
+	}
+
+	return ids
+}
+
+// check 检查一个集群的配置，并补全HA时的Host和Port以及Basic认证的密码
+func (hadoop *HadoopConfig) check(proxyUser bool) error {
+
+	if len(hadoop.NameNodes) > 0 {
+		// HA时使用第一个NameNode作为Host和Port
+		host, port, err := net.SplitHostPort(hadoop.NameNodes[0])
+		if err != nil {
+			return fmt.Errorf("Invalid NameNode address: %s", err)
+		}
+		hadoop.Host = host
+		hadoop.Port, _ = strconv.Atoi(port)
+	}
+
+	// host和port是必填的
+	if hadoop.Host == "" {
+		return errors.New("Please input Hadoop WebHDFS REST API hostname or IP!")
+	}
+	if hadoop.Port < 0 {
+		return errors.New("Please input Hadoop WebHDFS REST API port!")
+	}
+
+	if hadoop.Gateway != "auto" && hadoop.Gateway != "webhdfs" && hadoop.Gateway != "httpfs" {
+		return errors.New("Gateway must be \"auto\", \"webhdfs\" or \"httpfs\"!")
+	}
+
+	// 客户端证书和私钥需要同时设置
+	if (hadoop.SSLClientCert == "") != (hadoop.SSLClientKey == "") {
+		return errors.New("Please input both client certificate and key for mutual TLS!")
+	}
+
+	// 只能使用一种认证方式
+	authCount := 0
+	for _, enabled := range []bool{hadoop.IsKerberos(), hadoop.IsOAuth2(), hadoop.IsBasic()} {
+		if enabled {
+			authCount++
+		}
+	}
+	if authCount > 1 {
+		return errors.New("Only one of Kerberos, OAuth2 and Basic authentication can be used!")
+	}
+
+	if hadoop.IsBasic() {
+		hadoop.BasicPassword = os.Getenv(BasicPasswordEnv)
+		if hadoop.BasicPasswordFile == "" && hadoop.BasicPassword == "" {
+			return fmt.Errorf("Please input Basic auth password by -basic_auth_password_file or $%s!", BasicPasswordEnv)
+		}
+	}
+
+	if hadoop.IsOAuth2() {
+		if hadoop.OAuth2ClientID == "" {
+			return errors.New("Please input OAuth2 client id!")
+		}
+		hadoop.OAuth2ClientSecret = os.Getenv(OAuth2ClientSecretEnv)
+		hadoop.OAuth2RefreshToken = os.Getenv(OAuth2RefreshTokenEnv)
+		if hadoop.OAuth2ClientSecret == "" && hadoop.OAuth2ClientSecretFile == "" &&
+			hadoop.OAuth2RefreshToken == "" && hadoop.OAuth2RefreshTokenFile == "" {
+			return fmt.Errorf("Please input OAuth2 client secret by -oauth2_client_secret_file or $%s, or refresh token by -oauth2_refresh_token_file or $%s!",
+				OAuth2ClientSecretEnv, OAuth2RefreshTokenEnv)
+		}
+	}
+
+	// delegation token已经确定了用户，不能再使用doas
+	if proxyUser && (hadoop.Delegation != "" || hadoop.DelegationFetch) {
+		return errors.New("Proxy user can not be used with delegation token!")
+	}
+
+	// 使用keytab时需要principal
+	if hadoop.KerberosKeytab != "" && hadoop.KerberosPrincipal == "" {
+		return errors.New("Please input Kerberos principal for keytab!")
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// MountConfig 挂载表中的一项，把挂载点中的 Path 映射到集群 Cluster 中的 Remote 目录
+type MountConfig struct {
+	Path    string `json:"path"`
+	Cluster string `json:"cluster"`
+	Remote  string `json:"remote"`
+}
+
+// mountTableFile 挂载表文件的格式，clusters 中每个集群的配置与命令行参数的名字相同(HadoopConfig 的json tag)
+type mountTableFile struct {
+	Clusters map[string]json.RawMessage `json:"clusters"`
+	Mounts   []MountConfig              `json:"mounts"`
+}
+
+// LoadMountTable 读取挂载表文件。每个集群的配置以 base 为基础，
+// 但是集群的地址、DataNode地址改写和delegation token不继承，需要在集群中设置
+func LoadMountTable(file string, base HadoopConfig) (map[string]HadoopConfig, []MountConfig, error) {
+
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read mount table failed: %v", err)
+	}
+
+	table := mountTableFile{}
+	if err = json.Unmarshal(buf, &table); err != nil {
+		return nil, nil, fmt.Errorf("parse mount table [%s] failed: %v", file, err)
+	}
+	if len(table.Mounts) == 0 {
+		return nil, nil, fmt.Errorf("mount table [%s] has no mounts", file)
+	}
+
+	clusters := make(map[string]HadoopConfig, len(table.Clusters))
+	for name, raw := range table.Clusters {
+		cluster := base.clusterBase()
+		if err = json.Unmarshal(raw, &cluster); err != nil {
+			return nil, nil, fmt.Errorf("parse cluster [%s] of mount table [%s] failed: %v", name, file, err)
+		}
+		clusters[name] = cluster
+	}
+
+	for _, mount := range table.Mounts {
+		if _, ok := clusters[mount.Cluster]; !ok {
+			return nil, nil, fmt.Errorf("mount table [%s]: unknown cluster [%s] of [%s]", file, mount.Cluster, mount.Path)
+		}
+	}
+
+	return clusters, table.Mounts, nil
+}
+
+// clusterBase 挂载表中集群配置的默认值，复制slice和map，避免解析JSON时修改 hadoop
+func (hadoop HadoopConfig) clusterBase() HadoopConfig {
+
+	cluster := hadoop
+
+	cluster.Host = ""
+	cluster.NameNodes = nil
+	cluster.DataNodeMap = nil
+	cluster.Delegation = ""
+
+	if hadoop.Headers != nil {
+		cluster.Headers = make(map[string]string, len(hadoop.Headers))
+		for name, value := range hadoop.Headers {
+			cluster.Headers[name] = value
+		}
+	}
+
+	return cluster
+}
+
+// jsonDuration 挂载表中的时间，与命令行参数一样是 time.ParseDuration 的格式，比如 "30s"
+type jsonDuration struct {
+	value *time.Duration
+}
+
+func (d jsonDuration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\": %s", data)
+	}
+	value, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	*d.value = value
+	return nil
+}
+
+// UnmarshalJSON 解析挂载表中的集群配置，时间类型的字段使用字符串
+func (hadoop *HadoopConfig) UnmarshalJSON(data []byte) error {
+
+	// plain 没有 UnmarshalJSON 方法，外层同名的字段优先
+	type plain HadoopConfig
+	fields := struct {
+		*plain
+		HTTPIdleTimeout       jsonDuration `json:"http_idle_timeout"`
+		HTTPDialTimeout       jsonDuration `json:"http_dial_timeout"`
+		HTTPResponseTimeout   jsonDuration `json:"http_response_timeout"`
+		HTTPMetadataTimeout   jsonDuration `json:"http_metadata_timeout"`
+		HTTPDataTimeout       jsonDuration `json:"http_data_timeout"`
+		NameNodeStatsInterval jsonDuration `json:"namenode_stats_interval"`
+		RetryBaseDelay        jsonDuration `json:"retry_base_delay"`
+		RetryMaxDelay         jsonDuration `json:"retry_max_delay"`
+	}{
+		plain:                 (*plain)(hadoop),
+		HTTPIdleTimeout:       jsonDuration{&hadoop.HTTPIdleTimeout},
+		HTTPDialTimeout:       jsonDuration{&hadoop.HTTPDialTimeout},
+		HTTPResponseTimeout:   jsonDuration{&hadoop.HTTPResponseTimeout},
+		HTTPMetadataTimeout:   jsonDuration{&hadoop.HTTPMetadataTimeout},
+		HTTPDataTimeout:       jsonDuration{&hadoop.HTTPDataTimeout},
+		NameNodeStatsInterval: jsonDuration{&hadoop.NameNodeStatsInterval},
+		RetryBaseDelay:        jsonDuration{&hadoop.RetryBaseDelay},
+		RetryMaxDelay:         jsonDuration{&hadoop.RetryMaxDelay},
+	}
+
+	return json.Unmarshal(data, &fields)
+}
@@ -0,0 +1,201 @@
+package controler
+
+import (
+	"context"
+	"fmt"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"hadoop-fs/fs/model"
+	"net/http"
+	"strconv"
+)
+
+// AclStatus 文件的ACL。与HDFS一样，Entries 中没有owner、other和mask项，它们由 Permission 表示，
+// 有扩展ACL时 Permission 的group位是mask，group项在 Entries 中
+type AclStatus struct {
+	Entries    []string `json:"entries"`
+	Owner      string   `json:"owner"`
+	Group      string   `json:"group"`
+	Permission string   `json:"permission"`
+	StickyBit  bool     `json:"stickyBit"`
+}
+
+// GetAclStatus 获取文件的ACL，ACL功能没有开启或者Hadoop不支持时返回 herr.ErrNotsup
+func (hadoop *HadoopController) GetAclStatus(ctx context.Context, filepath string) (status AclStatus, err error) {
+	resp := AclStatusResp{}
+	err = hadoop.call(ctx, webhdfsRequest{
+		method: http.MethodGet,
+		op:     opGetAclStatus,
+		path:   filepath,
+		errors: map[int]error{400: herr.ErrNotsup},
+	}, &resp)
+
+	if err != nil {
+		return AclStatus{}, err
+	}
+
+	return resp.AclStatus, nil
+}
+
+// SetAcl 替换文件的ACL，aclspec 中只有access或者default项时，另一类保持不变
+func (hadoop *HadoopController) SetAcl(ctx context.Context, filepath, aclspec string) (err error) {
+	err = hadoop.modifyAcl(ctx, opSetAcl, filepath, aclspec)
+
+	return err
+}
+
+// RemoveDefaultAcl 删除目录的default ACL
+func (hadoop *HadoopController) RemoveDefaultAcl(ctx context.Context, filepath string) (err error) {
+	err = hadoop.modifyAcl(ctx, opRemoveDefAcl, filepath, "")
+
+	return err
+}
+
+// RemoveAclEntries 删除ACL项，aclspec 中的项不需要权限，例如 user:alice，其它的项保持不变
+func (hadoop *HadoopController) RemoveAclEntries(ctx context.Context, filepath, aclspec string) (err error) {
+	err = hadoop.modifyAcl(ctx, opRemoveAclEntry, filepath, aclspec)
+
+	return err
+}
+
+// modifyAcl 修改ACL的请求，400 是不支持ACL的Hadoop(2.4之前)或者aclspec不合法
+func (hadoop *HadoopController) modifyAcl(ctx context.Context, op, filepath, aclspec string) error {
+	return hadoop.call(ctx, webhdfsRequest{
+		method: http.MethodPut,
+		op:     op,
+		path:   filepath,
+		params: map[string]string{"aclspec": aclspec},
+		errors: map[int]error{400: herr.ErrNotsup},
+	}, nil)
+}
+
+// logicalAcl 由权限位和保存的ACL项得到完整的ACL，access部分总是有user、group(或者mask)和other项
+func logicalAcl(permission string, entries []model.AclEntry) []model.AclEntry {
+
+	mode, _ := strconv.ParseUint(permission, 8, 16)
+
+	acl := []model.AclEntry{{Type: model.AclUser, Perm: uint32(mode>>6) & 7}}
+	extended := false
+	for _, entry := range entries {
+		if !entry.Default {
+			extended = true
+		}
+	}
+	if extended {
+		acl = append(acl, model.AclEntry{Type: model.AclMask, Perm: uint32(mode>>3) & 7})
+	} else {
+		acl = append(acl, model.AclEntry{Type: model.AclGroup, Perm: uint32(mode>>3) & 7})
+	}
+	acl = append(acl, model.AclEntry{Type: model.AclOther, Perm: uint32(mode) & 7})
+	acl = append(acl, entries...)
+
+	model.SortAcl(acl)
+	return acl
+}
+
+// replaceAcl 与HDFS的SETACL一样按access和default分别替换，spec 中没有的那一类保持不变
+func replaceAcl(acl, spec []model.AclEntry) []model.AclEntry {
+
+	replaced := map[bool]bool{}
+	for _, entry := range spec {
+		replaced[entry.Default] = true
+	}
+
+	result := append([]model.AclEntry(nil), spec...)
+	for _, entry := range acl {
+		if !replaced[entry.Default] {
+			result = append(result, entry)
+		}
+	}
+
+	model.SortAcl(result)
+	return result
+}
+
+// splitAcl 把完整的ACL拆分成权限位和需要保存的ACL项。与HDFS一样每一类都必须有user、group和other项，
+// 有名字的项但是没有mask时，mask是group类权限的并集
+func splitAcl(permission string, acl []model.AclEntry) (string, []model.AclEntry, error) {
+
+	mode, _ := strconv.ParseUint(permission, 8, 16)
+	mode &^= 0777
+
+	entries := make([]model.AclEntry, 0)
+	for _, scope := range []bool{false, true} {
+
+		var base, named []model.AclEntry
+		var mask *model.AclEntry
+		found := map[string]bool{}
+		for i, entry := range acl {
+			switch {
+			case entry.Default != scope:
+			case entry.Type == model.AclMask:
+				mask = &acl[i]
+			case entry.Name != "":
+				named = append(named, entry)
+			default:
+				found[entry.Type] = true
+				base = append(base, entry)
+			}
+		}
+
+		if len(base)+len(named) == 0 && mask == nil {
+			continue
+		}
+		if !found[model.AclUser] || !found[model.AclGroup] || !found[model.AclOther] || len(base) != 3 {
+			return "", nil, fmt.Errorf("invalid ACL: the user, group and other entries are required: %w", herr.ErrInvalid)
+		}
+
+		if mask == nil && len(named) > 0 {
+			union := model.AclEntry{Default: scope, Type: model.AclMask}
+			for _, entry := range named {
+				union.Perm |= entry.Perm
+			}
+			for _, entry := range base {
+				if entry.Type == model.AclGroup {
+					union.Perm |= entry.Perm
+				}
+			}
+			mask = &union
+		}
+
+		if scope {
+			// default ACL全部保存
+			entries = append(entries, base...)
+			entries = append(entries, named...)
+			if mask != nil {
+				entries = append(entries, *mask)
+			}
+			continue
+		}
+
+		for _, entry := range base {
+			switch entry.Type {
+			case model.AclUser:
+				mode |= uint64(entry.Perm) << 6
+			case model.AclOther:
+				mode |= uint64(entry.Perm)
+			case model.AclGroup:
+				if mask == nil {
+					mode |= uint64(entry.Perm) << 3
+				} else {
+					entries = append(entries, entry)
+				}
+			}
+		}
+		if mask != nil {
+			mode |= uint64(mask.Perm) << 3
+			entries = append(entries, named...)
+		}
+	}
+
+	model.SortAcl(entries)
+	return strconv.FormatUint(mode, 8), entries, nil
+}
+
+// aclEntries 把ACL项转换成 AclStatus 中的格式
+func aclEntries(entries []model.AclEntry) []string {
+	specs := make([]string, len(entries))
+	for i, entry := range entries {
+		specs[i] = entry.String()
+	}
+	return specs
+}
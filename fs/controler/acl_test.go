@@ -0,0 +1,133 @@
+package controler
+
+import (
+	"context"
+	"errors"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"reflect"
+	"testing"
+)
+
+// aclBackend 同时在假的WebHDFS和内存后端上测试，两者的ACL行为应该一致
+type aclBackend interface {
+	GetAclStatus(ctx context.Context, filepath string) (AclStatus, error)
+	SetAcl(ctx context.Context, filepath, aclspec string) error
+	RemoveDefaultAcl(ctx context.Context, filepath string) error
+	RemoveAclEntries(ctx context.Context, filepath, aclspec string) error
+}
+
+func TestSetAcl(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddDir("/dir")
+
+	memory := &MemoryController{}
+	memory.Init(testUser)
+	if _, err := memory.MakeDir(context.Background(), "/dir", "755"); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, backend := range map[string]aclBackend{"webhdfs": hadoop, "memory": memory} {
+		ctx := context.Background()
+
+		if err := backend.SetAcl(ctx, "/dir", "user::rwx,user:bob:rw-,group::r-x,other::---"); err != nil {
+			t.Fatalf("%s: SetAcl: %v", name, err)
+		}
+		status, err := backend.GetAclStatus(ctx, "/dir")
+		// 没有指定mask时，mask是group类权限的并集
+		if err != nil || status.Permission != "770" || !reflect.DeepEqual(status.Entries, []string{"user:bob:rw-", "group::r-x"}) {
+			t.Errorf("%s: GetAclStatus: got %+v, %v", name, status, err)
+		}
+
+		// 只有default项时access ACL保持不变
+		if err = backend.SetAcl(ctx, "/dir", "default:user::rwx,default:group::r-x,default:other::---"); err != nil {
+			t.Fatalf("%s: SetAcl default: %v", name, err)
+		}
+		status, _ = backend.GetAclStatus(ctx, "/dir")
+		want := []string{"user:bob:rw-", "group::r-x", "default:user::rwx", "default:group::r-x", "default:other::---"}
+		if !reflect.DeepEqual(status.Entries, want) {
+			t.Errorf("%s: entries after SetAcl default: got %v, want %v", name, status.Entries, want)
+		}
+
+		if err = backend.RemoveDefaultAcl(ctx, "/dir"); err != nil {
+			t.Fatalf("%s: RemoveDefaultAcl: %v", name, err)
+		}
+		// 只有基本项时删除扩展ACL
+		if err = backend.SetAcl(ctx, "/dir", "user::rwx,group::r--,other::r--"); err != nil {
+			t.Fatalf("%s: SetAcl minimal: %v", name, err)
+		}
+		status, _ = backend.GetAclStatus(ctx, "/dir")
+		if status.Permission != "744" || len(status.Entries) != 0 {
+			t.Errorf("%s: GetAclStatus after minimal SetAcl: got %+v", name, status)
+		}
+
+		if err = backend.SetAcl(ctx, "/dir", "user:bob:rw-"); err == nil {
+			t.Errorf("%s: SetAcl without base entries: expected error", name)
+		}
+		if _, err = backend.GetAclStatus(ctx, "/missing"); !errors.Is(err, herr.ErrNoFound) {
+			t.Errorf("%s: GetAclStatus of missing file: got %v, want ErrNoFound", name, err)
+		}
+	}
+
+	if file, _ := memory.GetFileStatus(context.Background(), "/dir"); file.AclBit {
+		t.Errorf("memory: aclBit should be cleared")
+	}
+}
+
+func TestRemoveAclEntries(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddDir("/dir")
+
+	memory := &MemoryController{}
+	memory.Init(testUser)
+	if _, err := memory.MakeDir(context.Background(), "/dir", "755"); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, backend := range map[string]aclBackend{"webhdfs": hadoop, "memory": memory} {
+		ctx := context.Background()
+
+		spec := "user::rwx,user:bob:rwx,group::r--,group:analysts:r--,mask::rwx,other::r--,default:user::rwx,default:group::r-x,default:other::---"
+		if err := backend.SetAcl(ctx, "/dir", spec); err != nil {
+			t.Fatalf("%s: SetAcl: %v", name, err)
+		}
+
+		// 还有有名字的项时重新计算mask
+		if err := backend.RemoveAclEntries(ctx, "/dir", "user:bob"); err != nil {
+			t.Fatalf("%s: RemoveAclEntries: %v", name, err)
+		}
+		status, err := backend.GetAclStatus(ctx, "/dir")
+		want := []string{"group::r--", "group:analysts:r--", "default:user::rwx", "default:group::r-x", "default:other::---"}
+		if err != nil || status.Permission != "744" || !reflect.DeepEqual(status.Entries, want) {
+			t.Errorf("%s: GetAclStatus after removing user:bob: got %+v, %v", name, status, err)
+		}
+
+		// 删除所有有名字的access项，权限位的group恢复为group项的权限，default ACL不变
+		if err = backend.RemoveAclEntries(ctx, "/dir", "group:analysts"); err != nil {
+			t.Fatalf("%s: RemoveAclEntries: %v", name, err)
+		}
+		status, err = backend.GetAclStatus(ctx, "/dir")
+		want = []string{"default:user::rwx", "default:group::r-x", "default:other::---"}
+		if err != nil || status.Permission != "744" || !reflect.DeepEqual(status.Entries, want) {
+			t.Errorf("%s: GetAclStatus after RemoveAclEntries: got %+v, %v", name, status, err)
+		}
+
+		if err = backend.RemoveAclEntries(ctx, "/dir", "group::"); err == nil {
+			t.Errorf("%s: RemoveAclEntries of the group entry: expected error", name)
+		}
+	}
+
+	ctx := context.Background()
+	if file, _ := hadoop.GetFileStatus(ctx, "/dir"); !file.AclBit {
+		t.Errorf("webhdfs: aclBit should be kept for the default ACL")
+	}
+
+	server.Disable(opGetAclStatus)
+	if _, err := hadoop.GetAclStatus(ctx, "/dir"); !errors.Is(err, herr.ErrNotsup) {
+		t.Errorf("GetAclStatus not supported: got %v, want ErrNotsup", err)
+	}
+	server.Fail(opSetAcl, 403, "AclException", "org.apache.hadoop.hdfs.protocol.AclException",
+		"The ACL operation has been rejected.  Support for ACLs has been disabled by setting dfs.namenode.acls.enabled to false.")
+	if err := hadoop.SetAcl(ctx, "/dir", "user::rwx,group::r--,other::r--"); !errors.Is(err, herr.ErrNotsup) {
+		t.Errorf("SetAcl with ACLs disabled: got %v, want ErrNotsup", err)
+	}
+}
@@ -0,0 +1,13 @@
+package controler
+
+import (
+	"net/http"
+)
+
+// Authenticator 为发往WebHDFS的请求添加认证信息
+type Authenticator interface {
+	// Authenticate 在请求发送前调用，为请求添加认证信息
+	Authenticate(req *http.Request) error
+	// Refresh 服务端返回401时调用，重新获取认证信息
+	Refresh() error
+}
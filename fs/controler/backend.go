@@ -0,0 +1,197 @@
+package controler
+
+import (
+	"context"
+	"hadoop-fs/fs/config"
+	"hadoop-fs/fs/model"
+	"net/http"
+)
+
+// 可选的存储后端
+const (
+	BackendHadoop = "hadoop"
+	BackendMemory = "memory"
+)
+
+// Backend 存储后端的接口，FUSE层的所有文件操作都通过它完成
+//
+// 返回的 model.FileModel 与 WebHDFS 接口的格式一致，需要调用 AdjustNormal 转换，
+// ctx 在FUSE请求被INTERRUPT时取消
+type Backend interface {
+	// List 列出目录下 startAfter 之后的文件，remain 为剩余未返回的数量
+	List(ctx context.Context, path, startAfter string) (fileList []model.FileModel, remain int, err error)
+	// GetHomeDirectory 获取当前用户的home目录
+	GetHomeDirectory(ctx context.Context) (home string, err error)
+	// GetFileStatus 获取文件信息
+	GetFileStatus(ctx context.Context, filePath string) (file model.FileModel, err error)
+	// Read 读取文件内容，超出文件末尾时返回 herr.ErrEOF
+	Read(ctx context.Context, filePath string, offset uint64, length uint32, buffersize int) (content []byte, err error)
+	// MakeDir 创建目录
+	MakeDir(ctx context.Context, pathname, permission string) (result bool, err error)
+	// Create 创建空文件
+	Create(ctx context.Context, filepath, permission string) (err error)
+	// ModificationTime 设置文件Mtime和Atime，-1表示不变
+	ModificationTime(ctx context.Context, filepath string, mtime, atime int64) (err error)
+	// AppendFile 追加文件内容
+	AppendFile(ctx context.Context, filepath string, content []byte) (err error)
+	// TruncateFile Truncate 文件
+	TruncateFile(ctx context.Context, filepath string, newlength int64) (result bool, err error)
+	// Delete 删除文件或者目录
+	Delete(ctx context.Context, filepath string) (result bool, err error)
+	// SetPermission 设置文件权限
+	SetPermission(ctx context.Context, filepath, permission string) (err error)
+	// GetAclStatus 获取文件的ACL
+	GetAclStatus(ctx context.Context, filepath string) (status AclStatus, err error)
+	// SetAcl 替换文件的ACL，aclspec 中只有access或者default项时，另一类保持不变
+	SetAcl(ctx context.Context, filepath, aclspec string) (err error)
+	// RemoveDefaultAcl 删除目录的default ACL
+	RemoveDefaultAcl(ctx context.Context, filepath string) (err error)
+	// RemoveAclEntries 删除ACL项，aclspec 中的项不需要权限，例如 user:alice
+	RemoveAclEntries(ctx context.Context, filepath, aclspec string) (err error)
+	// SetOwner 设置文件的owner和group，为空的不修改
+	SetOwner(ctx context.Context, filepath, owner, group string) (err error)
+	// CheckAccess 检查对文件是否有 fsaction(比如 "rw-")的权限，没有权限时返回 herr.ErrAccess
+	CheckAccess(ctx context.Context, filepath, fsaction string) (err error)
+	// Rename 文件重命名
+	Rename(ctx context.Context, src, dest string) (result bool, err error)
+	// CreateSymlink 创建软连接
+	CreateSymlink(ctx context.Context, src, link string) (err error)
+	// Setxattr 设置文件额外属性，flag 为 CREATE 或者 REPLACE
+	Setxattr(ctx context.Context, filepath, name, value, flag string) (err error)
+	// Getxattr 获取指定名字的文件额外属性值
+	Getxattr(ctx context.Context, filepath, name string) (value string, err error)
+	// Listxattr 列出文件所有的额外属性
+	Listxattr(ctx context.Context, filepath string) (attrs []Xattr, err error)
+	// Removexattr 删除文件额外属性
+	Removexattr(ctx context.Context, filepath, name string) (err error)
+	// GetStatus 获取 path 所在文件系统的容量，不支持时返回 herr.ErrNotsup
+	GetStatus(ctx context.Context, path string) (status FsStatus, err error)
+	// GetQuotaUsage 获取目录的配额和使用量
+	GetQuotaUsage(ctx context.Context, path string) (usage QuotaUsage, err error)
+}
+
+var _ Backend = &HadoopController{}
+var _ Backend = &MemoryController{}
+var _ Backend = &MountTable{}
+var _ Backend = &SymlinkEmulation{}
+
+// NewBackend 根据配置创建对应的存储后端，配置了挂载表时返回 MountTable，
+// 开启 symlink_emulation 时由 SymlinkEmulation 包装
+func NewBackend(cg config.Config) (Backend, error) {
+
+	var backend Backend
+	var err error
+	if len(cg.Mounts) > 0 {
+		backend, err = newMountTable(cg)
+	} else {
+		backend, err = newBackend(cg.Backend, cg.Hadoop)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !cg.SymlinkEmulation {
+		return backend, nil
+	}
+
+	emulation := &SymlinkEmulation{}
+	emulation.Init(backend)
+	return emulation, nil
+}
+
+// newBackend 创建一个集群的存储后端
+func newBackend(kind string, hadoopConfig config.HadoopConfig) (Backend, error) {
+
+	switch kind {
+	case BackendMemory:
+		memory := &MemoryController{}
+		memory.Init(hadoopConfig.Username)
+		return memory, nil
+	default:
+		hadoop := &HadoopController{}
+		hadoop.Init(hadoopConfig.IsSSL, hadoopConfig.Host, hadoopConfig.Port, hadoopConfig.Username)
+		if len(hadoopConfig.NameNodes) > 1 {
+			hadoop.SetNameNodes(hadoopConfig.NameNodes)
+		}
+		if err := hadoop.SetGateway(hadoopConfig.Gateway); err != nil {
+			return nil, err
+		}
+		if hadoopConfig.BasePath != "" {
+			hadoop.SetBasePath(hadoopConfig.BasePath)
+		}
+		headers := http.Header{}
+		for name, value := range hadoopConfig.Headers {
+			headers.Set(name, value)
+		}
+		hadoop.SetHeaders(headers)
+		hadoop.SetCSRFHeader(hadoopConfig.CSRFHeader)
+		if len(hadoopConfig.DataNodeMap) > 0 {
+			hadoop.SetDataNodeMap(hadoopConfig.DataNodeMap)
+		}
+
+		hadoop.SetHTTPOptions(HTTPOptions{
+			MaxIdleConnsPerHost:   hadoopConfig.HTTPMaxIdleConns,
+			MaxConnsPerHost:       hadoopConfig.HTTPMaxConns,
+			IdleConnTimeout:       hadoopConfig.HTTPIdleTimeout,
+			DialTimeout:           hadoopConfig.HTTPDialTimeout,
+			ResponseHeaderTimeout: hadoopConfig.HTTPResponseTimeout,
+			MetadataTimeout:       hadoopConfig.HTTPMetadataTimeout,
+			DataTimeout:           hadoopConfig.HTTPDataTimeout,
+		})
+		hadoop.SetConcurrency(hadoopConfig.NameNodeConcurrency)
+		hadoop.SetStatsInterval(hadoopConfig.NameNodeStatsInterval)
+		hadoop.SetRetryOptions(RetryOptions{
+			MaxRetries: hadoopConfig.RetryMax,
+			BaseDelay:  hadoopConfig.RetryBaseDelay,
+			MaxDelay:   hadoopConfig.RetryMaxDelay,
+		})
+
+		if hadoopConfig.IsSSL {
+			tlsConfig, err := NewTLSConfig(hadoopConfig.SSLCACert, hadoopConfig.SSLClientCert, hadoopConfig.SSLClientKey, hadoopConfig.SSLInsecure)
+			if err != nil {
+				return nil, err
+			}
+			hadoop.SetTLSConfig(tlsConfig, hadoopConfig.SSLServerName)
+		}
+
+		if hadoopConfig.IsKerberos() {
+			krb := &KerberosAuthenticator{}
+			err := krb.Init(hadoopConfig.KerberosKrb5Conf, hadoopConfig.KerberosPrincipal, hadoopConfig.KerberosKeytab,
+				hadoopConfig.KerberosCCache, hadoopConfig.KerberosSPN)
+			if err != nil {
+				return nil, err
+			}
+			hadoop.SetAuthenticator(krb)
+		}
+
+		if hadoopConfig.IsBasic() {
+			basic := &BasicAuthenticator{}
+			if err := basic.Init(hadoopConfig.BasicUser, hadoopConfig.BasicPassword, hadoopConfig.BasicPasswordFile); err != nil {
+				return nil, err
+			}
+			hadoop.SetAuthenticator(basic)
+		}
+
+		if hadoopConfig.IsOAuth2() {
+			oauth := &OAuth2Authenticator{}
+			err := oauth.Init(hadoopConfig.OAuth2TokenURL, hadoopConfig.OAuth2ClientID,
+				hadoopConfig.OAuth2ClientSecret, hadoopConfig.OAuth2ClientSecretFile,
+				hadoopConfig.OAuth2RefreshToken, hadoopConfig.OAuth2RefreshTokenFile, hadoopConfig.OAuth2Scope)
+			if err != nil {
+				return nil, err
+			}
+			hadoop.SetAuthenticator(oauth)
+		}
+
+		if hadoopConfig.Delegation != "" {
+			hadoop.SetDelegation(hadoopConfig.Delegation)
+			hadoop.StartDelegationRenewer()
+		} else if hadoopConfig.DelegationFetch {
+			if err := hadoop.FetchDelegation(hadoopConfig.DelegationRenewer); err != nil {
+				return nil, err
+			}
+			hadoop.StartDelegationRenewer()
+		}
+
+		return hadoop, nil
+	}
+}
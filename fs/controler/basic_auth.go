@@ -0,0 +1,97 @@
+package controler
+
+import (
+	"errors"
+	"fmt"
+	"hadoop-fs/fs/logger"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// BasicAuthenticator 使用HTTP Basic认证，例如通过Apache Knox访问WebHDFS时
+type BasicAuthenticator struct {
+	lock sync.Mutex
+
+	username string
+	password string
+
+	// passwordFile 不为空时从文件读取密码，服务端返回401时重新读取，密码更换后不需要重新挂载
+	passwordFile string
+}
+
+// Init 初始化函数，passwordFile 不为空时从文件读取密码，否则使用 password
+func (basic *BasicAuthenticator) Init(username, password, passwordFile string) error {
+
+	if username == "" {
+		return errors.New("basic auth username is required")
+	}
+
+	basic.username = username
+	basic.password = password
+	basic.passwordFile = passwordFile
+
+	basic.lock.Lock()
+	defer basic.lock.Unlock()
+
+	return basic.load()
+}
+
+// load 从文件读取密码，调用前需要持有锁
+func (basic *BasicAuthenticator) load() error {
+
+	if basic.passwordFile == "" {
+		if basic.password == "" {
+			return errors.New("basic auth password is required")
+		}
+		return nil
+	}
+
+	password, err := readSecretFile("basic auth password", basic.passwordFile)
+	if err != nil {
+		return err
+	}
+	if basic.password != "" && basic.password != password {
+		logger.Info.Printf("basic auth password of [%s] is changed\n", basic.username)
+	}
+	basic.password = password
+
+	return nil
+}
+
+// Authenticate 添加 Authorization: Basic 请求头
+func (basic *BasicAuthenticator) Authenticate(req *http.Request) error {
+
+	basic.lock.Lock()
+	defer basic.lock.Unlock()
+
+	req.SetBasicAuth(basic.username, basic.password)
+	return nil
+}
+
+// Refresh 重新从文件读取密码
+func (basic *BasicAuthenticator) Refresh() error {
+
+	basic.lock.Lock()
+	defer basic.lock.Unlock()
+
+	return basic.load()
+}
+
+// readSecretFile 读取保存密码等的文件，what 是错误信息中的名字
+func readSecretFile(what, path string) (string, error) {
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read %s file [%s] failed: %v", what, path, err)
+	}
+
+	// 只去掉行尾的换行，密码本身可能有空格
+	secret := strings.TrimRight(string(buf), "\r\n")
+	if secret == "" {
+		return "", fmt.Errorf("%s file [%s] is empty", what, path)
+	}
+
+	return secret, nil
+}
@@ -0,0 +1,98 @@
+package controler
+
+import (
+	"context"
+	"errors"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"hadoop-fs/fs/controler/webhdfstest"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func newKnoxTestController(t *testing.T) (*HadoopController, *webhdfstest.Server, *webhdfstest.Knox, string) {
+	t.Helper()
+
+	server := webhdfstest.NewServer()
+	t.Cleanup(server.Close)
+	knox := webhdfstest.NewKnox(server, "default", "alice", "secret")
+	t.Cleanup(knox.Close)
+
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	if err := ioutil.WriteFile(passwordFile, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	u, _ := url.Parse(knox.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	hadoop := &HadoopController{}
+	hadoop.Init(false, u.Hostname(), port, "")
+	hadoop.SetBasePath(knox.BasePath() + "/")
+
+	basic := &BasicAuthenticator{}
+	if err := basic.Init("alice", "", passwordFile); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	hadoop.SetAuthenticator(basic)
+
+	return hadoop, server, knox, passwordFile
+}
+
+func TestKnox(t *testing.T) {
+	ctx := context.Background()
+	hadoop, server, knox, _ := newKnoxTestController(t)
+	server.AddDir("/dir")
+
+	if err := hadoop.Create(ctx, "/dir/file", "644"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := hadoop.AppendFile(ctx, "/dir/file", []byte("hello")); err != nil {
+		t.Fatalf("AppendFile: %v", err)
+	}
+	if content, err := hadoop.Read(ctx, "/dir/file", 0, 100, 0); err != nil || string(content) != "hello" {
+		t.Errorf("Read: got %q, err %v", content, err)
+	}
+	if files, _, err := hadoop.List(ctx, "/dir", ""); err != nil || len(files) != 1 {
+		t.Errorf("List: got %+v, err %v", files, err)
+	}
+	if status, _ := server.Status("/dir/file"); status.Owner != "alice" {
+		t.Errorf("owner = %q, want alice", status.Owner)
+	}
+
+	// DataNode的请求也经过网关
+	for _, req := range server.Requests() {
+		if req.Query.Get("noredirect") != "" {
+			t.Errorf("%s: noredirect should not be sent through gateway", req.Op)
+		}
+	}
+	if got := knox.Requests(); got != 7 {
+		t.Errorf("gateway requests = %d, want 7", got)
+	}
+}
+
+func TestBasicAuthPasswordFile(t *testing.T) {
+	ctx := context.Background()
+	hadoop, _, knox, passwordFile := newKnoxTestController(t)
+
+	// 密码修改后，返回401时重新读取密码文件
+	knox.SetPassword("changed")
+	if err := ioutil.WriteFile(passwordFile, []byte("changed"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hadoop.GetFileStatus(ctx, "/"); err != nil {
+		t.Fatalf("GetFileStatus after password changed: %v", err)
+	}
+
+	knox.SetPassword("wrong")
+	if _, err := hadoop.GetFileStatus(ctx, "/"); !errors.Is(err, herr.ErrAuth) {
+		t.Errorf("GetFileStatus with wrong password: got err %v, want ErrAuth", err)
+	}
+
+	basic := &BasicAuthenticator{}
+	if err := basic.Init("alice", "", filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("Init with missing password file: expected error")
+	}
+}
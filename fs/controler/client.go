@@ -0,0 +1,123 @@
+package controler
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// HTTPOptions 与WebHDFS之间HTTP连接的参数，为0时使用默认值
+type HTTPOptions struct {
+	// MaxIdleConnsPerHost 每个NameNode或DataNode保持的空闲连接数
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost 每个NameNode或DataNode的最大连接数，0表示不限制
+	MaxConnsPerHost int
+	// IdleConnTimeout 空闲连接保持的时间
+	IdleConnTimeout time.Duration
+	// DialTimeout 建立连接的超时时间
+	DialTimeout time.Duration
+	// ResponseHeaderTimeout 发送请求后等待响应头的超时时间
+	ResponseHeaderTimeout time.Duration
+	// MetadataTimeout 元数据操作(GETFILESTATUS、LISTSTATUS_BATCH、MKDIRS等)的总超时时间
+	MetadataTimeout time.Duration
+	// DataTimeout 读写数据操作(OPEN、CREATE、APPEND)的总超时时间
+	DataTimeout time.Duration
+}
+
+// DefaultHTTPOptions 默认的HTTP连接参数
+var DefaultHTTPOptions = HTTPOptions{
+	MaxIdleConnsPerHost:   16,
+	MaxConnsPerHost:       0,
+	IdleConnTimeout:       90 * time.Second,
+	DialTimeout:           10 * time.Second,
+	ResponseHeaderTimeout: 60 * time.Second,
+	MetadataTimeout:       60 * time.Second,
+	DataTimeout:           10 * time.Minute,
+}
+
+// withDefault 为0的参数使用默认值
+func (opts HTTPOptions) withDefault() HTTPOptions {
+	if opts.MaxIdleConnsPerHost <= 0 {
+		opts.MaxIdleConnsPerHost = DefaultHTTPOptions.MaxIdleConnsPerHost
+	}
+	if opts.MaxConnsPerHost < 0 {
+		opts.MaxConnsPerHost = DefaultHTTPOptions.MaxConnsPerHost
+	}
+	if opts.IdleConnTimeout <= 0 {
+		opts.IdleConnTimeout = DefaultHTTPOptions.IdleConnTimeout
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = DefaultHTTPOptions.DialTimeout
+	}
+	if opts.ResponseHeaderTimeout <= 0 {
+		opts.ResponseHeaderTimeout = DefaultHTTPOptions.ResponseHeaderTimeout
+	}
+	if opts.MetadataTimeout <= 0 {
+		opts.MetadataTimeout = DefaultHTTPOptions.MetadataTimeout
+	}
+	if opts.DataTimeout <= 0 {
+		opts.DataTimeout = DefaultHTTPOptions.DataTimeout
+	}
+	return opts
+}
+
+// SetHTTPOptions 设置HTTP连接的参数
+func (hadoop *HadoopController) SetHTTPOptions(opts HTTPOptions) {
+	hadoop.httpOptions = opts.withDefault()
+	hadoop.buildClient()
+}
+
+// buildClient 根据HTTP参数和TLS配置重新创建client，发往NameNode和DataNode的请求都使用这个client
+func (hadoop *HadoopController) buildClient() {
+
+	opts := hadoop.httpOptions
+
+	dialer := &net.Dialer{
+		Timeout:   opts.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	hadoop.transport = &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       opts.MaxConnsPerHost,
+		IdleConnTimeout:       opts.IdleConnTimeout,
+		TLSHandshakeTimeout:   opts.DialTimeout,
+		ResponseHeaderTimeout: opts.ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+		TLSClientConfig:       hadoop.tlsConfig,
+	}
+
+	var transport http.RoundTripper = hadoop.transport
+
+	if hadoop.tlsConfig != nil && hadoop.tlsServerName != "" {
+		namenode := hadoop.transport.Clone()
+		namenode.TLSClientConfig = hadoop.tlsConfig.Clone()
+		namenode.TLSClientConfig.ServerName = hadoop.tlsServerName
+
+		transport = &namenodeTransport{
+			hadoop:   hadoop,
+			namenode: namenode,
+			datanode: hadoop.transport,
+		}
+	}
+
+	hadoop.client = &http.Client{Transport: transport, CheckRedirect: hadoop.checkRedirect}
+}
+
+// opContext 为一个op的请求设置超时时间，读写数据的操作使用 DataTimeout
+func (hadoop *HadoopController) opContext(ctx context.Context, op string) (context.Context, context.CancelFunc) {
+
+	timeout := hadoop.httpOptions.MetadataTimeout
+
+	switch op {
+	case opRead, opCreate, opAppend:
+		timeout = hadoop.httpOptions.DataTimeout
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
@@ -0,0 +1,53 @@
+package controler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMetadataTimeout(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/file", []byte("data"))
+
+	hadoop.SetHTTPOptions(HTTPOptions{MetadataTimeout: 100 * time.Millisecond})
+
+	release := server.Hang("GETFILESTATUS")
+	t.Cleanup(release)
+
+	start := time.Now()
+	_, err := hadoop.GetFileStatus(context.Background(), "/file")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetFileStatus: got err %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("GetFileStatus: returned after %s", elapsed)
+	}
+
+	// 读数据使用 DataTimeout，不受 MetadataTimeout 影响
+	if content, err := hadoop.Read(context.Background(), "/file", 0, 100, 0); err != nil || string(content) != "data" {
+		t.Errorf("Read: got %q, err %v", content, err)
+	}
+}
+
+func TestCancelRequest(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddDir("/dir")
+
+	release := server.Hang("LISTSTATUS_BATCH")
+	t.Cleanup(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, _, err := hadoop.List(ctx, "/dir", "")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("List: got err %v, want Canceled", err)
+	}
+
+	release()
+	if _, _, err = hadoop.List(context.Background(), "/dir", ""); err != nil {
+		t.Errorf("List after release: %v", err)
+	}
+}
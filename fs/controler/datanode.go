@@ -0,0 +1,133 @@
+package controler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hadoop-fs/fs/logger"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+)
+
+// DataNodeError 发往DataNode的请求失败，与NameNode返回的错误区分开
+type DataNodeError struct {
+	Op   string
+	Addr string // DataNode的地址(host:port)，改写之后的
+	Err  error
+}
+
+func (e *DataNodeError) Error() string {
+	return fmt.Sprintf("DataNode [%s] %s: %v", e.Addr, e.Op, e.Err)
+}
+
+// Unwrap 返回DataNode的错误，比如 OPEN 超出文件末尾的 ErrEOF
+func (e *DataNodeError) Unwrap() error {
+	return e.Err
+}
+
+// redirectLocation NameNode 对 noredirect=true 返回的数据节点地址(Hadoop 3)
+type redirectLocation struct {
+	Location string `json:"Location"`
+}
+
+// SetDataNodeMap 设置DataNode地址的改写规则，DataNode使用客户端无法解析的内部地址时使用：
+// key 和 value 可以是 host 或者 host:port，value 没有端口时只替换host
+func (hadoop *HadoopController) SetDataNodeMap(rewrites map[string]string) {
+
+	datanodeMap := make(map[string]string, len(rewrites))
+	for from, to := range rewrites {
+		datanodeMap[from] = to
+	}
+
+	hadoop.datanodeMap = datanodeMap
+}
+
+// rewriteDataNode 按 SetDataNodeMap 的规则改写DataNode的地址，先匹配 host:port 再匹配 host
+func (hadoop *HadoopController) rewriteDataNode(location *url.URL) {
+
+	if len(hadoop.datanodeMap) == 0 {
+		return
+	}
+
+	to, ok := hadoop.datanodeMap[location.Host]
+	if !ok {
+		if to, ok = hadoop.datanodeMap[location.Hostname()]; !ok {
+			return
+		}
+	}
+
+	if _, _, err := net.SplitHostPort(to); err != nil && location.Port() != "" {
+		// 只替换host，保留原来的端口
+		to = net.JoinHostPort(to, location.Port())
+	}
+
+	logger.Trace.Printf("datanode: rewrite [%s] to [%s]\n", location.Host, to)
+	location.Host = to
+}
+
+// isDataOp 读写数据的op，WebHDFS 会把请求重定向到DataNode
+func isDataOp(op string) bool {
+	return op == opRead || op == opCreate || op == opAppend
+}
+
+// dataLocation 取出NameNode返回的DataNode地址：307重定向的Location，
+// 或者 noredirect=true 时200响应中的Location，不是重定向时返回nil，响应留给调用者处理
+func (hadoop *HadoopController) dataLocation(resp *http.Response) (*url.URL, error) {
+
+	var location string
+
+	switch resp.StatusCode {
+	case http.StatusTemporaryRedirect, http.StatusFound, http.StatusSeeOther, http.StatusPermanentRedirect:
+		location = resp.Header.Get("Location")
+
+	case http.StatusOK:
+		// HttpFS 的OPEN会直接返回文件内容，只有JSON才是重定向的地址
+		mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if mediaType != "application/json" {
+			return nil, nil
+		}
+
+		buf, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = ioutil.NopCloser(bytes.NewReader(buf))
+		if err != nil {
+			return nil, err
+		}
+
+		redirect := redirectLocation{}
+		if json.Unmarshal(buf, &redirect) != nil {
+			return nil, nil
+		}
+		location = redirect.Location
+	}
+
+	if location == "" {
+		return nil, nil
+	}
+
+	target, err := resp.Request.URL.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redirect location [%s]: %v", location, err)
+	}
+
+	// HttpFS 把CREATE和APPEND重定向到带data=true的自己
+	if hadoop.gateway == GatewayAuto && target.Query().Get("data") == "true" &&
+		atomic.CompareAndSwapInt32(&hadoop.httpfs, 0, 1) {
+		logger.Info.Printf("gateway: [%s] is HttpFS, upload data with data=true\n", target.Host)
+	}
+
+	return target, nil
+}
+
+// sendData 发送重定向后的数据请求：重定向回NameNode自己时(HttpFS)需要认证，
+// 发往DataNode时由URL中的delegation参数认证，不能带上NameNode的认证信息
+func (hadoop *HadoopController) sendData(req *http.Request) (*http.Response, error) {
+	if hadoop.isNameNode(req.URL.Host) {
+		return hadoop.send(req)
+	}
+	return hadoop.client.Do(req)
+}
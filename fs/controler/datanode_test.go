@@ -0,0 +1,127 @@
+package controler
+
+import (
+	"context"
+	"errors"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+func TestDataNodeRedirect(t *testing.T) {
+	for _, hadoop2 := range []bool{false, true} {
+		t.Run("hadoop2="+strconv.FormatBool(hadoop2), func(t *testing.T) {
+			ctx := context.Background()
+			hadoop, server := newTestController(t)
+			server.IgnoreNoRedirect = hadoop2
+
+			if err := hadoop.Create(ctx, "/file", "644"); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			if err := hadoop.AppendFile(ctx, "/file", []byte("hello")); err != nil {
+				t.Fatalf("AppendFile: %v", err)
+			}
+			if content, err := hadoop.Read(ctx, "/file", 1, 3, 0); err != nil || string(content) != "ell" {
+				t.Errorf("Read: got %q, err %v", content, err)
+			}
+
+			for _, req := range server.Requests() {
+				if req.DataNode {
+					continue
+				}
+				if req.Query.Get("noredirect") != "true" {
+					t.Errorf("%s: NameNode request without noredirect=true", req.Op)
+				}
+			}
+			for _, op := range []string{"CREATE", "APPEND", "OPEN"} {
+				if req, ok := server.LastRequest(op); !ok || !req.DataNode {
+					t.Errorf("%s: last request should be sent to DataNode", op)
+				}
+			}
+		})
+	}
+}
+
+func TestDataNodeMap(t *testing.T) {
+	ctx := context.Background()
+	hadoop, server := newTestController(t)
+	hadoop.SetRetryOptions(RetryOptions{MaxRetries: 0})
+	server.AddFile("/file", []byte("data"))
+
+	dn, _ := url.Parse(server.DataNode.URL)
+
+	// 没有改写时连接不上DataNode
+	server.DataNodeAddr = "127.0.0.1:1"
+	_, err := hadoop.Read(ctx, "/file", 0, 10, 0)
+	var dnErr *DataNodeError
+	if !errors.As(err, &dnErr) || dnErr.Addr != "127.0.0.1:1" || dnErr.Op != opRead {
+		t.Fatalf("Read unreachable DataNode: got err %v, want DataNodeError", err)
+	}
+
+	hadoop.SetDataNodeMap(map[string]string{"127.0.0.1:1": dn.Host})
+	if content, err := hadoop.Read(ctx, "/file", 0, 10, 0); err != nil || string(content) != "data" {
+		t.Errorf("Read with host:port rewrite: got %q, err %v", content, err)
+	}
+
+	// 只改写host，保留端口
+	server.DataNodeAddr = net.JoinHostPort("datanode.internal", dn.Port())
+	hadoop.SetDataNodeMap(map[string]string{"datanode.internal": dn.Hostname()})
+	if err := hadoop.AppendFile(ctx, "/file", []byte("more")); err != nil {
+		t.Errorf("AppendFile with host rewrite: %v", err)
+	}
+	if content, _ := server.Content("/file"); string(content) != "datamore" {
+		t.Errorf("content = %q, want %q", content, "datamore")
+	}
+}
+
+func TestRewriteDataNode(t *testing.T) {
+	hadoop := &HadoopController{}
+	hadoop.SetDataNodeMap(map[string]string{
+		"dn1.internal:9864": "127.0.0.1:19864",
+		"dn2.internal":      "10.0.0.2",
+		"dn3.internal":      "10.0.0.3:1022",
+	})
+
+	cases := map[string]string{
+		"dn1.internal:9864": "127.0.0.1:19864",
+		"dn1.internal:9865": "dn1.internal:9865",
+		"dn2.internal:9864": "10.0.0.2:9864",
+		"dn3.internal:9864": "10.0.0.3:1022",
+		"dn4.internal:9864": "dn4.internal:9864",
+	}
+	for from, want := range cases {
+		location := &url.URL{Scheme: "http", Host: from}
+		hadoop.rewriteDataNode(location)
+		if location.Host != want {
+			t.Errorf("rewrite %s: got %s, want %s", from, location.Host, want)
+		}
+	}
+}
+
+func TestDataNodeError(t *testing.T) {
+	ctx := context.Background()
+	hadoop, server := newTestController(t)
+	server.AddFile("/file", []byte("data"))
+
+	// DataNode返回的错误
+	server.FailDataNode("APPEND", http.StatusForbidden, "AccessControlException",
+		"org.apache.hadoop.security.AccessControlException", "Permission denied")
+	err := hadoop.AppendFile(ctx, "/file", []byte("more"))
+	var dnErr *DataNodeError
+	if !errors.As(err, &dnErr) || !errors.Is(err, herr.ErrAccess) {
+		t.Errorf("AppendFile: got err %v, want DataNodeError of ErrAccess", err)
+	}
+
+	// offset超出文件末尾时DataNode返回403
+	if _, err = hadoop.Read(ctx, "/file", 100, 10, 0); !errors.As(err, &dnErr) || !errors.Is(err, herr.ErrEOF) {
+		t.Errorf("Read beyond EOF: got err %v, want DataNodeError of ErrEOF", err)
+	}
+
+	// NameNode返回的错误不是 DataNodeError
+	if err = hadoop.AppendFile(ctx, "/missing", []byte("more")); errors.As(err, &dnErr) || !errors.Is(err, herr.ErrNoFound) {
+		t.Errorf("AppendFile missing file: got err %v, want NameNode ErrNoFound", err)
+	}
+}
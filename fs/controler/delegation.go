@@ -0,0 +1,207 @@
+package controler
+
+import (
+	"context"
+	"errors"
+	"hadoop-fs/fs/logger"
+	"net/http"
+	"time"
+)
+
+// delegation token 相关的op code，这些请求不能带上delegation参数
+const (
+	opGetDelegationToken    = "GETDELEGATIONTOKEN"
+	opRenewDelegationToken  = "RENEWDELEGATIONTOKEN"
+	opCancelDelegationToken = "CANCELDELEGATIONTOKEN"
+)
+
+// 在token有效期过了80%时续期
+const delegationRenewFactor = 0.8
+
+// 两次续期的最短间隔，续期失败时也按这个间隔重试
+var delegationMinRenewWait = time.Minute
+
+// ErrNoDelegation 集群没有开启安全认证时，GETDELEGATIONTOKEN 不会返回token
+var ErrNoDelegation = errors.New("delegation token is not available, is security enabled on the cluster?")
+
+// TokenResp response contain delegation token from hadoop
+type TokenResp struct {
+	Token *struct {
+		URLString string `json:"urlString"`
+	} `json:"Token"`
+}
+
+// LongResp response contain long from hadoop
+type LongResp struct {
+	Long int64 `json:"long"`
+}
+
+func isDelegationOp(op string) bool {
+	return op == opGetDelegationToken || op == opRenewDelegationToken || op == opCancelDelegationToken
+}
+
+// getDelegation 获取当前使用的delegation token
+func (hadoop *HadoopController) getDelegation() string {
+	hadoop.delegationLock.RLock()
+	defer hadoop.delegationLock.RUnlock()
+
+	return hadoop.delegation
+}
+
+// SetDelegation 使用已有的delegation token，之后的请求都会带上delegation参数
+func (hadoop *HadoopController) SetDelegation(token string) {
+	hadoop.delegationLock.Lock()
+	defer hadoop.delegationLock.Unlock()
+
+	hadoop.delegation = token
+	hadoop.delegationOwned = false
+}
+
+// FetchDelegation 通过 GETDELEGATIONTOKEN 获取一个新的delegation token，
+// 获取的token会在 Close 时取消
+func (hadoop *HadoopController) FetchDelegation(renewer string) (err error) {
+	tokenResp := TokenResp{}
+	err = hadoop.call(context.Background(), webhdfsRequest{
+		method: http.MethodGet,
+		op:     opGetDelegationToken,
+		path:   "/",
+		params: map[string]string{"renewer": renewer},
+	}, &tokenResp)
+
+	if err != nil {
+		return err
+	}
+	if tokenResp.Token == nil || tokenResp.Token.URLString == "" {
+		return ErrNoDelegation
+	}
+
+	hadoop.delegationLock.Lock()
+	hadoop.delegation = tokenResp.Token.URLString
+	hadoop.delegationOwned = true
+	hadoop.delegationRenewer = renewer
+	hadoop.delegationLock.Unlock()
+
+	logger.Info.Println("fetched a new delegation token")
+
+	return err
+}
+
+// tokenOp 对token执行 RENEWDELEGATIONTOKEN 或者 CANCELDELEGATIONTOKEN
+func (hadoop *HadoopController) tokenOp(op, token string, out interface{}) error {
+	return hadoop.call(context.Background(), webhdfsRequest{
+		method: http.MethodPut,
+		op:     op,
+		path:   "/",
+		params: map[string]string{"token": token},
+	}, out)
+}
+
+// RenewDelegation 续期当前的delegation token，返回新的过期时间
+func (hadoop *HadoopController) RenewDelegation() (expiration time.Time, err error) {
+
+	token := hadoop.getDelegation()
+	if token == "" {
+		return expiration, ErrNoDelegation
+	}
+
+	longResp := LongResp{}
+	if err = hadoop.tokenOp(opRenewDelegationToken, token, &longResp); err != nil {
+		return expiration, err
+	}
+
+	return time.Unix(0, longResp.Long*int64(time.Millisecond)), nil
+}
+
+// CancelDelegation 取消当前的delegation token，之后的请求不再带上delegation参数
+func (hadoop *HadoopController) CancelDelegation() error {
+
+	token := hadoop.getDelegation()
+	if token == "" {
+		return nil
+	}
+
+	hadoop.delegationLock.Lock()
+	hadoop.delegation = ""
+	hadoop.delegationOwned = false
+	hadoop.delegationLock.Unlock()
+
+	return hadoop.tokenOp(opCancelDelegationToken, token, nil)
+}
+
+// StartDelegationRenewer 在后台定时续期delegation token，
+// 如果token是自己获取的，达到最长有效期后会重新获取一个
+func (hadoop *HadoopController) StartDelegationRenewer() {
+
+	hadoop.delegationLock.Lock()
+	defer hadoop.delegationLock.Unlock()
+
+	if hadoop.delegationStop != nil {
+		return
+	}
+
+	hadoop.delegationStop = make(chan struct{})
+	go hadoop.renewDelegationLoop(hadoop.delegationStop)
+}
+
+func (hadoop *HadoopController) renewDelegationLoop(stop chan struct{}) {
+
+	var lastExpiration time.Time
+
+	for {
+		expiration, err := hadoop.RenewDelegation()
+
+		hadoop.delegationLock.RLock()
+		owned := hadoop.delegationOwned
+		renewer := hadoop.delegationRenewer
+		hadoop.delegationLock.RUnlock()
+
+		if (err != nil || !expiration.After(lastExpiration)) && owned {
+			// 续期失败或者已经无法再延长，重新获取一个token
+			logger.Warning.Printf("delegation token can not be renewed any more [%v], fetch a new one\n", err)
+
+			old := hadoop.getDelegation()
+			if err = hadoop.FetchDelegation(renewer); err == nil {
+				hadoop.tokenOp(opCancelDelegationToken, old, nil)
+				expiration, err = hadoop.RenewDelegation()
+			}
+		}
+
+		wait := delegationMinRenewWait
+		if err != nil {
+			logger.Error.Printf("renew delegation token failed: %v\n", err)
+		} else {
+			logger.Info.Printf("delegation token renewed, expires at %s\n", expiration)
+			lastExpiration = expiration
+
+			if next := time.Duration(float64(time.Until(expiration)) * delegationRenewFactor); next > wait {
+				wait = next
+			}
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Close 停止输出统计和续期，并取消自己获取的delegation token
+func (hadoop *HadoopController) Close() error {
+
+	hadoop.SetStatsInterval(0)
+
+	hadoop.delegationLock.Lock()
+	if hadoop.delegationStop != nil {
+		close(hadoop.delegationStop)
+		hadoop.delegationStop = nil
+	}
+	owned := hadoop.delegationOwned
+	hadoop.delegationLock.Unlock()
+
+	if owned {
+		return hadoop.CancelDelegation()
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package controler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFetchDelegation(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/file", nil)
+
+	if err := hadoop.FetchDelegation("renewer"); err != nil {
+		t.Fatalf("FetchDelegation: %v", err)
+	}
+	token := hadoop.getDelegation()
+	if token == "" || !server.ValidToken(token) {
+		t.Fatalf("FetchDelegation: got invalid token %q", token)
+	}
+	if req, _ := server.LastRequest(opGetDelegationToken); req.Query.Get("renewer") != "renewer" {
+		t.Errorf("FetchDelegation: renewer = %q", req.Query.Get("renewer"))
+	}
+
+	if _, err := hadoop.GetFileStatus(context.Background(), "/file"); err != nil {
+		t.Fatalf("GetFileStatus: %v", err)
+	}
+	req, _ := server.LastRequest("GETFILESTATUS")
+	if req.Query.Get("delegation") != token || req.Query.Get("user.name") != "" {
+		t.Errorf("GetFileStatus: delegation = %q, user.name = %q", req.Query.Get("delegation"), req.Query.Get("user.name"))
+	}
+
+	// DataNode 的请求也要带上token
+	if err := hadoop.AppendFile(context.Background(), "/file", []byte("data")); err != nil {
+		t.Fatalf("AppendFile: %v", err)
+	}
+}
+
+func TestRenewAndCancelDelegation(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/file", nil)
+
+	if err := hadoop.FetchDelegation(""); err != nil {
+		t.Fatalf("FetchDelegation: %v", err)
+	}
+	token := hadoop.getDelegation()
+
+	expiration, err := hadoop.RenewDelegation()
+	if err != nil || time.Until(expiration) < 23*time.Hour {
+		t.Fatalf("RenewDelegation: got %s, err %v", expiration, err)
+	}
+
+	if err = hadoop.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if server.ValidToken(token) {
+		t.Errorf("Close: fetched token should be cancelled")
+	}
+	if hadoop.getDelegation() != "" {
+		t.Errorf("Close: delegation should be cleared")
+	}
+}
+
+func TestCloseKeepsGivenDelegation(t *testing.T) {
+	hadoop, server := newTestController(t)
+
+	if err := hadoop.FetchDelegation(""); err != nil {
+		t.Fatalf("FetchDelegation: %v", err)
+	}
+	token := hadoop.getDelegation()
+
+	// 通过参数传入的token不属于自己，不能取消
+	hadoop.SetDelegation(token)
+	if err := hadoop.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if !server.ValidToken(token) {
+		t.Errorf("Close: given token should not be cancelled")
+	}
+}
+
+func TestDelegationRenewer(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/file", nil)
+
+	oldWait := delegationMinRenewWait
+	delegationMinRenewWait = 10 * time.Millisecond
+	defer func() { delegationMinRenewWait = oldWait }()
+
+	server.TokenRenewInterval = 200 * time.Millisecond
+	server.TokenMaxLifetime = 500 * time.Millisecond
+
+	if err := hadoop.FetchDelegation(""); err != nil {
+		t.Fatalf("FetchDelegation: %v", err)
+	}
+	first := hadoop.getDelegation()
+
+	hadoop.StartDelegationRenewer()
+	defer hadoop.Close()
+
+	// 超过最长有效期后，续期线程会重新获取token
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := hadoop.GetFileStatus(context.Background(), "/file"); err != nil {
+			t.Fatalf("GetFileStatus at %s: %v", time.Now(), err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if current := hadoop.getDelegation(); current == first {
+		t.Errorf("token should be replaced after max lifetime")
+	}
+	if server.ValidToken(first) {
+		t.Errorf("replaced token should be cancelled")
+	}
+}
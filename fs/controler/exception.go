@@ -0,0 +1,45 @@
+package controler
+
+import (
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"strings"
+)
+
+// exceptionErrors RemoteException 的类名(不含包名)对应的错误
+var exceptionErrors = map[string]error{
+	"FileNotFoundException":            herr.ErrNoFound,
+	"AccessControlException":           herr.ErrAccess,
+	"AuthorizationException":           herr.ErrAccess,
+	"FileAlreadyExistsException":       herr.ErrExist,
+	"ParentNotDirectoryException":      herr.ErrNotDir,
+	"PathIsNotDirectoryException":      herr.ErrNotDir,
+	"PathIsNotEmptyDirectoryException": herr.ErrNotEmpty,
+	"NSQuotaExceededException":         herr.ErrQuota,
+	"DSQuotaExceededException":         herr.ErrQuota,
+	"QuotaExceededException":           herr.ErrQuota,
+	"SafeModeException":                herr.ErrSafeMode,
+	"LeaseExpiredException":            herr.ErrLease,
+	"UnsupportedOperationException":    herr.ErrNotsup,
+	"AclException":                     herr.ErrNotsup,
+}
+
+// Kind 按 JavaClassName 分类的错误，没有对应时返回nil
+func (hadoop HadoopException) Kind() error {
+
+	name := hadoop.RemoteException.JavaClassName
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	if name == "" {
+		// 有的代理只返回exception
+		name = hadoop.RemoteException.Exception
+	}
+
+	return exceptionErrors[name]
+}
+
+// Is 使 errors.Is(err, herr.ErrNoFound) 等可以判断 HadoopException 的类型
+func (hadoop HadoopException) Is(target error) bool {
+	kind := hadoop.Kind()
+	return kind != nil && kind == target
+}
@@ -0,0 +1,77 @@
+package controler
+
+import (
+	"context"
+	"errors"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"net/http"
+	"testing"
+)
+
+func TestExceptionKind(t *testing.T) {
+	cases := []struct {
+		status        int
+		exception     string
+		javaClassName string
+		want          error
+	}{
+		{http.StatusNotFound, "FileNotFoundException", "java.io.FileNotFoundException", herr.ErrNoFound},
+		{http.StatusForbidden, "AccessControlException", "org.apache.hadoop.security.AccessControlException", herr.ErrAccess},
+		{http.StatusForbidden, "FileAlreadyExistsException", "org.apache.hadoop.fs.FileAlreadyExistsException", herr.ErrExist},
+		{http.StatusForbidden, "ParentNotDirectoryException", "org.apache.hadoop.fs.ParentNotDirectoryException", herr.ErrNotDir},
+		{http.StatusForbidden, "PathIsNotEmptyDirectoryException", "org.apache.hadoop.fs.PathIsNotEmptyDirectoryException", herr.ErrNotEmpty},
+		{http.StatusForbidden, "NSQuotaExceededException", "org.apache.hadoop.hdfs.protocol.NSQuotaExceededException", herr.ErrQuota},
+		{http.StatusForbidden, "DSQuotaExceededException", "org.apache.hadoop.hdfs.protocol.DSQuotaExceededException", herr.ErrQuota},
+		{http.StatusForbidden, "SafeModeException", "org.apache.hadoop.hdfs.server.namenode.SafeModeException", herr.ErrSafeMode},
+		{http.StatusForbidden, "LeaseExpiredException", "org.apache.hadoop.hdfs.server.namenode.LeaseExpiredException", herr.ErrLease},
+		{http.StatusBadRequest, "UnsupportedOperationException", "java.lang.UnsupportedOperationException", herr.ErrNotsup},
+		// 没有javaClassName时使用exception
+		{http.StatusForbidden, "AccessControlException", "", herr.ErrAccess},
+	}
+
+	hadoop, server := newTestController(t)
+	server.AddFile("/file", nil)
+
+	for _, c := range cases {
+		server.Fail("APPEND", c.status, c.exception, c.javaClassName, "failed")
+
+		err := hadoop.AppendFile(context.Background(), "/file", []byte("data"))
+		if !errors.Is(err, c.want) {
+			t.Errorf("%s: got err %v, want %v", c.exception, err, c.want)
+		}
+
+		// 保留原始的异常信息
+		var exception HadoopException
+		if !errors.As(err, &exception) || exception.RemoteException.Message != "failed" {
+			t.Errorf("%s: got err %#v, want HadoopException", c.exception, err)
+		}
+	}
+}
+
+func TestUnknownException(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/file", nil)
+
+	// 无法分类的403 IOException 不能当成没有权限
+	server.Fail("MKDIRS", http.StatusForbidden, "IOException", "java.io.IOException", "failed")
+	_, err := hadoop.MakeDir(context.Background(), "/dir", "755")
+	if _, ok := err.(HadoopException); !ok || errors.Is(err, herr.ErrAccess) {
+		t.Errorf("MakeDir: got err %v, want unclassified HadoopException", err)
+	}
+
+	// OPEN 超出文件末尾
+	if _, err = hadoop.Read(context.Background(), "/file", 100, 10, 0); !errors.Is(err, herr.ErrEOF) {
+		t.Errorf("Read beyond EOF: got err %v, want ErrEOF", err)
+	}
+}
+
+func TestResponseErrorWithoutException(t *testing.T) {
+	request := webhdfsRequest{op: opGetFileStatus, path: "/file"}
+
+	if err := request.responseError(http.StatusNotFound, []byte("<html>Not Found</html>")); !errors.Is(err, herr.ErrNoFound) {
+		t.Errorf("404 html: got err %v, want ErrNoFound", err)
+	}
+	if err := request.responseError(http.StatusBadGateway, nil); err == nil || errors.Is(err, herr.ErrNoFound) {
+		t.Errorf("502: got err %v", err)
+	}
+}
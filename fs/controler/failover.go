@@ -0,0 +1,170 @@
+package controler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"hadoop-fs/fs/logger"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// StandbyException 的javaClassName
+const standbyException = "org.apache.hadoop.ipc.StandbyException"
+
+// errNoRetryBody 请求体无法重新生成，不能重试
+var errNoRetryBody = errors.New("request body can not be sent again")
+
+// SetNameNodes 设置HA的所有NameNode地址(host:port)，请求遇到 StandbyException 或者连接失败时，
+// 会依次尝试其它的NameNode，并记住成功的那个。幂等的请求在超时等其它网络错误时也会切换
+func (hadoop *HadoopController) SetNameNodes(addrs []string) {
+
+	hadoop.namenodeLock.Lock()
+	defer hadoop.namenodeLock.Unlock()
+
+	hadoop.namenodes = append([]string(nil), addrs...)
+	hadoop.active = 0
+}
+
+// activeNameNode 当前使用的NameNode
+func (hadoop *HadoopController) activeNameNode() (int, string) {
+
+	hadoop.namenodeLock.RLock()
+	defer hadoop.namenodeLock.RUnlock()
+
+	return hadoop.active, hadoop.namenodes[hadoop.active]
+}
+
+func (hadoop *HadoopController) setActiveNameNode(index int) {
+
+	hadoop.namenodeLock.Lock()
+	defer hadoop.namenodeLock.Unlock()
+
+	if hadoop.active != index {
+		logger.Warning.Printf("failover: active NameNode changed from [%s] to [%s]\n",
+			hadoop.namenodes[hadoop.active], hadoop.namenodes[index])
+		hadoop.active = index
+	}
+}
+
+// failover 发送请求到NameNode，失败时切换到其它的NameNode重试
+func (hadoop *HadoopController) failover(req *http.Request) (*http.Response, error) {
+
+	hadoop.namenodeLock.RLock()
+	namenodes := hadoop.namenodes
+	start := hadoop.active
+	hadoop.namenodeLock.RUnlock()
+
+	if len(namenodes) <= 1 {
+		return hadoop.send(req)
+	}
+
+	var resp *http.Response
+	var err error
+
+	for i := 0; i < len(namenodes); i++ {
+		index := (start + i) % len(namenodes)
+
+		attempt := req
+		if i > 0 {
+			if attempt, err = cloneRequest(req); err != nil {
+				return nil, err
+			}
+		}
+		attempt.URL.Host = namenodes[index]
+
+		resp, err = hadoop.send(attempt)
+
+		reason := failoverReason(attempt, resp, err)
+		if reason == "" {
+			hadoop.setActiveNameNode(index)
+			return resp, err
+		}
+
+		logger.Warning.Printf("failover: NameNode [%s] %s\n", namenodes[index], reason)
+
+		if i < len(namenodes)-1 && resp != nil {
+			resp.Body.Close()
+		}
+	}
+
+	logger.Error.Printf("failover: all NameNodes %v failed\n", namenodes)
+
+	return resp, err
+}
+
+// failoverReason 判断是否需要切换NameNode，返回切换的原因，不需要切换时返回空字符串
+func failoverReason(req *http.Request, resp *http.Response, err error) string {
+
+	if err != nil {
+		// 请求被取消或者超时，不再尝试其他NameNode
+		if req.Context().Err() != nil {
+			return ""
+		}
+		// 只有连接NameNode失败时才切换，DataNode的错误不切换
+		if urlErr, ok := err.(*url.Error); ok {
+			if failed, perr := url.Parse(urlErr.URL); perr == nil && failed.Host != req.URL.Host {
+				return ""
+			}
+		}
+		if isDialError(err) {
+			return "connect failed: " + err.Error()
+		}
+		// 连接建立后失败(比如等待响应超时)时NameNode可能已经执行了请求，与重试一样只切换幂等的请求
+		if idempotentOps[req.URL.Query().Get("op")] {
+			return "request failed: " + err.Error()
+		}
+		return ""
+	}
+
+	if resp.StatusCode < 300 || resp.Request.URL.Host != req.URL.Host {
+		return ""
+	}
+
+	// 读出body判断是否是StandbyException，再放回去给调用者使用
+	buf, rerr := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(buf))
+	if rerr != nil {
+		return ""
+	}
+
+	exception := HadoopException{}
+	if json.Unmarshal(buf, &exception) != nil {
+		return ""
+	}
+	if exception.RemoteException.JavaClassName == standbyException ||
+		strings.Contains(exception.RemoteException.Message, "in state standby") {
+		return "is standby"
+	}
+
+	return ""
+}
+
+// isDialError 是否是建立连接时的错误，这时请求还没有发送到NameNode
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// cloneRequest 复制一个请求用于重试，请求体也会重新生成
+func cloneRequest(req *http.Request) (*http.Request, error) {
+
+	retry := req.Clone(req.Context())
+
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, errNoRetryBody
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+
+	return retry, nil
+}
@@ -0,0 +1,127 @@
+package controler
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestFailoverToActive(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/file", []byte("data"))
+
+	standby := server.AddNameNode()
+	server.SetActive(1)
+	hadoop.SetNameNodes([]string{server.NameNodeAddr(0), standby})
+
+	file, err := hadoop.GetFileStatus(context.Background(), "/file")
+	if err != nil || file.StSize != 4 {
+		t.Fatalf("GetFileStatus: got %+v, err %v", file, err)
+	}
+	if index, _ := hadoop.activeNameNode(); index != 1 {
+		t.Errorf("active NameNode = %d, want 1", index)
+	}
+
+	// 之后的请求直接发到active的NameNode
+	before := len(server.Requests())
+	if _, err = hadoop.GetFileStatus(context.Background(), "/file"); err != nil {
+		t.Fatalf("GetFileStatus: %v", err)
+	}
+	requests := server.Requests()[before:]
+	if len(requests) != 1 || requests[0].NameNode != 1 {
+		t.Errorf("GetFileStatus after failover: requests %+v", requests)
+	}
+
+	// 写请求切换时要重新发送请求体
+	server.SetActive(0)
+	if err = hadoop.AppendFile(context.Background(), "/file", []byte("more")); err != nil {
+		t.Fatalf("AppendFile: %v", err)
+	}
+	if content, _ := server.Content("/file"); string(content) != "datamore" {
+		t.Errorf("AppendFile: content = %q", content)
+	}
+	if index, _ := hadoop.activeNameNode(); index != 0 {
+		t.Errorf("active NameNode = %d, want 0", index)
+	}
+}
+
+func TestFailoverUnreachable(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/file", nil)
+
+	// 找一个没有监听的端口
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := listener.Addr().String()
+	listener.Close()
+
+	hadoop.SetNameNodes([]string{down, server.NameNodeAddr(0)})
+
+	if _, err = hadoop.GetFileStatus(context.Background(), "/file"); err != nil {
+		t.Fatalf("GetFileStatus: %v", err)
+	}
+	if index, _ := hadoop.activeNameNode(); index != 1 {
+		t.Errorf("active NameNode = %d, want 1", index)
+	}
+}
+
+func TestFailoverAllStandby(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/file", nil)
+
+	standby := server.AddNameNode()
+	server.SetActive(-1)
+	hadoop.SetNameNodes([]string{server.NameNodeAddr(0), standby})
+
+	_, err := hadoop.GetFileStatus(context.Background(), "/file")
+	exception, ok := err.(HadoopException)
+	if !ok || exception.RemoteException.JavaClassName != standbyException {
+		t.Errorf("GetFileStatus: got err %v, want StandbyException", err)
+	}
+}
+
+func TestFailoverTimeout(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/file", []byte("data"))
+
+	// 接受连接但是不返回响应的NameNode
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+
+	hadoop.SetHTTPOptions(HTTPOptions{ResponseHeaderTimeout: 100 * time.Millisecond})
+	hadoop.SetNameNodes([]string{listener.Addr().String(), server.NameNodeAddr(0)})
+
+	// 超时的RENAME可能已经被执行，不发送到其它NameNode
+	if _, err = hadoop.Rename(context.Background(), "/file", "/renamed"); err == nil {
+		t.Fatalf("Rename: expected timeout error")
+	}
+	if countOps(server, opRename) != 0 {
+		t.Errorf("Rename should not be resent after a timeout")
+	}
+	if _, ok := server.Status("/file"); !ok {
+		t.Errorf("/file should not be renamed")
+	}
+
+	// 幂等的请求切换到其它NameNode
+	if _, err = hadoop.GetFileStatus(context.Background(), "/file"); err != nil {
+		t.Fatalf("GetFileStatus: %v", err)
+	}
+	if index, _ := hadoop.activeNameNode(); index != 1 {
+		t.Errorf("active NameNode = %d, want 1", index)
+	}
+}
@@ -1,20 +1,27 @@
 package controler
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
+	"context"
+	"crypto/tls"
 	herr "hadoop-fs/fs/controler/hadoop_error"
 	"hadoop-fs/fs/logger"
 	"hadoop-fs/fs/model"
+	"net"
 	"net/http"
+	"path"
 	"strconv"
+	"strings"
+	"sync"
 )
 
 // op code
 const (
 	opListStatusBatch = "LISTSTATUS_BATCH"
 	opGetFileStatus   = "GETFILESTATUS"
+	opGetHomeDir      = "GETHOMEDIRECTORY"
+	opGetStatus       = "GETSTATUS"
+	opGetQuotaUsage   = "GETQUOTAUSAGE"
+	opGetContent      = "GETCONTENTSUMMARY"
 	opRead            = "OPEN"
 	opMkDir           = "MKDIRS"
 	opCreate          = "CREATE"
@@ -23,11 +30,17 @@ const (
 	opTruncate        = "TRUNCATE"
 	opDelete          = "DELETE"
 	opSetPermission   = "SETPERMISSION"
+	opCheckAccess     = "CHECKACCESS"
+	opSetOwner        = "SETOWNER"
 	opRename          = "RENAME"
 	opCreateSymlink   = "CREATESYMLINK"
 	opSetXattr        = "SETXATTR"
 	opGetXattr        = "GETXATTRS"
 	opRemoveXattr     = "REMOVEXATTR"
+	opGetAclStatus    = "GETACLSTATUS"
+	opSetAcl          = "SETACL"
+	opRemoveDefAcl    = "REMOVEDEFAULTACL"
+	opRemoveAclEntry  = "REMOVEACLENTRIES"
 )
 
 var defaultBufferSize = 4096
@@ -36,12 +49,63 @@ var defaultLength = 4096
 // HadoopController 与Hadoop WebHDFS 交互的控制类
 type HadoopController struct {
 	isSSL bool
-	host  string
-	port  int
+
+	// NameNode的地址(host:port)，HA时有多个，active 是当前使用的NameNode
+	namenodes    []string
+	active       int
+	namenodeLock sync.RWMutex
 
 	username string
 
 	httpPrefix string
+	// basePath WebHDFS REST API 的路径前缀，默认是 /webhdfs/v1
+	basePath string
+
+	// 发往NameNode和DataNode的请求都使用这个client，由 buildClient 创建
+	client        *http.Client
+	transport     *http.Transport
+	httpOptions   HTTPOptions
+	tlsConfig     *tls.Config
+	tlsServerName string
+	retryOptions  RetryOptions
+
+	// headers 所有请求都带上的请求头，csrfHeader 是修改操作带上的CSRF请求头
+	headers    http.Header
+	csrfHeader string
+
+	// limit 限制同时发往NameNode的请求数，为nil时不限制
+	limit     chan struct{}
+	stats     RequestStats
+	statsLock sync.Mutex
+	// statsStop 停止定时输出统计，statsLogged 是上次输出时的请求数
+	statsStop   chan struct{}
+	statsLogged uint64
+
+	// flights 正在进行的可以合并的请求
+	flights    map[string]*flight
+	flightLock sync.Mutex
+
+	// datanodeMap DataNode地址的改写规则，由 SetDataNodeMap 设置
+	datanodeMap map[string]string
+
+	// gateway 服务端的类型，httpfs 为1时按HttpFS的方式上传数据
+	gateway string
+	httpfs  int32
+
+	// noQuotaUsage 为1时NameNode不支持GETQUOTAUSAGE(Hadoop 3.1之前)，使用GETCONTENTSUMMARY
+	noQuotaUsage int32
+	// noStatus 为1时NameNode不支持GETSTATUS(Hadoop 3.4之前)，不再发送
+	noStatus int32
+
+	// auth 为空时使用 user.name 参数的简单认证
+	auth Authenticator
+
+	// delegation token，不为空时所有请求都带上delegation参数
+	delegation        string
+	delegationOwned   bool // 是否是通过 FetchDelegation 获取的
+	delegationRenewer string
+	delegationStop    chan struct{}
+	delegationLock    sync.RWMutex
 
 	inited bool
 }
@@ -56,781 +120,412 @@ func (hadoop *HadoopController) Init(ssl bool, host string, port int, username s
 		hadoop.httpPrefix = "http"
 	}
 
-	hadoop.host = host
-	hadoop.port = port
+	hadoop.namenodes = []string{net.JoinHostPort(host, strconv.Itoa(port))}
 	hadoop.username = username
+	hadoop.basePath = webhdfsPrefix
+	hadoop.csrfHeader = DefaultCSRFHeader
+
+	hadoop.gateway = GatewayAuto
+	hadoop.httpOptions = DefaultHTTPOptions
+	hadoop.buildClient()
+	hadoop.retryOptions = DefaultRetryOptions
+	hadoop.SetConcurrency(DefaultConcurrency)
 
 	hadoop.inited = true
 
 }
 
-func (hadoop *HadoopController) urlJoin(path, op string) string {
-	var url string
-	if hadoop.username != "" {
-		url = fmt.Sprintf("%s://%s:%d/webhdfs/v1%s?user.name=%s&op=%s", hadoop.httpPrefix, hadoop.host, hadoop.port, path, hadoop.username, op)
-	} else {
-		url = fmt.Sprintf("%s://%s:%d/webhdfs/v1%s?op=%s", hadoop.httpPrefix, hadoop.host, hadoop.port, path, op)
+// SetBasePath 设置WebHDFS REST API 的路径前缀，比如通过Apache Knox访问时的 /gateway/<topology>/webhdfs/v1
+func (hadoop *HadoopController) SetBasePath(basePath string) {
+	basePath = "/" + strings.Trim(basePath, "/")
+	if basePath == "/" {
+		basePath = ""
 	}
+	hadoop.basePath = basePath
+}
 
-	return url
+// SetAuthenticator 设置认证方式，例如Kerberos
+func (hadoop *HadoopController) SetAuthenticator(auth Authenticator) {
+	hadoop.auth = auth
 }
 
 // List 列出目录下的文件
-func (hadoop *HadoopController) List(path, startAfter string) (fileList []model.FileModel, remain int, err error) {
-
-	defer func() {
-		if err := recover(); err != nil {
-			logger.Error.Println(err)
-		}
-	}()
-
-	url := hadoop.urlJoin(path, opListStatusBatch)
-
-	if startAfter != "" {
-		url = urlAddParam(url, "startAfter", startAfter)
-	}
-
-	resp, err := http.Get(url)
-
-	if err != nil {
-		panic(err)
-	}
-
-	defer resp.Body.Close()
-
-	buf := bytes.NewBuffer(nil)
-
-	buf.ReadFrom(resp.Body)
-
-	if resp.StatusCode != 200 {
-		exception := HadoopException{}
-		err = json.Unmarshal(buf.Bytes(), &exception)
-		if err != nil {
-			panic(err)
-		}
-		switch resp.StatusCode {
-		case 404:
-			panic(herr.ErrNoFound)
-		default:
-			panic(exception)
-		}
-	}
+func (hadoop *HadoopController) List(ctx context.Context, dirPath, startAfter string) (fileList []model.FileModel, remain int, err error) {
 
 	statusBatch := ListStatusBatch{}
-	err = json.Unmarshal(buf.Bytes(), &statusBatch)
+	err = hadoop.call(ctx, webhdfsRequest{
+		method: http.MethodGet,
+		op:     opListStatusBatch,
+		path:   dirPath,
+		params: map[string]string{"startAfter": startAfter},
+	}, &statusBatch)
 
 	if err != nil {
-		panic(err)
+		return nil, 0, err
 	}
 
 	fileList = statusBatch.GetFiles()
-	remain = statusBatch.PemainingEntries
-
-	return
-}
+	remain = statusBatch.GetRemaining()
 
-func recoverError(exception *error) {
-	if err := recover(); err != nil {
-		*exception = err.(error)
-		logger.Error.Println(err)
+	for i := range fileList {
+		fillFileID(path.Join(dirPath, fileList[i].Name), &fileList[i])
 	}
+
+	return
 }
 
 // GetFileStatus 获取文件信息
-func (hadoop *HadoopController) GetFileStatus(filePath string) (file model.FileModel, err error) {
-
-	defer recoverError(&err)
-
-	url := hadoop.urlJoin(filePath, opGetFileStatus)
+func (hadoop *HadoopController) GetFileStatus(ctx context.Context, filePath string) (file model.FileModel, err error) {
 
-	resp, err := http.Get(url)
+	fileStatus := GetFileStatus{}
+	err = hadoop.call(ctx, webhdfsRequest{
+		method: http.MethodGet,
+		op:     opGetFileStatus,
+		path:   filePath,
+	}, &fileStatus)
 
 	if err != nil {
-		panic(err)
+		return model.FileModel{}, err
 	}
 
-	defer resp.Body.Close()
-
-	buf := bytes.NewBuffer(nil)
-
-	buf.ReadFrom(resp.Body)
+	file = fileStatus.GetFile()
+	fillFileID(filePath, &file)
 
-	if resp.StatusCode != 200 {
-		exception := HadoopException{}
-		err = json.Unmarshal(buf.Bytes(), &exception)
-		if err != nil {
-			panic(err)
-		}
-		switch resp.StatusCode {
-		case 404:
-			panic(herr.ErrNoFound)
-		default:
-			panic(exception)
-		}
-	}
+	return file, nil
+}
 
-	fileStatus := GetFileStatus{}
-	err = json.Unmarshal(buf.Bytes(), &fileStatus)
+// GetHomeDirectory 获取当前用户的home目录
+func (hadoop *HadoopController) GetHomeDirectory(ctx context.Context) (home string, err error) {
+	homeResp := PathResp{}
+	err = hadoop.call(ctx, webhdfsRequest{
+		method: http.MethodGet,
+		op:     opGetHomeDir,
+		path:   "/",
+	}, &homeResp)
 
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 
-	file = fileStatus.GetFile()
-
-	return file, err
+	return homeResp.Path, nil
 }
 
 // 读取文件内容
-func (hadoop *HadoopController) Read(filePath string, offset uint64, length uint32, buffersize int) (content []byte, err error) {
-	defer recoverError(&err)
-
-	url := hadoop.urlJoin(filePath, opRead)
-
-	url = urlAddParam(url, "offset", strconv.FormatInt(int64(offset), 10))
-
+func (hadoop *HadoopController) Read(ctx context.Context, filePath string, offset uint64, length uint32, buffersize int) (content []byte, err error) {
 	if length <= 0 {
 		length = uint32(defaultLength)
 	}
 	if buffersize <= 0 {
 		buffersize = defaultBufferSize
 	}
-	url = urlAddParam(url, "length", strconv.FormatInt(int64(length), 10))
-	url = urlAddParam(url, "buffersize", strconv.Itoa(buffersize))
 
-	resp, err := http.Get(url)
+	err = hadoop.call(ctx, webhdfsRequest{
+		method: http.MethodGet,
+		op:     opRead,
+		path:   filePath,
+		params: map[string]string{
+			"offset":     strconv.FormatUint(offset, 10),
+			"length":     strconv.FormatUint(uint64(length), 10),
+			"buffersize": strconv.Itoa(buffersize),
+		},
+		// offset超出文件末尾时返回403 IOException
+		errors: map[int]error{403: herr.ErrEOF},
+	}, &content)
 
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	defer resp.Body.Close()
-
-	buf := bytes.NewBuffer(nil)
-
-	buf.ReadFrom(resp.Body)
-
-	if resp.StatusCode != 200 {
-		exception := HadoopException{}
-		err = json.Unmarshal(buf.Bytes(), &exception)
-		if err != nil {
-			panic(err)
-		}
-		switch resp.StatusCode {
-		case 404:
-			panic(herr.ErrNoFound)
-		case 403:
-			panic(herr.ErrEOF)
-		default:
-			panic(exception)
-		}
-
-	}
-
-	content = buf.Bytes()
-
-	return content, err
+	return content, nil
 }
 
 // MakeDir 创建目录
-func (hadoop *HadoopController) MakeDir(pathname, permission string) (result bool, err error) {
-	defer recoverError(&err)
-
-	url := hadoop.urlJoin(pathname, opMkDir)
-
-	if permission != "" {
-		url = urlAddParam(url, "permission", permission)
-	}
-
-	req, err := http.NewRequest("PUT", url, nil)
-
-	if err != nil {
-		panic(err)
-	}
-
-	resp, err := http.DefaultClient.Do(req)
-
-	if err != nil {
-		panic(err)
-	}
-
-	defer resp.Body.Close()
-
-	buf := bytes.NewBuffer(nil)
-	buf.ReadFrom(resp.Body)
-
-	if resp.StatusCode != 200 {
-		exception := HadoopException{}
-		err = json.Unmarshal(buf.Bytes(), &exception)
-		if err != nil {
-			panic(err)
-		}
-		switch resp.StatusCode {
-		case 403:
-			panic(herr.ErrAccess)
-		default:
-			panic(exception)
-		}
-	}
-
+func (hadoop *HadoopController) MakeDir(ctx context.Context, pathname, permission string) (result bool, err error) {
 	booleanRes := BooleanResp{}
-	err = json.Unmarshal(buf.Bytes(), &booleanRes)
+	err = hadoop.call(ctx, webhdfsRequest{
+		method: http.MethodPut,
+		op:     opMkDir,
+		path:   pathname,
+		params: map[string]string{"permission": permission},
+	}, &booleanRes)
 
 	if err != nil {
-		panic(err)
+		return false, err
 	}
 
-	return booleanRes.Boolean, err
+	return booleanRes.Boolean, nil
 }
 
 // Create 创建文件
-func (hadoop *HadoopController) Create(filepath, permission string) (err error) {
-	defer recoverError(&err)
-
-	url := hadoop.urlJoin(filepath, opCreate)
-
-	if permission != "" {
-		url = urlAddParam(url, "permission", permission)
-	}
-	url = urlAddParam(url, "overwrite", "false")
-
-	req, err := http.NewRequest("PUT", url, nil)
-
-	if err != nil {
-		panic(err)
-	}
-
-	resp, err := http.DefaultClient.Do(req)
-
-	if err != nil {
-		panic(err)
-	}
-
-	defer resp.Body.Close()
-
-	buf := bytes.NewBuffer(nil)
-	buf.ReadFrom(resp.Body)
-
-	if resp.StatusCode != 201 {
-		exception := HadoopException{}
-		err = json.Unmarshal(buf.Bytes(), &exception)
-		if err != nil {
-			panic(err)
-		}
-		switch exception.Error() {
-		case "AccessControlException":
-			panic(herr.ErrAccess)
-		case "FileAlreadyExistsException":
-			panic(herr.ErrExist)
-		default:
-			panic(exception)
-		}
-	}
+func (hadoop *HadoopController) Create(ctx context.Context, filepath, permission string) (err error) {
+	// HttpFS要求上传数据的请求都带上Content-Type
+	err = hadoop.call(ctx, webhdfsRequest{
+		method:      http.MethodPut,
+		op:          opCreate,
+		path:        filepath,
+		params:      map[string]string{"permission": permission, "overwrite": "false", "data": hadoop.dataParam()},
+		body:        []byte{},
+		contentType: "application/octet-stream",
+		status:      http.StatusCreated,
+	}, nil)
 
 	return err
 }
 
 // ModificationTime 设置文件Mtime和Atime，-1表示不变
-func (hadoop *HadoopController) ModificationTime(filepath string, mtime, atime int64) (err error) {
-	defer recoverError(&err)
-
-	url := hadoop.urlJoin(filepath, opSetTimes)
-
-	url = urlAddParam(url, "modificationtime", strconv.FormatInt(mtime, 10))
-	url = urlAddParam(url, "accesstime", strconv.FormatInt(atime, 10))
-
-	req, err := http.NewRequest("PUT", url, nil)
-
-	if err != nil {
-		panic(err)
-	}
-
-	resp, err := http.DefaultClient.Do(req)
-
-	if err != nil {
-		panic(err)
-	}
-
-	defer resp.Body.Close()
-
-	buf := bytes.NewBuffer(nil)
-	buf.ReadFrom(resp.Body)
-
-	if resp.StatusCode != 200 {
-		exception := HadoopException{}
-		err = json.Unmarshal(buf.Bytes(), &exception)
-		if err != nil {
-			panic(err)
-		}
-		switch exception.Error() {
-		case "AccessControlException":
-			panic(herr.ErrAccess)
-		default:
-			panic(exception)
-		}
-	}
+func (hadoop *HadoopController) ModificationTime(ctx context.Context, filepath string, mtime, atime int64) (err error) {
+	err = hadoop.call(ctx, webhdfsRequest{
+		method: http.MethodPut,
+		op:     opSetTimes,
+		path:   filepath,
+		params: map[string]string{
+			"modificationtime": strconv.FormatInt(mtime, 10),
+			"accesstime":       strconv.FormatInt(atime, 10),
+		},
+	}, nil)
 
 	return err
 }
 
 // AppendFile 追加文件内容
-func (hadoop *HadoopController) AppendFile(filepath string, content []byte) (err error) {
-	defer recoverError(&err)
-
-	url := hadoop.urlJoin(filepath, opAppend)
-
-	contentBuf := bytes.NewBuffer(content)
-
-	resp, err := http.Post(url, "application/octet-stream", contentBuf)
-
-	if err != nil {
-		panic(err)
+func (hadoop *HadoopController) AppendFile(ctx context.Context, filepath string, content []byte) (err error) {
+	if content == nil {
+		content = []byte{}
 	}
 
-	defer resp.Body.Close()
-
-	buf := bytes.NewBuffer(nil)
-	buf.ReadFrom(resp.Body)
-
-	if resp.StatusCode != 200 {
-		exception := HadoopException{}
-		err = json.Unmarshal(buf.Bytes(), &exception)
-		if err != nil {
-			panic(err)
-		}
-		switch exception.Error() {
-		case "AccessControlException":
-			panic(herr.ErrAccess)
-		default:
-			panic(exception)
-		}
-	}
+	err = hadoop.call(ctx, webhdfsRequest{
+		method:      http.MethodPost,
+		op:          opAppend,
+		path:        filepath,
+		params:      map[string]string{"data": hadoop.dataParam()},
+		body:        content,
+		contentType: "application/octet-stream",
+	}, nil)
 
 	return err
 }
 
 // TruncateFile Truncate 文件
-func (hadoop *HadoopController) TruncateFile(filepath string, newlength int64) (result bool, err error) {
-	defer recoverError(&err)
-
-	url := hadoop.urlJoin(filepath, opTruncate)
-	url = urlAddParam(url, "newlength", strconv.FormatInt(newlength, 10))
-
-	resp, err := http.Post(url, "application/json", nil)
-
-	if err != nil {
-		panic(err)
-	}
-
-	defer resp.Body.Close()
-
-	buf := bytes.NewBuffer(nil)
-	buf.ReadFrom(resp.Body)
-
-	if resp.StatusCode != 200 {
-		exception := HadoopException{}
-		err = json.Unmarshal(buf.Bytes(), &exception)
-
-		if err != nil {
-			panic(err)
-		}
-		switch resp.StatusCode {
-		case 404:
-			panic(herr.ErrExist)
-		case 403:
-			panic(herr.ErrAccess)
-		default:
-			panic(exception)
-		}
-	}
-
+func (hadoop *HadoopController) TruncateFile(ctx context.Context, filepath string, newlength int64) (result bool, err error) {
 	booleanRes := BooleanResp{}
-	err = json.Unmarshal(buf.Bytes(), &booleanRes)
+	err = hadoop.call(ctx, webhdfsRequest{
+		method:      http.MethodPost,
+		op:          opTruncate,
+		path:        filepath,
+		params:      map[string]string{"newlength": strconv.FormatInt(newlength, 10)},
+		contentType: "application/json",
+	}, &booleanRes)
 
 	if err != nil {
-		panic(err)
+		return false, err
 	}
 
-	return booleanRes.Boolean, err
+	return booleanRes.Boolean, nil
 }
 
 // Delete 删除文件或者目录
-func (hadoop *HadoopController) Delete(filepath string) (result bool, err error) {
-
-	defer recoverError(&err)
-
-	url := hadoop.urlJoin(filepath, opDelete)
-
-	req, err := http.NewRequest("DELETE", url, nil)
-
-	if err != nil {
-		panic(err)
-	}
-
-	resp, err := http.DefaultClient.Do(req)
-
-	if err != nil {
-		panic(err)
-	}
-
-	defer resp.Body.Close()
-
-	buf := bytes.NewBuffer(nil)
-	buf.ReadFrom(resp.Body)
-
-	if resp.StatusCode != 200 {
-		exception := HadoopException{}
-		err = json.Unmarshal(buf.Bytes(), &exception)
-
-		if err != nil {
-			panic(err)
-		}
-		switch resp.StatusCode {
-		case 404:
-			panic(herr.ErrExist)
-		case 403:
-			panic(herr.ErrAccess)
-		default:
-			panic(exception)
-		}
-	}
+func (hadoop *HadoopController) Delete(ctx context.Context, filepath string) (result bool, err error) {
 
 	booleanRes := BooleanResp{}
-	err = json.Unmarshal(buf.Bytes(), &booleanRes)
+	err = hadoop.call(ctx, webhdfsRequest{
+		method: http.MethodDelete,
+		op:     opDelete,
+		path:   filepath,
+	}, &booleanRes)
 
 	if err != nil {
-		panic(err)
+		return false, err
 	}
 
-	return booleanRes.Boolean, err
+	return booleanRes.Boolean, nil
 }
 
 // SetPermission 设置文件权限
-func (hadoop *HadoopController) SetPermission(filepath, permission string) (err error) {
-	defer recoverError(&err)
+func (hadoop *HadoopController) SetPermission(ctx context.Context, filepath, permission string) (err error) {
+	err = hadoop.call(ctx, webhdfsRequest{
+		method: http.MethodPut,
+		op:     opSetPermission,
+		path:   filepath,
+		params: map[string]string{"permission": permission},
+	}, nil)
 
-	url := hadoop.urlJoin(filepath, opSetPermission)
-	url = urlAddParam(url, "permission", permission)
-
-	req, err := http.NewRequest("PUT", url, nil)
-
-	if err != nil {
-		panic(err)
-	}
+	return err
+}
 
-	resp, err := http.DefaultClient.Do(req)
+// SetOwner 设置文件的owner和group，为空的不修改。修改owner需要HDFS的超级用户
+func (hadoop *HadoopController) SetOwner(ctx context.Context, filepath, owner, group string) (err error) {
+	err = hadoop.call(ctx, webhdfsRequest{
+		method: http.MethodPut,
+		op:     opSetOwner,
+		path:   filepath,
+		params: map[string]string{"owner": owner, "group": group},
+	}, nil)
 
-	if err != nil {
-		panic(err)
-	}
+	return err
+}
 
-	defer resp.Body.Close()
-
-	buf := bytes.NewBuffer(nil)
-	buf.ReadFrom(resp.Body)
-
-	if resp.StatusCode != 200 {
-		exception := HadoopException{}
-		err = json.Unmarshal(buf.Bytes(), &exception)
-
-		if err != nil {
-			panic(err)
-		}
-		switch resp.StatusCode {
-		case 404:
-			panic(herr.ErrExist)
-		case 403:
-			panic(herr.ErrAccess)
-		default:
-			panic(exception)
-		}
-	}
+// CheckAccess 检查当前用户(或者代理的用户)对文件是否有 fsaction 的权限，比如 "rw-"，
+// 没有权限时返回 herr.ErrAccess，NameNode不支持CHECKACCESS(Hadoop 2.6之前)时返回 herr.ErrNotsup
+func (hadoop *HadoopController) CheckAccess(ctx context.Context, filepath, fsaction string) (err error) {
+	err = hadoop.call(ctx, webhdfsRequest{
+		method: http.MethodGet,
+		op:     opCheckAccess,
+		path:   filepath,
+		params: map[string]string{"fsaction": fsaction},
+		errors: map[int]error{400: herr.ErrNotsup},
+	}, nil)
 
 	return err
 }
 
 // Rename 文件重命名
-func (hadoop *HadoopController) Rename(src, dest string) (result bool, err error) {
-	defer recoverError(&err)
-
-	url := hadoop.urlJoin(src, opRename)
-	url = urlAddParam(url, "destination", dest)
-
-	req, err := http.NewRequest("PUT", url, nil)
-
-	if err != nil {
-		panic(err)
-	}
-
-	resp, err := http.DefaultClient.Do(req)
-
-	if err != nil {
-		panic(err)
-	}
-
-	defer resp.Body.Close()
-
-	buf := bytes.NewBuffer(nil)
-	buf.ReadFrom(resp.Body)
-
-	if resp.StatusCode != 200 {
-		exception := HadoopException{}
-		err = json.Unmarshal(buf.Bytes(), &exception)
-
-		if err != nil {
-			panic(err)
-		}
-		switch resp.StatusCode {
-		case 404:
-			panic(herr.ErrExist)
-		case 403:
-			panic(herr.ErrAccess)
-		default:
-			panic(exception)
-		}
-	}
-
+func (hadoop *HadoopController) Rename(ctx context.Context, src, dest string) (result bool, err error) {
 	booleanRes := BooleanResp{}
-	err = json.Unmarshal(buf.Bytes(), &booleanRes)
+	err = hadoop.call(ctx, webhdfsRequest{
+		method: http.MethodPut,
+		op:     opRename,
+		path:   src,
+		params: map[string]string{"destination": dest},
+	}, &booleanRes)
 
 	if err != nil {
-		panic(err)
+		return false, err
 	}
 
-	return booleanRes.Boolean, err
+	return booleanRes.Boolean, nil
 }
 
 // CreateSymlink 创建软连接
-func (hadoop *HadoopController) CreateSymlink(src, link string) (err error) {
-	defer recoverError(&err)
-
-	url := hadoop.urlJoin(src, opCreateSymlink)
-	url = urlAddParam(url, "destination", link)
-
-	req, err := http.NewRequest("PUT", url, nil)
-
-	if err != nil {
-		panic(err)
-	}
-
-	resp, err := http.DefaultClient.Do(req)
-
-	if err != nil {
-		panic(err)
-	}
-
-	defer resp.Body.Close()
-
-	buf := bytes.NewBuffer(nil)
-	buf.ReadFrom(resp.Body)
-
-	if resp.StatusCode != 200 {
-		exception := HadoopException{}
-		err = json.Unmarshal(buf.Bytes(), &exception)
-
-		if err != nil {
-			panic(err)
-		}
-		switch resp.StatusCode {
-		case 404:
-			panic(herr.ErrExist)
-		case 403:
-			panic(herr.ErrAccess)
-		default:
-			panic(exception)
-		}
-	}
+func (hadoop *HadoopController) CreateSymlink(ctx context.Context, src, link string) (err error) {
+	err = hadoop.call(ctx, webhdfsRequest{
+		method: http.MethodPut,
+		op:     opCreateSymlink,
+		path:   src,
+		params: map[string]string{"destination": link},
+	}, nil)
 
 	return err
 }
 
 // Setxattr setxattr
-func (hadoop *HadoopController) Setxattr(filepath, name, value, flag string) (err error) {
-	defer recoverError(&err)
-
-	url := hadoop.urlJoin(filepath, opSetXattr)
-	url = urlAddParam(url, "xattr.name", name)
-	url = urlAddParam(url, "xattr.value", value)
-	url = urlAddParam(url, "flag", flag)
-
-	req, err := http.NewRequest("PUT", url, nil)
-
-	if err != nil {
-		panic(err)
+func (hadoop *HadoopController) Setxattr(ctx context.Context, filepath, name, value, flag string) (err error) {
+	existErr := herr.ErrExist
+	if flag == "REPLACE" {
+		existErr = herr.ErrNoAttr
 	}
 
-	resp, err := http.DefaultClient.Do(req)
-
-	if err != nil {
-		panic(err)
-	}
-
-	defer resp.Body.Close()
-
-	buf := bytes.NewBuffer(nil)
-	buf.ReadFrom(resp.Body)
-
-	if resp.StatusCode != 200 {
-		exception := HadoopException{}
-		err = json.Unmarshal(buf.Bytes(), &exception)
-
-		if err != nil {
-			panic(err)
-		}
-		switch resp.StatusCode {
-		case 400:
-			panic(herr.ErrNotsup)
-		case 404:
-			panic(herr.ErrNoFound)
-		case 403:
-			// xattr已经存在
-			panic(herr.ErrExist)
-		default:
-			panic(exception)
-		}
-	}
+	err = hadoop.call(ctx, webhdfsRequest{
+		method: http.MethodPut,
+		op:     opSetXattr,
+		path:   filepath,
+		params: map[string]string{"xattr.name": name, "xattr.value": value, "flag": flag},
+		// 400 是namespace不合法，403 是xattr已经存在(CREATE)或者不存在(REPLACE)
+		errors: map[int]error{400: herr.ErrNotsup, 403: existErr},
+	}, nil)
 
 	return err
 }
 
 // Getxattr getxattr
-func (hadoop *HadoopController) Getxattr(filepath, name string) (value string, err error) {
-	recoverError(&err)
-
-	url := hadoop.urlJoin(filepath, opGetXattr)
-	url = urlAddParam(url, "xattr.name", name)
-	url = urlAddParam(url, "encoding", "text")
-
-	resp, err := http.Get(url)
+func (hadoop *HadoopController) Getxattr(ctx context.Context, filepath, name string) (value string, err error) {
+	attrs := XattrsResp{}
+	err = hadoop.call(ctx, webhdfsRequest{
+		method: http.MethodGet,
+		op:     opGetXattr,
+		path:   filepath,
+		params: map[string]string{"xattr.name": name, "encoding": "text"},
+		// 403 是xattr不存在
+		errors: map[int]error{403: herr.ErrNoAttr},
+	}, &attrs)
 
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 
-	defer resp.Body.Close()
-
-	buf := bytes.NewBuffer(nil)
-	buf.ReadFrom(resp.Body)
-
-	if resp.StatusCode != 200 {
-		exception := HadoopException{}
-		err = json.Unmarshal(buf.Bytes(), &exception)
-
-		if err != nil {
-			panic(err)
-		}
-		switch resp.StatusCode {
-		case 404:
-			panic(herr.ErrNoFound)
-		default:
-			panic(exception)
-		}
+	if len(attrs.Xattrs) == 0 {
+		return "", herr.ErrNoAttr
 	}
 
-	attrs := XattrsResp{}
-	err = json.Unmarshal(buf.Bytes(), &attrs)
+	value = decodeXattrValue(attrs.Xattrs[0].Value)
 
-	if err != nil {
-		panic(err)
-	}
-
-	attr := attrs.Xattrs[0]
-	value = attr.Value
-
-	return value, err
+	return value, nil
 }
 
 // Listxattr lisstxattr
-func (hadoop *HadoopController) Listxattr(filepath string) (attrs []Xattr, err error) {
-	recoverError(&err)
-
-	url := hadoop.urlJoin(filepath, opGetXattr)
-	url = urlAddParam(url, "encoding", "text")
-
-	resp, err := http.Get(url)
-
-	if err != nil {
-		panic(err)
-	}
-
-	defer resp.Body.Close()
-
-	buf := bytes.NewBuffer(nil)
-	buf.ReadFrom(resp.Body)
-
-	if resp.StatusCode != 200 {
-		exception := HadoopException{}
-		err = json.Unmarshal(buf.Bytes(), &exception)
-
-		if err != nil {
-			panic(err)
-		}
-		switch resp.StatusCode {
-		case 404:
-			panic(herr.ErrNoFound)
-		default:
-			panic(exception)
-		}
-	}
-
+func (hadoop *HadoopController) Listxattr(ctx context.Context, filepath string) (attrs []Xattr, err error) {
 	attrsresp := XattrsResp{}
-	err = json.Unmarshal(buf.Bytes(), &attrsresp)
+	err = hadoop.call(ctx, webhdfsRequest{
+		method: http.MethodGet,
+		op:     opGetXattr,
+		path:   filepath,
+		params: map[string]string{"encoding": "text"},
+	}, &attrsresp)
 
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	attrs = attrsresp.Xattrs
+	for i := range attrs {
+		attrs[i].Value = decodeXattrValue(attrs[i].Value)
+	}
 
-	return attrs, err
+	return attrs, nil
 }
 
 // Removexattr removexattr
-func (hadoop *HadoopController) Removexattr(filepath, name string) (err error) {
-	recoverError(&err)
+func (hadoop *HadoopController) Removexattr(ctx context.Context, filepath, name string) (err error) {
+	err = hadoop.call(ctx, webhdfsRequest{
+		method: http.MethodPut,
+		op:     opRemoveXattr,
+		path:   filepath,
+		params: map[string]string{"xattr.name": name},
+		// 400 是namespace不合法，403 是xattr不存在
+		errors: map[int]error{400: herr.ErrNotsup, 403: herr.ErrNoAttr},
+	}, nil)
 
-	url := hadoop.urlJoin(filepath, opRemoveXattr)
-	url = urlAddParam(url, "xattr.name", name)
+	return err
+}
 
-	req, err := http.NewRequest("PUT", url, nil)
+// send 发送请求，设置了认证方式时，会先添加认证信息，返回401时重新认证后重试一次
+func (hadoop *HadoopController) send(req *http.Request) (*http.Response, error) {
 
-	if err != nil {
-		panic(err)
+	if hadoop.auth == nil || req.URL.Query().Get("delegation") != "" {
+		// 带有delegation token的请求不需要再认证
+		return hadoop.client.Do(req)
+	}
+
+	if err := hadoop.auth.Authenticate(req); err != nil {
+		logger.Error.Printf("authenticate failed: %s\n", err)
+		return nil, herr.ErrAuth
+	}
+
+	resp, err := hadoop.client.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
 	}
+	resp.Body.Close()
 
-	resp, err := http.DefaultClient.Do(req)
+	logger.Warning.Printf("%s %s: unauthorized, re-authenticate and retry\n", req.Method, req.URL.Path)
 
+	retry, err := cloneRequest(req)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	defer resp.Body.Close()
-
-	buf := bytes.NewBuffer(nil)
-	buf.ReadFrom(resp.Body)
-
-	if resp.StatusCode != 200 {
-		exception := HadoopException{}
-		err = json.Unmarshal(buf.Bytes(), &exception)
-
-		if err != nil {
-			panic(err)
-		}
-		switch resp.StatusCode {
-		case 400:
-			panic(herr.ErrNotsup)
-		case 403:
-			panic(herr.ErrNoAttr)
-		case 404:
-			panic(herr.ErrNoFound)
-		default:
-			panic(exception)
-		}
+	if err = hadoop.auth.Refresh(); err == nil {
+		err = hadoop.auth.Authenticate(retry)
+	}
+	if err != nil {
+		logger.Error.Printf("authenticate failed: %s\n", err)
+		return nil, herr.ErrAuth
 	}
 
-	return err
-}
+	resp, err = hadoop.client.Do(retry)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		return nil, herr.ErrAuth
+	}
 
-func urlAddParam(url, name, val string) string {
-	return url + "&" + name + "=" + val
+	return resp, err
 }
@@ -0,0 +1,401 @@
+package controler
+
+import (
+	"context"
+	"errors"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"hadoop-fs/fs/controler/webhdfstest"
+	"hadoop-fs/fs/model"
+	"net/http"
+	"testing"
+)
+
+const testUser = "tester"
+
+func newTestController(t *testing.T) (*HadoopController, *webhdfstest.Server) {
+	t.Helper()
+
+	server := webhdfstest.NewServer()
+	t.Cleanup(server.Close)
+
+	hadoop := &HadoopController{}
+	hadoop.Init(false, server.Host(), server.Port(), testUser)
+
+	return hadoop, server
+}
+
+func TestList(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/dir/a", []byte("a"))
+	server.AddFile("/dir/b", []byte("bb"))
+	server.AddDir("/dir/c")
+
+	files, remain, err := hadoop.List(context.Background(), "/dir", "")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if remain != 0 || len(files) != 3 {
+		t.Fatalf("List: got %d files, remain %d", len(files), remain)
+	}
+	if files[0].Name != "a" || files[1].Name != "b" || files[2].Name != "c" {
+		t.Errorf("List: got names %q %q %q", files[0].Name, files[1].Name, files[2].Name)
+	}
+	if files[1].StSize != 2 || files[2].HadoopType != model.HadoopDir {
+		t.Errorf("List: unexpected status %+v %+v", files[1], files[2])
+	}
+
+	if req, _ := server.LastRequest("LISTSTATUS_BATCH"); req.Query.Get("user.name") != testUser {
+		t.Errorf("List: user.name = %q", req.Query.Get("user.name"))
+	}
+}
+
+func TestListBatch(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.ListLimit = 2
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		server.AddFile("/dir/"+name, nil)
+	}
+
+	files, remain, err := hadoop.List(context.Background(), "/dir", "")
+	if err != nil || len(files) != 2 || remain != 3 {
+		t.Fatalf("List: got %d files, remain %d, err %v", len(files), remain, err)
+	}
+
+	files, remain, err = hadoop.List(context.Background(), "/dir", files[1].Name)
+	if err != nil || len(files) != 2 || remain != 1 || files[0].Name != "c" {
+		t.Fatalf("List startAfter: got %+v, remain %d, err %v", files, remain, err)
+	}
+}
+
+func TestListNotFound(t *testing.T) {
+	hadoop, _ := newTestController(t)
+
+	if _, _, err := hadoop.List(context.Background(), "/missing", ""); !errors.Is(err, herr.ErrNoFound) {
+		t.Errorf("List: got err %v, want ErrNoFound", err)
+	}
+}
+
+func TestGetFileStatus(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/file", []byte("hello"))
+
+	file, err := hadoop.GetFileStatus(context.Background(), "/file")
+	if err != nil {
+		t.Fatalf("GetFileStatus: %v", err)
+	}
+	if file.StSize != 5 || file.HadoopType != model.HadoopFile || file.HadoopPermission != "644" {
+		t.Errorf("GetFileStatus: unexpected status %+v", file)
+	}
+
+	if _, err = hadoop.GetFileStatus(context.Background(), "/missing"); !errors.Is(err, herr.ErrNoFound) {
+		t.Errorf("GetFileStatus: got err %v, want ErrNoFound", err)
+	}
+}
+
+func TestRead(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/file", []byte("hello world"))
+
+	content, err := hadoop.Read(context.Background(), "/file", 6, 5, 0)
+	if err != nil || string(content) != "world" {
+		t.Fatalf("Read: got %q, err %v", content, err)
+	}
+
+	if _, err = hadoop.Read(context.Background(), "/file", 100, 5, 0); !errors.Is(err, herr.ErrEOF) {
+		t.Errorf("Read out of range: got err %v, want ErrEOF", err)
+	}
+	if _, err = hadoop.Read(context.Background(), "/missing", 0, 5, 0); !errors.Is(err, herr.ErrNoFound) {
+		t.Errorf("Read missing: got err %v, want ErrNoFound", err)
+	}
+}
+
+func TestMakeDir(t *testing.T) {
+	hadoop, server := newTestController(t)
+
+	ok, err := hadoop.MakeDir(context.Background(), "/a/b", "700")
+	if err != nil || !ok {
+		t.Fatalf("MakeDir: got %v, err %v", ok, err)
+	}
+	status, exist := server.Status("/a/b")
+	if !exist || status.Type != model.HadoopDir || status.Permission != "700" {
+		t.Errorf("MakeDir: unexpected status %+v", status)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	hadoop, server := newTestController(t)
+
+	if err := hadoop.Create(context.Background(), "/file", "600"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	status, exist := server.Status("/file")
+	if !exist || status.Type != model.HadoopFile || status.Permission != "600" || status.Length != 0 {
+		t.Errorf("Create: unexpected status %+v", status)
+	}
+	if status.Owner != testUser {
+		t.Errorf("Create: owner = %q, want %q", status.Owner, testUser)
+	}
+
+	if err := hadoop.Create(context.Background(), "/file", "600"); !errors.Is(err, herr.ErrExist) {
+		t.Errorf("Create existing: got err %v, want ErrExist", err)
+	}
+}
+
+func TestModificationTime(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/file", nil)
+
+	if err := hadoop.ModificationTime(context.Background(), "/file", 1000, 2000); err != nil {
+		t.Fatalf("ModificationTime: %v", err)
+	}
+	status, _ := server.Status("/file")
+	if status.ModificationTime != 1000 || status.AccessTime != 2000 {
+		t.Errorf("ModificationTime: got mtime %d, atime %d", status.ModificationTime, status.AccessTime)
+	}
+
+	if err := hadoop.ModificationTime(context.Background(), "/file", -1, 3000); err != nil {
+		t.Fatalf("ModificationTime: %v", err)
+	}
+	status, _ = server.Status("/file")
+	if status.ModificationTime != 1000 || status.AccessTime != 3000 {
+		t.Errorf("ModificationTime -1: got mtime %d, atime %d", status.ModificationTime, status.AccessTime)
+	}
+}
+
+func TestAppendFile(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/file", []byte("hello"))
+
+	if err := hadoop.AppendFile(context.Background(), "/file", []byte(" world")); err != nil {
+		t.Fatalf("AppendFile: %v", err)
+	}
+	if content, _ := server.Content("/file"); string(content) != "hello world" {
+		t.Errorf("AppendFile: content = %q", content)
+	}
+
+	if err := hadoop.AppendFile(context.Background(), "/missing", []byte("x")); err == nil {
+		t.Errorf("AppendFile missing: expected error")
+	}
+}
+
+func TestTruncateFile(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/file", []byte("hello world"))
+
+	ok, err := hadoop.TruncateFile(context.Background(), "/file", 5)
+	if err != nil || !ok {
+		t.Fatalf("TruncateFile: got %v, err %v", ok, err)
+	}
+	if content, _ := server.Content("/file"); string(content) != "hello" {
+		t.Errorf("TruncateFile: content = %q", content)
+	}
+
+	if _, err = hadoop.TruncateFile(context.Background(), "/file", 100); err == nil {
+		t.Errorf("TruncateFile larger: expected error")
+	}
+}
+
+func TestDelete(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/dir/file", nil)
+
+	if _, err := hadoop.Delete(context.Background(), "/dir"); !errors.Is(err, herr.ErrNotEmpty) {
+		t.Errorf("Delete non-empty dir: got err %v, want ErrNotEmpty", err)
+	}
+
+	ok, err := hadoop.Delete(context.Background(), "/dir/file")
+	if err != nil || !ok {
+		t.Fatalf("Delete: got %v, err %v", ok, err)
+	}
+	if _, exist := server.Status("/dir/file"); exist {
+		t.Errorf("Delete: file still exists")
+	}
+
+	ok, err = hadoop.Delete(context.Background(), "/dir/file")
+	if err != nil || ok {
+		t.Errorf("Delete missing: got %v, err %v", ok, err)
+	}
+}
+
+func TestSetPermission(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/file", nil)
+
+	if err := hadoop.SetPermission(context.Background(), "/file", "1750"); err != nil {
+		t.Fatalf("SetPermission: %v", err)
+	}
+	if status, _ := server.Status("/file"); status.Permission != "1750" {
+		t.Errorf("SetPermission: permission = %q", status.Permission)
+	}
+
+	if err := hadoop.SetPermission(context.Background(), "/missing", "755"); !errors.Is(err, herr.ErrNoFound) {
+		t.Errorf("SetPermission missing: got err %v, want ErrNoFound", err)
+	}
+}
+
+func TestRename(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/src/file", []byte("data"))
+	server.AddFile("/other", nil)
+
+	ok, err := hadoop.Rename(context.Background(), "/src", "/dest")
+	if err != nil || !ok {
+		t.Fatalf("Rename: got %v, err %v", ok, err)
+	}
+	if content, exist := server.Content("/dest/file"); !exist || string(content) != "data" {
+		t.Errorf("Rename: /dest/file = %q, %v", content, exist)
+	}
+
+	ok, err = hadoop.Rename(context.Background(), "/dest/file", "/other")
+	if err != nil || ok {
+		t.Errorf("Rename onto existing file: got %v, err %v", ok, err)
+	}
+}
+
+func TestCreateSymlink(t *testing.T) {
+	hadoop, _ := newTestController(t)
+
+	err := hadoop.CreateSymlink(context.Background(), "/target", "/link")
+	exception, ok := err.(HadoopException)
+	if !ok || exception.RemoteException.Exception != "UnsupportedOperationException" {
+		t.Errorf("CreateSymlink: got err %v", err)
+	}
+}
+
+func TestXattr(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/file", nil)
+
+	if err := hadoop.Setxattr(context.Background(), "/file", "user.a", "1", "CREATE"); err != nil {
+		t.Fatalf("Setxattr: %v", err)
+	}
+	if err := hadoop.Setxattr(context.Background(), "/file", "user.a", "2", "CREATE"); !errors.Is(err, herr.ErrExist) {
+		t.Errorf("Setxattr CREATE existing: got err %v, want ErrExist", err)
+	}
+	if err := hadoop.Setxattr(context.Background(), "/file", "user.missing", "2", "REPLACE"); !errors.Is(err, herr.ErrNoAttr) {
+		t.Errorf("Setxattr REPLACE missing: got err %v, want ErrNoAttr", err)
+	}
+	if err := hadoop.Setxattr(context.Background(), "/file", "user.a", "2", "REPLACE"); err != nil {
+		t.Fatalf("Setxattr REPLACE: %v", err)
+	}
+	if err := hadoop.Setxattr(context.Background(), "/file", "bad", "1", "CREATE"); !errors.Is(err, herr.ErrNotsup) {
+		t.Errorf("Setxattr bad namespace: got err %v, want ErrNotsup", err)
+	}
+	if err := hadoop.Setxattr(context.Background(), "/file", "user.b", "3", "CREATE"); err != nil {
+		t.Fatalf("Setxattr: %v", err)
+	}
+
+	value, err := hadoop.Getxattr(context.Background(), "/file", "user.a")
+	if err != nil || value != "2" {
+		t.Errorf("Getxattr: got %q, err %v", value, err)
+	}
+	if _, err = hadoop.Getxattr(context.Background(), "/file", "user.missing"); !errors.Is(err, herr.ErrNoAttr) {
+		t.Errorf("Getxattr missing: got err %v, want ErrNoAttr", err)
+	}
+
+	attrs, err := hadoop.Listxattr(context.Background(), "/file")
+	if err != nil || len(attrs) != 2 || attrs[0].Name != "user.a" || attrs[1].Value != "3" {
+		t.Errorf("Listxattr: got %+v, err %v", attrs, err)
+	}
+
+	if err = hadoop.Removexattr(context.Background(), "/file", "user.a"); err != nil {
+		t.Fatalf("Removexattr: %v", err)
+	}
+	if err = hadoop.Removexattr(context.Background(), "/file", "user.a"); !errors.Is(err, herr.ErrNoAttr) {
+		t.Errorf("Removexattr missing: got err %v, want ErrNoAttr", err)
+	}
+	if attrs := server.Xattrs("/file"); len(attrs) != 1 || attrs["user.b"] != "3" {
+		t.Errorf("Removexattr: xattrs = %v", attrs)
+	}
+}
+
+func TestRemoteException(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/file", nil)
+	server.Fail("GETFILESTATUS", http.StatusForbidden, "SafeModeException",
+		"org.apache.hadoop.hdfs.server.namenode.SafeModeException", "Name node is in safe mode.")
+
+	_, err := hadoop.GetFileStatus(context.Background(), "/file")
+	exception, ok := err.(HadoopException)
+	if !ok || exception.RemoteException.JavaClassName != "org.apache.hadoop.hdfs.server.namenode.SafeModeException" {
+		t.Fatalf("GetFileStatus: got err %v", err)
+	}
+
+	if _, err = hadoop.GetFileStatus(context.Background(), "/file"); err != nil {
+		t.Errorf("GetFileStatus after failure: %v", err)
+	}
+}
+
+func TestGetHomeDirectory(t *testing.T) {
+	hadoop, _ := newTestController(t)
+
+	home, err := hadoop.GetHomeDirectory(context.Background())
+	if err != nil || home != "/user/"+testUser {
+		t.Errorf("GetHomeDirectory: got %q, err %v", home, err)
+	}
+}
+
+func TestSetOwner(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.Owner = testUser
+	server.AddFile("/dir/a", []byte("a"))
+
+	ctx := context.Background()
+
+	// 非超级用户只能修改group
+	if err := hadoop.SetOwner(ctx, "/dir/a", "", "analysts"); err != nil {
+		t.Fatalf("SetOwner group: %v", err)
+	}
+	if req, _ := server.LastRequest(opSetOwner); req.Query.Has("owner") {
+		t.Errorf("empty owner should not be sent: %v", req.Query)
+	}
+	if err := hadoop.SetOwner(ctx, "/dir/a", "bob", ""); !errors.Is(err, herr.ErrAccess) {
+		t.Errorf("SetOwner by non-superuser: got %v, want ErrAccess", err)
+	}
+
+	server.Superuser = testUser
+	if err := hadoop.SetOwner(ctx, "/dir/a", "bob", "staff"); err != nil {
+		t.Fatalf("SetOwner by superuser: %v", err)
+	}
+	if status, _ := server.Status("/dir/a"); status.Owner != "bob" || status.Group != "staff" {
+		t.Errorf("owner and group after SetOwner: %s:%s", status.Owner, status.Group)
+	}
+
+	if err := hadoop.SetOwner(ctx, "/missing", "bob", ""); !errors.Is(err, herr.ErrNoFound) {
+		t.Errorf("SetOwner of missing file: got %v, want ErrNoFound", err)
+	}
+}
+
+func TestCheckAccess(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.Owner = testUser
+	server.AddFile("/dir/a", []byte("a"))
+
+	ctx := context.Background()
+	for action, want := range map[string]error{"rw-": nil, "r-x": herr.ErrAccess} {
+		if err := hadoop.CheckAccess(ctx, "/dir/a", action); !errors.Is(err, want) {
+			t.Errorf("CheckAccess(%s): got %v, want %v", action, err, want)
+		}
+	}
+
+	// 代理的用户不是owner，使用other的权限
+	proxied := WithProxyUser(ctx, "bob")
+	if err := hadoop.CheckAccess(proxied, "/dir/a", "r--"); err != nil {
+		t.Errorf("CheckAccess as bob r--: %v", err)
+	}
+	if err := hadoop.CheckAccess(proxied, "/dir/a", "-w-"); !errors.Is(err, herr.ErrAccess) {
+		t.Errorf("CheckAccess as bob -w-: got %v, want ErrAccess", err)
+	}
+	if req, _ := server.LastRequest(opCheckAccess); req.Query.Get("fsaction") != "-w-" || req.Query.Get("doas") != "bob" {
+		t.Errorf("CHECKACCESS query: %v", req.Query)
+	}
+
+	if err := hadoop.CheckAccess(ctx, "/missing", "r--"); !errors.Is(err, herr.ErrNoFound) {
+		t.Errorf("CheckAccess of missing file: got %v, want ErrNoFound", err)
+	}
+
+	server.Disable(opCheckAccess)
+	if err := hadoop.CheckAccess(ctx, "/dir/a", "r--"); !errors.Is(err, herr.ErrNotsup) {
+		t.Errorf("CheckAccess not supported: got %v, want ErrNotsup", err)
+	}
+}
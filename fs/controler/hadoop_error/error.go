@@ -25,3 +25,30 @@ var ErrNotsup = errors.New("The namespace prefix of name is not valid")
 
 // ErrRange Math result not representable
 var ErrRange = errors.New("Math result not representable")
+
+// ErrAuth Authentication failed
+var ErrAuth = errors.New("Authentication failed")
+
+// ErrNotDir Not a directory
+var ErrNotDir = errors.New("Not a directory")
+
+// ErrNotEmpty Directory not empty
+var ErrNotEmpty = errors.New("Directory not empty")
+
+// ErrQuota Quota exceeded
+var ErrQuota = errors.New("Quota exceeded")
+
+// ErrSafeMode NameNode is in safe mode, the file system is read-only
+var ErrSafeMode = errors.New("NameNode is in safe mode")
+
+// ErrLease Lease of the file is expired or held by another client
+var ErrLease = errors.New("Lease expired")
+
+// ErrCrossDevice Invalid cross-device link, such as rename across clusters
+var ErrCrossDevice = errors.New("Invalid cross-device link")
+
+// ErrPerm Operation not permitted, such as chown by a non-superuser
+var ErrPerm = errors.New("Operation not permitted")
+
+// ErrInvalid Invalid argument, such as a malformed ACL
+var ErrInvalid = errors.New("Invalid argument")
@@ -2,6 +2,7 @@ package controler
 
 import (
 	"hadoop-fs/fs/model"
+	"strings"
 )
 
 // FileStatuses from hadoop
@@ -16,13 +17,13 @@ type PartialListing struct {
 
 // DirectoryListing from hadoop
 type DirectoryListing struct {
-	DartialListing PartialListing `json:"partialListing"`
+	DartialListing   PartialListing `json:"partialListing"`
+	RemainingEntries int            `json:"remainingEntries"`
 }
 
 // ListStatusBatch from hadoop
 type ListStatusBatch struct {
 	DirectoryListing DirectoryListing `json:"DirectoryListing"`
-	PemainingEntries int              `json:"remainingEntries"`
 }
 
 // GetFiles return the FileStatuses in ListStatusBatch
@@ -30,6 +31,11 @@ func (lsb *ListStatusBatch) GetFiles() []model.FileModel {
 	return lsb.DirectoryListing.DartialListing.PileStatuses.FileStatuses
 }
 
+// GetRemaining return the number of entries remaining after this batch
+func (lsb *ListStatusBatch) GetRemaining() int {
+	return lsb.DirectoryListing.RemainingEntries
+}
+
 // GetFileStatus from hadoop
 type GetFileStatus struct {
 	GetFileStatus model.FileModel `json:"FileStatus"`
@@ -46,7 +52,10 @@ type HadoopException struct {
 }
 
 func (hadoop HadoopException) Error() string {
-	return hadoop.RemoteException.Exception
+	if hadoop.RemoteException.Message == "" {
+		return hadoop.RemoteException.Exception
+	}
+	return hadoop.RemoteException.Exception + ": " + hadoop.RemoteException.Message
 }
 
 // RemoteException exception from hadoop
@@ -61,6 +70,41 @@ type BooleanResp struct {
 	Boolean bool `json:"boolean"`
 }
 
+// PathResp response contain path from hadoop
+type PathResp struct {
+	Path string `json:"Path"`
+}
+
+// FsStatusResp response of GETSTATUS from hadoop
+type FsStatusResp struct {
+	FsStatus FsStatus `json:"FsStatus"`
+}
+
+// QuotaUsageResp response of GETQUOTAUSAGE from hadoop
+type QuotaUsageResp struct {
+	QuotaUsage QuotaUsage `json:"QuotaUsage"`
+}
+
+// ContentSummaryResp response of GETCONTENTSUMMARY from hadoop
+type ContentSummaryResp struct {
+	ContentSummary ContentSummary `json:"ContentSummary"`
+}
+
+// ContentSummary from hadoop
+type ContentSummary struct {
+	DirectoryCount int64 `json:"directoryCount"`
+	FileCount      int64 `json:"fileCount"`
+	Length         int64 `json:"length"`
+	Quota          int64 `json:"quota"`
+	SpaceConsumed  int64 `json:"spaceConsumed"`
+	SpaceQuota     int64 `json:"spaceQuota"`
+}
+
+// AclStatusResp response of GETACLSTATUS from hadoop
+type AclStatusResp struct {
+	AclStatus AclStatus `json:"AclStatus"`
+}
+
 // XattrsResp response contain xattrs from hadoop
 type XattrsResp struct {
 	Xattrs []Xattr `json:"XAttrs"`
@@ -71,3 +115,22 @@ type Xattr struct {
 	Name  string `json:"name"`
 	Value string `json:"value"`
 }
+
+// decodeXattrValue encoding=text 时 WebHDFS 返回的值带有双引号，需要去掉
+func decodeXattrValue(value string) string {
+	if len(value) >= 2 && strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"") {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// remoteException 构造一个与WebHDFS返回格式一致的异常
+func remoteException(exception, javaClassName, message string) HadoopException {
+	return HadoopException{
+		RemoteException: RemoteException{
+			Exception:     exception,
+			JavaClassName: javaClassName,
+			Message:       message,
+		},
+	}
+}
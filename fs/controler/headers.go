@@ -0,0 +1,43 @@
+package controler
+
+import (
+	"net/http"
+)
+
+// DefaultCSRFHeader 开启了 dfs.webhdfs.rest-csrf.enabled 的集群要求的请求头，
+// 对应 dfs.webhdfs.rest-csrf.custom-header 的默认值
+const DefaultCSRFHeader = "X-XSRF-HEADER"
+
+// csrfIgnoreMethods 不需要CSRF请求头的方法，对应 dfs.webhdfs.rest-csrf.methods-to-ignore 的默认值
+var csrfIgnoreMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodOptions: true,
+	http.MethodHead:    true,
+	http.MethodTrace:   true,
+}
+
+// SetHeaders 设置所有请求都带上的请求头，包括发往DataNode的请求
+func (hadoop *HadoopController) SetHeaders(headers http.Header) {
+	hadoop.headers = headers.Clone()
+}
+
+// SetCSRFHeader 设置PUT、POST、DELETE等修改操作带上的CSRF请求头，为空时不发送
+func (hadoop *HadoopController) SetCSRFHeader(name string) {
+	hadoop.csrfHeader = http.CanonicalHeaderKey(name)
+}
+
+// setHeaders 为请求添加自定义的请求头和CSRF请求头
+func (hadoop *HadoopController) setHeaders(req *http.Request) {
+
+	for name, values := range hadoop.headers {
+		req.Header.Del(name)
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+
+	if hadoop.csrfHeader != "" && !csrfIgnoreMethods[req.Method] && req.Header.Get(hadoop.csrfHeader) == "" {
+		// 服务端只检查请求头是否存在，与Hadoop的WebHdfsFileSystem一样使用 ""
+		req.Header.Set(hadoop.csrfHeader, `""`)
+	}
+}
@@ -0,0 +1,66 @@
+package controler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestCSRFHeader(t *testing.T) {
+	ctx := context.Background()
+	hadoop, server := newTestController(t)
+	server.CSRFHeader = DefaultCSRFHeader
+
+	if _, err := hadoop.MakeDir(ctx, "/dir", "755"); err != nil {
+		t.Fatalf("MakeDir: %v", err)
+	}
+	if err := hadoop.Create(ctx, "/dir/file", "644"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := hadoop.AppendFile(ctx, "/dir/file", []byte("data")); err != nil {
+		t.Fatalf("AppendFile: %v", err)
+	}
+	if _, err := hadoop.Rename(ctx, "/dir/file", "/dir/renamed"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if content, err := hadoop.Read(ctx, "/dir/renamed", 0, 10, 0); err != nil || string(content) != "data" {
+		t.Errorf("Read: got %q, err %v", content, err)
+	}
+	if _, err := hadoop.Delete(ctx, "/dir/renamed"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	for _, req := range server.Requests() {
+		_, sent := req.Header[http.CanonicalHeaderKey(DefaultCSRFHeader)]
+		if sent != (req.Method != http.MethodGet) {
+			t.Errorf("%s %s (DataNode %v): CSRF header sent %v", req.Method, req.Op, req.DataNode, sent)
+		}
+	}
+
+	// 不发送时被拒绝
+	hadoop.SetCSRFHeader("")
+	if _, err := hadoop.MakeDir(ctx, "/other", "755"); err == nil {
+		t.Errorf("MakeDir without CSRF header: expected error")
+	}
+}
+
+func TestCustomHeaders(t *testing.T) {
+	ctx := context.Background()
+	hadoop, server := newTestController(t)
+	server.AddFile("/file", []byte("data"))
+
+	hadoop.SetHeaders(http.Header{"x-request-source": []string{"hadoop-fs"}})
+
+	if _, err := hadoop.GetFileStatus(ctx, "/file"); err != nil {
+		t.Fatalf("GetFileStatus: %v", err)
+	}
+	if _, err := hadoop.Read(ctx, "/file", 0, 10, 0); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	for _, req := range server.Requests() {
+		if got := req.Header.Get("X-Request-Source"); got != "hadoop-fs" {
+			t.Errorf("%s (DataNode %v): X-Request-Source = %q", req.Op, req.DataNode, got)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package controler
+
+import (
+	"errors"
+	"fmt"
+	"hadoop-fs/fs/model"
+	"hash/fnv"
+	"net/http"
+	"sync/atomic"
+)
+
+// 服务端的类型
+const (
+	// GatewayAuto 先按WebHDFS访问，CREATE或APPEND被重定向到带data=true的地址时切换成HttpFS
+	GatewayAuto = "auto"
+	// GatewayWebHDFS NameNode 的 WebHDFS，CREATE、OPEN、APPEND会重定向到DataNode
+	GatewayWebHDFS = "webhdfs"
+	// GatewayHttpFS HttpFS网关，CREATE和APPEND带上data=true直接上传数据
+	GatewayHttpFS = "httpfs"
+)
+
+// 最多跟随的重定向次数
+const maxRedirects = 10
+
+// SetGateway 设置服务端的类型，GatewayAuto、GatewayWebHDFS 或者 GatewayHttpFS
+func (hadoop *HadoopController) SetGateway(gateway string) error {
+
+	switch gateway {
+	case GatewayAuto, GatewayWebHDFS:
+		atomic.StoreInt32(&hadoop.httpfs, 0)
+	case GatewayHttpFS:
+		atomic.StoreInt32(&hadoop.httpfs, 1)
+	default:
+		return fmt.Errorf("unknown gateway [%s], must be %s, %s or %s", gateway, GatewayAuto, GatewayWebHDFS, GatewayHttpFS)
+	}
+
+	hadoop.gateway = gateway
+	return nil
+}
+
+// isHttpFS 服务端是否是HttpFS，包括自动检测到的
+func (hadoop *HadoopController) isHttpFS() bool {
+	return atomic.LoadInt32(&hadoop.httpfs) == 1
+}
+
+// dataParam HttpFS时CREATE和APPEND的data参数
+func (hadoop *HadoopController) dataParam() string {
+	if hadoop.isHttpFS() {
+		return "true"
+	}
+	return ""
+}
+
+// checkRedirect 读写数据的重定向由 call 处理，其它请求正常跟随重定向
+func (hadoop *HadoopController) checkRedirect(req *http.Request, via []*http.Request) error {
+
+	if isDataOp(via[0].URL.Query().Get("op")) {
+		return http.ErrUseLastResponse
+	}
+
+	if len(via) >= maxRedirects {
+		return errors.New("stopped after 10 redirects")
+	}
+
+	return nil
+}
+
+// 生成的fileId的位数，留出高8位给挂载表区分集群(见 MountTable.fileID)
+const pathFileIDBits = 56
+
+// fillFileID 旧版本HttpFS返回的文件信息没有fileId，根据路径的64位FNV hash生成一个，FUSE需要用它作为inode。
+// 这只是尽力而为的替代：不同的路径可能得到相同的inode(find、tar会当作硬链接)，重命名后inode也会改变
+func fillFileID(path string, file *model.FileModel) {
+
+	if file.StIno != 0 {
+		return
+	}
+
+	hash := fnv.New64a()
+	hash.Write([]byte(path))
+	sum := hash.Sum64()
+	file.StIno = (sum ^ sum>>pathFileIDBits) & (1<<pathFileIDBits - 1)
+	if file.StIno <= 1 {
+		// 1 是FUSE根目录的nodeid
+		file.StIno += 2
+	}
+}
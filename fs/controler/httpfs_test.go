@@ -0,0 +1,164 @@
+package controler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"hadoop-fs/fs/controler/webhdfstest"
+	"hadoop-fs/fs/model"
+	"math"
+	"testing"
+)
+
+func newHttpFSTestController(t *testing.T, gateway string) (*HadoopController, *webhdfstest.Server) {
+	t.Helper()
+
+	hadoop, server := newTestController(t)
+	server.HttpFS = true
+	if err := hadoop.SetGateway(gateway); err != nil {
+		t.Fatal(err)
+	}
+
+	return hadoop, server
+}
+
+// uploads 返回op的请求，检查是否带有data=true
+func uploads(server *webhdfstest.Server, op string) (count int, data bool) {
+	for _, req := range server.Requests() {
+		if req.Op == op {
+			count++
+			data = req.Query.Get("data") == "true"
+		}
+	}
+	return count, data
+}
+
+func TestHttpFS(t *testing.T) {
+	ctx := context.Background()
+	hadoop, server := newHttpFSTestController(t, GatewayHttpFS)
+	server.AddDir("/dir")
+
+	if err := hadoop.Create(ctx, "/dir/file", "644"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := hadoop.AppendFile(ctx, "/dir/file", []byte("hello")); err != nil {
+		t.Fatalf("AppendFile: %v", err)
+	}
+	if count, data := uploads(server, "CREATE"); count != 1 || !data {
+		t.Errorf("CREATE: %d requests, data=true %v", count, data)
+	}
+	if count, data := uploads(server, "APPEND"); count != 1 || !data {
+		t.Errorf("APPEND: %d requests, data=true %v", count, data)
+	}
+	if req, _ := server.LastRequest("APPEND"); req.Header.Get("Content-Type") != "application/octet-stream" {
+		t.Errorf("APPEND: Content-Type = %q", req.Header.Get("Content-Type"))
+	}
+
+	if content, err := hadoop.Read(ctx, "/dir/file", 0, 100, 0); err != nil || string(content) != "hello" {
+		t.Errorf("Read: got %q, err %v", content, err)
+	}
+	if content, err := hadoop.Read(ctx, "/dir/file", 100, 10, 0); err != nil || len(content) != 0 {
+		t.Errorf("Read beyond EOF: got %q, err %v", content, err)
+	}
+
+	// 没有fileId时根据路径生成inode，GETFILESTATUS 和 LISTSTATUS_BATCH 要一致
+	file, err := hadoop.GetFileStatus(ctx, "/dir/file")
+	if err != nil || file.StIno <= 1 {
+		t.Fatalf("GetFileStatus: got %+v, err %v", file, err)
+	}
+	files, _, err := hadoop.List(ctx, "/dir", "")
+	if err != nil || len(files) != 1 || files[0].StIno != file.StIno {
+		t.Errorf("List: got %+v, err %v, want inode %d", files, err, file.StIno)
+	}
+
+	// HttpFS 对IOException返回500
+	if err = hadoop.Setxattr(ctx, "/dir/file", "user.a", "1", "CREATE"); err != nil {
+		t.Fatalf("Setxattr: %v", err)
+	}
+	if err = hadoop.Setxattr(ctx, "/dir/file", "user.a", "2", "CREATE"); !errors.Is(err, herr.ErrExist) {
+		t.Errorf("Setxattr existing: got err %v, want ErrExist", err)
+	}
+	if err = hadoop.Removexattr(ctx, "/dir/file", "user.b"); !errors.Is(err, herr.ErrNoAttr) {
+		t.Errorf("Removexattr missing: got err %v, want ErrNoAttr", err)
+	}
+	if _, err = hadoop.Delete(ctx, "/dir"); !errors.Is(err, herr.ErrNotEmpty) {
+		t.Errorf("Delete non-empty dir: got err %v, want ErrNotEmpty", err)
+	}
+	if err = hadoop.Create(ctx, "/dir/file", "644"); !errors.Is(err, herr.ErrExist) {
+		t.Errorf("Create existing: got err %v, want ErrExist", err)
+	}
+}
+
+func TestHttpFSAutoDetect(t *testing.T) {
+	ctx := context.Background()
+	hadoop, server := newHttpFSTestController(t, GatewayAuto)
+
+	// 第一次上传时被重定向到带data=true的地址
+	if err := hadoop.Create(ctx, "/file", "644"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if count, data := uploads(server, "CREATE"); count != 2 || !data {
+		t.Errorf("CREATE: %d requests, data=true %v", count, data)
+	}
+	if !hadoop.isHttpFS() {
+		t.Fatalf("HttpFS should be detected after redirect")
+	}
+
+	// 之后直接带上data=true
+	if err := hadoop.AppendFile(ctx, "/file", []byte("data")); err != nil {
+		t.Fatalf("AppendFile: %v", err)
+	}
+	if count, data := uploads(server, "APPEND"); count != 1 || !data {
+		t.Errorf("APPEND: %d requests, data=true %v", count, data)
+	}
+}
+
+func TestWebHDFSNotDetectedAsHttpFS(t *testing.T) {
+	ctx := context.Background()
+	hadoop, server := newTestController(t)
+
+	if err := hadoop.Create(ctx, "/file", "644"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := hadoop.AppendFile(ctx, "/file", []byte("data")); err != nil {
+		t.Fatalf("AppendFile: %v", err)
+	}
+	if hadoop.isHttpFS() {
+		t.Errorf("WebHDFS should not be detected as HttpFS")
+	}
+	if _, data := uploads(server, "APPEND"); data {
+		t.Errorf("APPEND should not have data=true")
+	}
+
+	if err := hadoop.SetGateway("gateway"); err == nil {
+		t.Errorf("SetGateway: expected error for unknown gateway")
+	}
+}
+
+func TestFillFileID(t *testing.T) {
+	seen := map[uint64]string{}
+	wide := false
+	for i := 0; i < 10000; i++ {
+		p := fmt.Sprintf("/dir/file%d", i)
+		file := model.FileModel{}
+		fillFileID(p, &file)
+		// 留出挂载表区分集群的高位
+		if file.StIno <= 1 || file.StIno >= 1<<pathFileIDBits {
+			t.Fatalf("fillFileID(%s) = %d", p, file.StIno)
+		}
+		if other, ok := seen[file.StIno]; ok {
+			t.Errorf("fillFileID: %s and %s have the same inode %d", p, other, file.StIno)
+		}
+		seen[file.StIno] = p
+		wide = wide || file.StIno > math.MaxUint32
+	}
+	if !wide {
+		t.Error("fillFileID uses only 32 bits")
+	}
+
+	file := model.FileModel{StIno: 16386}
+	if fillFileID("/dir", &file); file.StIno != 16386 {
+		t.Errorf("fillFileID replaced fileId: got %d", file.StIno)
+	}
+}
@@ -0,0 +1,295 @@
+package controler
+
+import (
+	"fmt"
+	"hadoop-fs/fs/logger"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jcmturner/gokrb5/v8/client"
+	krbconfig "github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+)
+
+// 与Hadoop UGI一样，在票据有效期过了80%时重新登录
+const kerberosReloginFactor = 0.8
+
+// ticket cache 过期后，重新读取的间隔，也是重新登录失败后重试间隔的上限
+const kerberosExpiredRetry = time.Minute
+
+// 重新登录失败后第一次重试的间隔，之后每次加倍
+const kerberosReloginRetry = 5 * time.Second
+
+// KerberosAuthenticator 使用SPNEGO进行Kerberos认证，可以使用keytab或者ticket cache
+type KerberosAuthenticator struct {
+	lock sync.Mutex
+
+	krb5conf *krbconfig.Config
+
+	username string
+	realm    string
+	keytab   *keytab.Keytab
+
+	ccachePath string
+
+	// spn 为空时使用 HTTP/<NameNode的hostname>
+	spn string
+
+	client  *client.Client
+	expire  time.Time // client 中TGT的过期时间
+	relogin time.Time
+
+	// retry 重新登录失败后到下次重试的间隔，登录成功后清零
+	retry time.Duration
+	// loginErr 最近一次登录的错误
+	loginErr error
+	// loginDone 不为nil时正在登录，登录结束时关闭
+	loginDone chan struct{}
+}
+
+// Init 初始化函数，keytabPath 不为空时使用 principal 和 keytab 登录，否则使用 ccachePath 的 ticket cache
+func (krb *KerberosAuthenticator) Init(krb5confPath, principal, keytabPath, ccachePath, spn string) (err error) {
+
+	krb.krb5conf, err = krbconfig.Load(krb5confPath)
+	if err != nil {
+		return fmt.Errorf("load krb5.conf [%s] failed: %v", krb5confPath, err)
+	}
+
+	krb.spn = spn
+
+	if keytabPath != "" {
+		krb.keytab, err = keytab.Load(keytabPath)
+		if err != nil {
+			return fmt.Errorf("load keytab [%s] failed: %v", keytabPath, err)
+		}
+
+		krb.username = principal
+		krb.realm = krb.krb5conf.LibDefaults.DefaultRealm
+		if index := strings.LastIndex(principal, "@"); index >= 0 {
+			krb.username = principal[:index]
+			krb.realm = principal[index+1:]
+		}
+		if krb.username == "" || krb.realm == "" {
+			return fmt.Errorf("invalid kerberos principal [%s]", principal)
+		}
+	} else {
+		if ccachePath == "" {
+			ccachePath = defaultCCachePath()
+		}
+		krb.ccachePath = strings.TrimPrefix(ccachePath, "FILE:")
+	}
+
+	_, err = krb.login(true)
+	return err
+}
+
+// defaultCCachePath 与MIT Kerberos一样，优先使用KRB5CCNAME
+func defaultCCachePath() string {
+	if ccache := os.Getenv("KRB5CCNAME"); ccache != "" {
+		return ccache
+	}
+	return fmt.Sprintf("/tmp/krb5cc_%d", os.Getuid())
+}
+
+// login 重新登录，同时只有一个登录在进行，其它调用等待它的结果。登录在锁外进行，
+// 成功后才替换原来的client。失败时如果原来的TGT还没有过期就继续使用，按指数退避重试；
+// force 为true时(服务端拒绝了原来的票据)不再使用原来的client，失败时返回错误
+func (krb *KerberosAuthenticator) login(force bool) (*client.Client, error) {
+
+	krb.lock.Lock()
+	if done := krb.loginDone; done != nil {
+		if cl := krb.validClient(); cl != nil && !force {
+			krb.lock.Unlock()
+			return cl, nil
+		}
+		krb.lock.Unlock()
+		<-done
+
+		krb.lock.Lock()
+		defer krb.lock.Unlock()
+		if krb.loginErr == nil {
+			return krb.client, nil
+		}
+		if cl := krb.validClient(); cl != nil && !force {
+			return cl, nil
+		}
+		return nil, krb.loginErr
+	}
+	if !force && time.Now().Before(krb.relogin) {
+		// 其它请求已经登录过了，或者还没有到重试的时间
+		defer krb.lock.Unlock()
+		if krb.client == nil {
+			return nil, krb.loginErr
+		}
+		return krb.client, nil
+	}
+	done := make(chan struct{})
+	krb.loginDone = done
+	krb.lock.Unlock()
+
+	cl, expire, err := krb.newClient()
+
+	krb.lock.Lock()
+	defer krb.lock.Unlock()
+	krb.loginDone = nil
+	close(done)
+	krb.loginErr = err
+
+	now := time.Now()
+	if err != nil {
+		krb.retry *= 2
+		if krb.retry < kerberosReloginRetry {
+			krb.retry = kerberosReloginRetry
+		} else if krb.retry > kerberosExpiredRetry {
+			krb.retry = kerberosExpiredRetry
+		}
+		krb.relogin = now.Add(krb.retry)
+		if old := krb.validClient(); old != nil && !force {
+			logger.Warning.Printf("%v, keep using the ticket which expires at %s and retry in %s\n", err, krb.expire, krb.retry)
+			return old, nil
+		}
+		return nil, err
+	}
+
+	// 原来的client可能还在被其它请求使用，不调用 Destroy，由GC回收
+	krb.client = cl
+	krb.expire = expire
+	krb.retry = 0
+
+	if !expire.After(now) {
+		// 已经过期了，等外部程序更新后再重新读取
+		expire = now.Add(kerberosExpiredRetry)
+	}
+	krb.relogin = now.Add(time.Duration(float64(expire.Sub(now)) * kerberosReloginFactor))
+
+	return cl, nil
+}
+
+// validClient 返回TGT还没有过期的client，调用前需要持有锁
+func (krb *KerberosAuthenticator) validClient() *client.Client {
+	if krb.client != nil && time.Now().Before(krb.expire) {
+		return krb.client
+	}
+	return nil
+}
+
+// newClient 登录得到新的client和TGT的过期时间，不修改 krb，不需要持有锁。
+// keytab登录得到的TGT也保存为ticket cache，两种方式都在TGT的有效期过了 kerberosReloginFactor 时重新登录
+func (krb *KerberosAuthenticator) newClient() (*client.Client, time.Time, error) {
+
+	var ccache *credentials.CCache
+	var source string
+	var err error
+	if krb.keytab != nil {
+		source = "keytab"
+		ccache, err = krb.keytabLogin()
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("kerberos login as [%s@%s] failed: %v", krb.username, krb.realm, err)
+		}
+	} else {
+		// ticket cache 由 kinit 或者 k5start 等外部程序负责更新，这里只是重新读取
+		source = fmt.Sprintf("ticket cache [%s]", krb.ccachePath)
+		ccache, err = credentials.LoadCCache(krb.ccachePath)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("load %s failed: %v", source, err)
+		}
+	}
+
+	cl, err := client.NewFromCCache(ccache, krb.krb5conf, client.DisablePAFXFAST(true))
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("kerberos login from %s failed: %v", source, err)
+	}
+
+	expire := tgtEndTime(ccache)
+	logger.Info.Printf("kerberos login as [%s@%s] from %s, ticket expires at %s\n",
+		ccache.GetClientPrincipalName().PrincipalNameString(), ccache.GetClientRealm(), source, expire)
+	if !expire.After(time.Now()) {
+		logger.Warning.Printf("kerberos ticket from %s has expired at %s\n", source, expire)
+	}
+
+	return cl, expire, nil
+}
+
+// keytabLogin 使用keytab向KDC获取TGT，返回只在内存中的ticket cache
+func (krb *KerberosAuthenticator) keytabLogin() (*credentials.CCache, error) {
+
+	cl := client.NewWithKeytab(krb.username, krb.realm, krb.keytab, krb.krb5conf, client.DisablePAFXFAST(true))
+
+	asReq, err := messages.NewASReqForTGT(krb.realm, krb.krb5conf, cl.Credentials.CName())
+	if err != nil {
+		return nil, err
+	}
+	asRep, err := cl.ASExchange(krb.realm, asReq, 0)
+	if err != nil {
+		return nil, err
+	}
+	ticket, err := asRep.Ticket.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	ccache := &credentials.CCache{Version: 4}
+	ccache.DefaultPrincipal.Realm = asRep.CRealm
+	ccache.DefaultPrincipal.PrincipalName = asRep.CName
+
+	part := asRep.DecryptedEncPart
+	cred := &credentials.Credential{
+		Client:    ccache.DefaultPrincipal,
+		Key:       part.Key,
+		AuthTime:  part.AuthTime,
+		StartTime: part.StartTime,
+		EndTime:   part.EndTime,
+		RenewTill: part.RenewTill,
+		Ticket:    ticket,
+	}
+	cred.Server.Realm = asRep.Ticket.Realm
+	cred.Server.PrincipalName = asRep.Ticket.SName
+	ccache.Credentials = []*credentials.Credential{cred}
+
+	return ccache, nil
+}
+
+// tgtEndTime 获取ticket cache中TGT的过期时间
+func tgtEndTime(ccache *credentials.CCache) time.Time {
+	var endTime time.Time
+	for _, cred := range ccache.GetEntries() {
+		names := cred.Server.PrincipalName.NameString
+		if len(names) > 0 && names[0] == "krbtgt" {
+			if endTime.IsZero() || cred.EndTime.Before(endTime) {
+				endTime = cred.EndTime
+			}
+		}
+	}
+	return endTime
+}
+
+// Authenticate 添加 Authorization: Negotiate 请求头，票据快过期时先重新登录。
+// 获取service ticket需要访问KDC，不持有锁
+func (krb *KerberosAuthenticator) Authenticate(req *http.Request) error {
+
+	krb.lock.Lock()
+	cl := krb.client
+	due := cl == nil || time.Now().After(krb.relogin)
+	krb.lock.Unlock()
+
+	if due {
+		var err error
+		if cl, err = krb.login(false); err != nil {
+			return err
+		}
+	}
+
+	return spnego.SetSPNEGOHeader(cl, req, krb.spn)
+}
+
+// Refresh 重新登录
+func (krb *KerberosAuthenticator) Refresh() error {
+	_, err := krb.login(true)
+	return err
+}
@@ -0,0 +1,317 @@
+package controler
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jcmturner/goidentity/v6"
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/iana/nametype"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/service"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// fakeNegotiate 模拟SPNEGO，每次Refresh都会得到一个新的token
+type fakeNegotiate struct {
+	token    int
+	refreshs int
+}
+
+func (fake *fakeNegotiate) Authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", "Negotiate token-"+strconv.Itoa(fake.token))
+	return nil
+}
+
+func (fake *fakeNegotiate) Refresh() error {
+	fake.token++
+	fake.refreshs++
+	return nil
+}
+
+func TestAuthenticatorRefresh(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/file", []byte("data"))
+
+	// 服务端只接受token-1，第一次请求会返回401
+	server.Authorize = func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "Negotiate token-1"
+	}
+	auth := &fakeNegotiate{}
+	hadoop.SetAuthenticator(auth)
+
+	file, err := hadoop.GetFileStatus(context.Background(), "/file")
+	if err != nil || file.StSize != 4 {
+		t.Fatalf("GetFileStatus: got %+v, err %v", file, err)
+	}
+	if auth.refreshs != 1 {
+		t.Errorf("refreshs = %d, want 1", auth.refreshs)
+	}
+
+	req, _ := server.LastRequest("GETFILESTATUS")
+	if req.Query.Get("user.name") != "" {
+		t.Errorf("user.name should not be sent with authenticator, got %q", req.Query.Get("user.name"))
+	}
+
+	// 写操作在重试时需要重新发送请求体
+	if err = hadoop.AppendFile(context.Background(), "/file", []byte("more")); err != nil {
+		t.Fatalf("AppendFile: %v", err)
+	}
+	if content, _ := server.Content("/file"); string(content) != "datamore" {
+		t.Errorf("AppendFile: content = %q", content)
+	}
+}
+
+func TestAuthenticatorRejected(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/file", nil)
+
+	server.Authorize = func(r *http.Request) bool { return false }
+	hadoop.SetAuthenticator(&fakeNegotiate{})
+
+	if _, err := hadoop.GetFileStatus(context.Background(), "/file"); !errors.Is(err, herr.ErrAuth) {
+		t.Errorf("GetFileStatus: got err %v, want ErrAuth", err)
+	}
+}
+
+// writeKrb5Conf 写入只有 EXAMPLE.COM 的krb5.conf，KDC 不可用
+func writeKrb5Conf(t *testing.T, dir string) string {
+	t.Helper()
+
+	krb5conf := filepath.Join(dir, "krb5.conf")
+	conf := "[libdefaults]\n  default_realm = EXAMPLE.COM\n[realms]\n  EXAMPLE.COM = {\n    kdc = 127.0.0.1:1\n  }\n"
+	if err := ioutil.WriteFile(krb5conf, []byte(conf), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return krb5conf
+}
+
+func TestKerberosInitErrors(t *testing.T) {
+	dir := t.TempDir()
+	krb5conf := writeKrb5Conf(t, dir)
+
+	krb := &KerberosAuthenticator{}
+	if err := krb.Init(filepath.Join(dir, "missing.conf"), "hdfs", filepath.Join(dir, "hdfs.keytab"), "", ""); err == nil {
+		t.Errorf("Init with missing krb5.conf: expected error")
+	}
+	if err := krb.Init(krb5conf, "hdfs", filepath.Join(dir, "hdfs.keytab"), "", ""); err == nil {
+		t.Errorf("Init with missing keytab: expected error")
+	}
+	if err := krb.Init(krb5conf, "", "", filepath.Join(dir, "krb5cc"), ""); err == nil {
+		t.Errorf("Init with missing ticket cache: expected error")
+	}
+}
+
+const (
+	testRealm = "EXAMPLE.COM"
+	testSPN   = "HTTP/localhost"
+)
+
+// testKDC 代替KDC签发票据，keytab 中有krbtgt和 testSPN 的密钥，HTTP服务使用它验证SPNEGO
+type testKDC struct {
+	keytab *keytab.Keytab
+}
+
+func newTestKDC(t *testing.T) *testKDC {
+	t.Helper()
+
+	kt := keytab.New()
+	for _, principal := range []string{"krbtgt/" + testRealm, testSPN} {
+		if err := kt.AddEntry(principal, testRealm, principal+"-password", time.Now(), 1, etypeID.AES256_CTS_HMAC_SHA1_96); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &testKDC{keytab: kt}
+}
+
+// writeCCache 写入 user 的ticket cache(版本4)，包括TGT和 testSPN 的票据，都在 endTime 过期
+func (kdc *testKDC) writeCCache(t *testing.T, path, user string, endTime time.Time) {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	write := func(v interface{}) { binary.Write(buf, binary.BigEndian, v) }
+	writeData := func(data []byte) {
+		write(uint32(len(data)))
+		buf.Write(data)
+	}
+	writePrincipal := func(name types.PrincipalName) {
+		write(name.NameType)
+		write(uint32(len(name.NameString)))
+		writeData([]byte(testRealm))
+		for _, component := range name.NameString {
+			writeData([]byte(component))
+		}
+	}
+
+	cname := types.NewPrincipalName(nametype.KRB_NT_PRINCIPAL, user)
+	buf.Write([]byte{5, 4, 0, 0})
+	writePrincipal(cname)
+
+	start := time.Now().Add(-time.Minute)
+	for _, server := range []string{"krbtgt/" + testRealm, testSPN} {
+		sname := types.NewPrincipalName(nametype.KRB_NT_SRV_INST, server)
+		ticket, key, err := messages.NewTicket(cname, testRealm, sname, testRealm, types.NewKrbFlags(), kdc.keytab,
+			etypeID.AES256_CTS_HMAC_SHA1_96, 1, start, start, endTime, endTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ticketBytes, err := ticket.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		writePrincipal(cname)
+		writePrincipal(sname)
+		write(uint16(key.KeyType))
+		writeData(key.KeyValue)
+		for _, ts := range []time.Time{start, start, endTime, endTime} {
+			write(uint32(ts.Unix()))
+		}
+		buf.Write([]byte{0, 0, 0, 0, 0}) // is_skey 和 ticket flags
+		write(uint32(0))                 // addresses
+		write(uint32(0))                 // authdata
+		writeData(ticketBytes)
+		writeData(nil)
+	}
+
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// server 验证SPNEGO的HTTP服务，返回认证的用户名
+func (kdc *testKDC) server(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, goidentity.FromHTTPRequestContext(r).UserName())
+	})
+	server := httptest.NewServer(spnego.SPNEGOKRB5Authenticate(inner, kdc.keytab, service.DecodePAC(false)))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// authenticatedUser 使用 krb 认证后发送请求，返回服务端认证的用户名
+func authenticatedUser(t *testing.T, krb *KerberosAuthenticator, url string) string {
+	t.Helper()
+
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if err := krb.Authenticate(req); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("SPNEGO rejected: %d %s", resp.StatusCode, body)
+	}
+	return string(body)
+}
+
+func TestKerberosAuthenticate(t *testing.T) {
+	dir := t.TempDir()
+	krb5conf := writeKrb5Conf(t, dir)
+	ccache := filepath.Join(dir, "krb5cc")
+
+	kdc := newTestKDC(t)
+	server := kdc.server(t)
+	kdc.writeCCache(t, ccache, "alice", time.Now().Add(time.Hour))
+
+	krb := &KerberosAuthenticator{}
+	if err := krb.Init(krb5conf, "", "", "FILE:"+ccache, testSPN); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	// 在TGT的有效期过了80%时重新登录
+	if wait := time.Until(krb.relogin); wait < 47*time.Minute || wait > 49*time.Minute {
+		t.Errorf("relogin after %s, want about 48m", wait)
+	}
+
+	if user := authenticatedUser(t, krb, server.URL); user != "alice" {
+		t.Errorf("authenticated user: got %q, want alice", user)
+	}
+
+	// 没有到重新登录的时间时不重新读取ticket cache
+	kdc.writeCCache(t, ccache, "bob", time.Now().Add(2*time.Hour))
+	if user := authenticatedUser(t, krb, server.URL); user != "alice" {
+		t.Errorf("authenticated user before relogin: got %q, want alice", user)
+	}
+
+	krb.relogin = time.Now().Add(-time.Second)
+	if user := authenticatedUser(t, krb, server.URL); user != "bob" {
+		t.Errorf("authenticated user after relogin: got %q, want bob", user)
+	}
+	if wait := time.Until(krb.relogin); wait < 95*time.Minute || wait > 97*time.Minute {
+		t.Errorf("relogin after %s, want about 96m", wait)
+	}
+
+	// TGT已经过期时，在 kerberosExpiredRetry 内重新读取
+	kdc.writeCCache(t, ccache, "carol", time.Now().Add(-time.Second))
+	if err := krb.Refresh(); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if wait := time.Until(krb.relogin); wait <= 0 || wait > kerberosExpiredRetry {
+		t.Errorf("relogin after %s with expired TGT", wait)
+	}
+}
+
+func TestKerberosReloginFailure(t *testing.T) {
+	dir := t.TempDir()
+	krb5conf := writeKrb5Conf(t, dir)
+	ccache := filepath.Join(dir, "krb5cc")
+
+	kdc := newTestKDC(t)
+	server := kdc.server(t)
+	kdc.writeCCache(t, ccache, "alice", time.Now().Add(time.Hour))
+
+	krb := &KerberosAuthenticator{}
+	if err := krb.Init(krb5conf, "", "", "FILE:"+ccache, testSPN); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	// 重新登录失败时继续使用原来还没有过期的票据，按退避时间重试
+	if err := ioutil.WriteFile(ccache, []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	krb.relogin = time.Now().Add(-time.Second)
+	if user := authenticatedUser(t, krb, server.URL); user != "alice" {
+		t.Errorf("authenticated user after failed relogin: got %q, want alice", user)
+	}
+	if wait := time.Until(krb.relogin); wait <= 0 || wait > kerberosReloginRetry {
+		t.Errorf("retry relogin after %s, want within %s", wait, kerberosReloginRetry)
+	}
+	krb.relogin = time.Now().Add(-time.Second)
+	authenticatedUser(t, krb, server.URL)
+	if krb.retry != 2*kerberosReloginRetry {
+		t.Errorf("retry interval: got %s, want %s", krb.retry, 2*kerberosReloginRetry)
+	}
+
+	// 服务端拒绝了原来的票据时，重新登录失败返回错误
+	if err := krb.Refresh(); err == nil {
+		t.Error("Refresh with broken ticket cache succeeded")
+	}
+
+	kdc.writeCCache(t, ccache, "bob", time.Now().Add(time.Hour))
+	krb.relogin = time.Now().Add(-time.Second)
+	if user := authenticatedUser(t, krb, server.URL); user != "bob" {
+		t.Errorf("authenticated user after relogin: got %q, want bob", user)
+	}
+	if krb.retry != 0 {
+		t.Errorf("retry interval not reset after relogin: %s", krb.retry)
+	}
+}
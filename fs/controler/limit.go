@@ -0,0 +1,264 @@
+package controler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hadoop-fs/fs/logger"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// DefaultConcurrency 默认同时发往NameNode的最大请求数
+const DefaultConcurrency = 32
+
+// coalescedOps 可以合并的元数据op，相同的请求同时只发送一个，结果共享
+var coalescedOps = map[string]bool{
+	opGetFileStatus:   true,
+	opListStatusBatch: true,
+	opGetXattr:        true,
+}
+
+// RequestStats 发往NameNode的请求的排队与合并统计
+type RequestStats struct {
+	// Requests 发往NameNode的请求数，包括重试
+	Requests uint64
+	// InFlight 正在进行的请求数
+	InFlight int
+	// Waiting 正在排队等待的请求数
+	Waiting int
+	// Queued 需要排队的请求数
+	Queued uint64
+	// QueueTime 排队的总时间，MaxQueueTime 是最长的一次
+	QueueTime    time.Duration
+	MaxQueueTime time.Duration
+	// Coalesced 合并到其他相同请求的请求数
+	Coalesced uint64
+}
+
+func (stats RequestStats) String() string {
+	return fmt.Sprintf("requests %d, in flight %d, waiting %d, queued %d (total %s, max %s), coalesced %d",
+		stats.Requests, stats.InFlight, stats.Waiting, stats.Queued, stats.QueueTime, stats.MaxQueueTime, stats.Coalesced)
+}
+
+// flight 正在进行的可以合并的请求
+type flight struct {
+	path string
+	done chan struct{}
+	resp webhdfsResponse
+	err  error
+}
+
+// SetConcurrency 设置同时发往NameNode的最大请求数，超过时排队等待，0表示不限制
+func (hadoop *HadoopController) SetConcurrency(limit int) {
+	if limit <= 0 {
+		hadoop.limit = nil
+		return
+	}
+	hadoop.limit = make(chan struct{}, limit)
+}
+
+// Stats 返回发往NameNode的请求的统计
+func (hadoop *HadoopController) Stats() RequestStats {
+	hadoop.statsLock.Lock()
+	defer hadoop.statsLock.Unlock()
+	return hadoop.stats
+}
+
+// SetStatsInterval 每隔 interval 在有新的请求时输出NameNode请求的统计，0表示不输出。
+// 停止输出时再输出一次，退出时可以看到最后的统计
+func (hadoop *HadoopController) SetStatsInterval(interval time.Duration) {
+
+	hadoop.statsLock.Lock()
+	stop := hadoop.statsStop
+	hadoop.statsStop = nil
+	if interval > 0 {
+		hadoop.statsStop = make(chan struct{})
+		go hadoop.statsLoop(hadoop.statsStop, interval)
+	}
+	hadoop.statsLock.Unlock()
+
+	if stop != nil {
+		close(stop)
+		hadoop.logStats()
+	}
+}
+
+// statsLoop 定时输出统计，直到stop被关闭
+func (hadoop *HadoopController) statsLoop(stop chan struct{}, interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			hadoop.logStats()
+		}
+	}
+}
+
+// logStats 输出统计，上次输出之后没有新的请求时不输出
+func (hadoop *HadoopController) logStats() {
+
+	hadoop.statsLock.Lock()
+	defer hadoop.statsLock.Unlock()
+
+	if hadoop.stats.Requests == hadoop.statsLogged {
+		return
+	}
+	hadoop.statsLogged = hadoop.stats.Requests
+
+	logger.Info.Printf("NameNode %s\n", hadoop.stats)
+}
+
+// acquire 占用一个并发名额，没有名额时排队，直到ctx被取消
+func (hadoop *HadoopController) acquire(ctx context.Context) error {
+
+	hadoop.statsLock.Lock()
+	hadoop.stats.Requests++
+	hadoop.statsLock.Unlock()
+
+	if hadoop.limit == nil {
+		return nil
+	}
+
+	select {
+	case hadoop.limit <- struct{}{}:
+		hadoop.statsLock.Lock()
+		hadoop.stats.InFlight++
+		hadoop.statsLock.Unlock()
+		return nil
+	default:
+	}
+
+	start := time.Now()
+
+	hadoop.statsLock.Lock()
+	hadoop.stats.Queued++
+	hadoop.stats.Waiting++
+	hadoop.statsLock.Unlock()
+
+	var err error
+	select {
+	case hadoop.limit <- struct{}{}:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	wait := time.Since(start)
+
+	hadoop.statsLock.Lock()
+	defer hadoop.statsLock.Unlock()
+
+	hadoop.stats.Waiting--
+	hadoop.stats.QueueTime += wait
+	if wait > hadoop.stats.MaxQueueTime {
+		hadoop.stats.MaxQueueTime = wait
+	}
+	if err == nil {
+		hadoop.stats.InFlight++
+	}
+
+	return err
+}
+
+// release 释放 acquire 占用的名额
+func (hadoop *HadoopController) release() {
+
+	if hadoop.limit == nil {
+		return
+	}
+
+	hadoop.statsLock.Lock()
+	hadoop.stats.InFlight--
+	hadoop.statsLock.Unlock()
+
+	<-hadoop.limit
+}
+
+// sendNameNode 占用一个并发名额后发送请求到NameNode，收到响应头后就释放
+func (hadoop *HadoopController) sendNameNode(req *http.Request) (*http.Response, error) {
+
+	if err := hadoop.acquire(req.Context()); err != nil {
+		return nil, err
+	}
+	defer hadoop.release()
+
+	return hadoop.failover(req)
+}
+
+// coalesce 相同的请求(method、op、path、参数和代理用户都相同)正在进行时，等待它的结果，
+// 而不是再发送一次。发送请求的调用被取消时，其它还在等待的调用会重新发送
+func (hadoop *HadoopController) coalesce(ctx context.Context, request webhdfsRequest) (webhdfsResponse, error) {
+
+	// fmt 按key的顺序输出map
+	key := fmt.Sprintf("%s %s %s %v %s", request.method, request.op, request.path, request.params, proxyUser(ctx))
+
+	for {
+		hadoop.flightLock.Lock()
+		if hadoop.flights == nil {
+			hadoop.flights = make(map[string]*flight)
+		}
+
+		if f, ok := hadoop.flights[key]; ok {
+			hadoop.flightLock.Unlock()
+
+			hadoop.statsLock.Lock()
+			hadoop.stats.Coalesced++
+			hadoop.statsLock.Unlock()
+
+			select {
+			case <-f.done:
+			case <-ctx.Done():
+				return webhdfsResponse{}, ctx.Err()
+			}
+
+			if f.err != nil && ctx.Err() == nil &&
+				(errors.Is(f.err, context.Canceled) || errors.Is(f.err, context.DeadlineExceeded)) {
+				logger.Trace.Printf("coalesce: %s %s was canceled, send again\n", request.op, request.path)
+				continue
+			}
+			return f.resp, f.err
+		}
+
+		f := &flight{path: request.path, done: make(chan struct{})}
+		hadoop.flights[key] = f
+		hadoop.flightLock.Unlock()
+
+		f.resp, f.err = hadoop.fetch(ctx, request)
+
+		hadoop.flightLock.Lock()
+		if hadoop.flights[key] == f {
+			delete(hadoop.flights, key)
+		}
+		hadoop.flightLock.Unlock()
+		close(f.done)
+
+		return f.resp, f.err
+	}
+}
+
+// forget 修改请求完成后调用，之后的读请求不再合并到修改之前就在进行的请求，避免读到修改前的结果。
+// paths 下面的路径(删除和重命名目录)和 paths 的父目录(列出目录)也会受影响
+func (hadoop *HadoopController) forget(paths ...string) {
+
+	hadoop.flightLock.Lock()
+	defer hadoop.flightLock.Unlock()
+
+	for key, f := range hadoop.flights {
+		for _, p := range paths {
+			if p == "" {
+				continue
+			}
+			if f.path == p || f.path == path.Dir(p) || strings.HasPrefix(f.path, strings.TrimSuffix(p, "/")+"/") {
+				delete(hadoop.flights, key)
+				break
+			}
+		}
+	}
+}
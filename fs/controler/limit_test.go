@@ -0,0 +1,265 @@
+package controler
+
+import (
+	"context"
+	"errors"
+	"hadoop-fs/fs/logger"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitStats 等待统计满足条件
+func waitStats(t *testing.T, hadoop *HadoopController, cond func(stats RequestStats) bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond(hadoop.Stats()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout, stats: %s", hadoop.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	hadoop, server := newTestController(t)
+	hadoop.SetConcurrency(2)
+	for i := 0; i < 5; i++ {
+		server.AddFile("/file"+strconv.Itoa(i), []byte("data"))
+	}
+
+	release := server.Hang("GETFILESTATUS")
+	defer release()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = hadoop.GetFileStatus(context.Background(), "/file"+strconv.Itoa(i))
+		}(i)
+	}
+
+	waitStats(t, hadoop, func(stats RequestStats) bool { return stats.InFlight == 2 && stats.Waiting == 3 })
+
+	release()
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("GetFileStatus %d: %v", i, err)
+		}
+	}
+	if stats := hadoop.Stats(); stats.Queued != 3 || stats.InFlight != 0 || stats.Waiting != 0 || stats.Requests != 5 {
+		t.Errorf("stats: %s", stats)
+	}
+}
+
+func TestConcurrencyLimitCanceled(t *testing.T) {
+	hadoop, server := newTestController(t)
+	hadoop.SetConcurrency(1)
+
+	release := server.Hang("LISTSTATUS_BATCH")
+	defer release()
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := hadoop.List(context.Background(), "/", "")
+		done <- err
+	}()
+	waitStats(t, hadoop, func(stats RequestStats) bool { return stats.InFlight == 1 })
+
+	// 排队时被取消
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := hadoop.GetFileStatus(ctx, "/"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetFileStatus while queued: got err %v, want DeadlineExceeded", err)
+	}
+	if stats := hadoop.Stats(); stats.Waiting != 0 || stats.Queued != 1 {
+		t.Errorf("stats: %s", stats)
+	}
+
+	release()
+	if err := <-done; err != nil {
+		t.Errorf("List: %v", err)
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/file", []byte("data"))
+
+	release := server.Hang("GETFILESTATUS")
+	defer release()
+
+	var wg sync.WaitGroup
+	sizes := make([]int64, 5)
+	errs := make([]error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			file, err := hadoop.GetFileStatus(context.Background(), "/file")
+			sizes[i], errs[i] = file.StSize, err
+		}(i)
+	}
+
+	waitStats(t, hadoop, func(stats RequestStats) bool { return stats.Coalesced == 4 })
+
+	release()
+	wg.Wait()
+
+	for i := range errs {
+		if errs[i] != nil || sizes[i] != 4 {
+			t.Errorf("GetFileStatus %d: size %d, err %v", i, sizes[i], errs[i])
+		}
+	}
+	if got := countOps(server, "GETFILESTATUS"); got != 1 {
+		t.Errorf("GETFILESTATUS requests = %d, want 1", got)
+	}
+}
+
+func TestCoalesceLeaderCanceled(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/file", []byte("data"))
+
+	release := server.Hang("GETFILESTATUS")
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error, 1)
+	go func() {
+		_, err := hadoop.GetFileStatus(ctx, "/file")
+		leader <- err
+	}()
+	waitStats(t, hadoop, func(stats RequestStats) bool { return stats.Requests == 1 })
+
+	follower := make(chan error, 1)
+	go func() {
+		_, err := hadoop.GetFileStatus(context.Background(), "/file")
+		follower <- err
+	}()
+	waitStats(t, hadoop, func(stats RequestStats) bool { return stats.Coalesced == 1 })
+
+	// 发送请求的调用被取消，等待的调用重新发送
+	cancel()
+	if err := <-leader; !errors.Is(err, context.Canceled) {
+		t.Errorf("leader: got err %v, want Canceled", err)
+	}
+	release()
+	if err := <-follower; err != nil {
+		t.Errorf("follower: %v", err)
+	}
+}
+
+func TestCoalesceForgetAfterMutation(t *testing.T) {
+	tests := []struct {
+		name   string
+		read   string
+		mutate func(hadoop *HadoopController) error
+	}{
+		{"setpermission", "/dir/file", func(hadoop *HadoopController) error {
+			return hadoop.SetPermission(context.Background(), "/dir/file", "600")
+		}},
+		{"rename destination parent", "/other", func(hadoop *HadoopController) error {
+			_, err := hadoop.Rename(context.Background(), "/dir/file", "/other/file")
+			return err
+		}},
+		{"rename parent", "/dir/file", func(hadoop *HadoopController) error {
+			_, err := hadoop.Rename(context.Background(), "/dir", "/moved")
+			return err
+		}},
+		{"delete child", "/dir", func(hadoop *HadoopController) error {
+			_, err := hadoop.Delete(context.Background(), "/dir/file")
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hadoop, server := newTestController(t)
+			server.AddDir("/other")
+			server.AddFile("/dir/file", []byte("data"))
+
+			release := server.Hang("GETFILESTATUS")
+			defer release()
+
+			results := make(chan error, 2)
+			read := func() {
+				_, err := hadoop.GetFileStatus(context.Background(), tt.read)
+				results <- err
+			}
+			go read()
+			waitStats(t, hadoop, func(stats RequestStats) bool { return stats.Requests == 1 })
+
+			if err := tt.mutate(hadoop); err != nil {
+				t.Fatalf("mutate: %v", err)
+			}
+
+			// 修改之后的读请求不合并到修改之前开始的请求
+			go read()
+			waitStats(t, hadoop, func(stats RequestStats) bool { return stats.Requests == 3 })
+			if stats := hadoop.Stats(); stats.Coalesced != 0 {
+				t.Errorf("coalesced %d requests after mutation", stats.Coalesced)
+			}
+
+			release()
+			<-results
+			<-results
+		})
+	}
+}
+
+// lineWriter 把每一行日志发送到channel
+type lineWriter chan string
+
+func (w lineWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func TestStatsInterval(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/file", []byte("data"))
+
+	lines := make(lineWriter, 16)
+	logger.Info.SetOutput(lines)
+	defer logger.Info.SetOutput(os.Stdout)
+
+	hadoop.SetStatsInterval(10 * time.Millisecond)
+	if _, err := hadoop.GetFileStatus(context.Background(), "/file"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case line := <-lines:
+		if !strings.Contains(line, "NameNode requests 1,") {
+			t.Errorf("stats log: got %q", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the stats log")
+	}
+
+	// 没有新的请求时不再输出
+	time.Sleep(50 * time.Millisecond)
+	if len(lines) != 0 {
+		t.Errorf("stats logged without new requests: %q", <-lines)
+	}
+
+	// 停止时输出最后的统计
+	if _, err := hadoop.GetFileStatus(context.Background(), "/file"); err != nil {
+		t.Fatal(err)
+	}
+	hadoop.SetStatsInterval(0)
+	select {
+	case line := <-lines:
+		if !strings.Contains(line, "NameNode requests 2,") {
+			t.Errorf("final stats log: got %q", line)
+		}
+	default:
+		t.Errorf("no stats logged when stopped")
+	}
+}
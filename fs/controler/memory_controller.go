@@ -0,0 +1,798 @@
+package controler
+
+import (
+	"context"
+	"fmt"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"hadoop-fs/fs/model"
+	"hadoop-fs/fs/util"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 与HDFS的默认值保持一致
+const (
+	memoryRootFileID     = 16385
+	memoryBlockSize      = 134217728
+	memoryListLimit      = 1000
+	memoryDefaultGroup   = "supergroup"
+	memoryDirPermission  = "755"
+	memoryFilePermission = "644"
+	memoryCapacity       = 1 << 40
+)
+
+// memoryNode 内存中的一个文件或者目录
+type memoryNode struct {
+	file    model.FileModel
+	content []byte
+	xattrs  map[string]string
+	// acl 扩展的ACL项和default ACL，格式与 AclStatus 一样
+	acl []model.AclEntry
+
+	// 目录的配额，没有配额时为-1，只用于 GetQuotaUsage，写入时不检查
+	nsQuota    int64
+	spaceQuota int64
+}
+
+// MemoryController 将文件保存在内存中的存储后端，行为尽量与WebHDFS保持一致，
+// 用于在没有Hadoop集群的情况下运行和测试FUSE层
+type MemoryController struct {
+	lock sync.Mutex
+
+	username string
+
+	nextID uint64
+	nodes  map[string]*memoryNode
+
+	// LISTSTATUS_BATCH 每次返回的最大数量
+	listLimit int
+}
+
+// Init 初始化函数，username 为空时使用当前用户作为文件的owner
+func (memory *MemoryController) Init(username string) {
+
+	if username == "" {
+		if current, err := user.Current(); err == nil {
+			username = current.Username
+		}
+	}
+
+	memory.username = username
+	memory.listLimit = memoryListLimit
+	memory.nextID = memoryRootFileID
+	memory.nodes = make(map[string]*memoryNode)
+
+	memory.nodes["/"] = memory.newNode("", model.HadoopDir, memoryDirPermission)
+}
+
+func (memory *MemoryController) newNode(name, hadoopType, permission string) *memoryNode {
+
+	now := util.NsToMs(time.Now().UnixNano())
+
+	node := &memoryNode{xattrs: make(map[string]string), nsQuota: -1, spaceQuota: -1}
+	node.file.Name = name
+	node.file.StIno = memory.nextID
+	node.file.StMtime = now
+	node.file.HadoopOwner = memory.username
+	node.file.HadoopGroup = memoryDefaultGroup
+	node.file.HadoopType = hadoopType
+	node.file.HadoopPermission = permission
+
+	if hadoopType == model.HadoopFile {
+		node.file.StAtime = now
+		node.file.StBlksize = memoryBlockSize
+	}
+
+	memory.nextID++
+
+	return node
+}
+
+// cleanPath 去掉路径结尾的"/"
+func cleanPath(path string) string {
+	path = strings.TrimRight(path, "/")
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// children 获取目录下的文件名，按名字排序
+func (memory *MemoryController) children(dir string) []string {
+
+	names := make([]string, 0)
+	for path := range memory.nodes {
+		if path != "/" && cleanPath(util.GetParentPath(path)) == dir {
+			names = append(names, util.GetFileName(path))
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// status 返回文件信息的副本
+func (memory *MemoryController) status(path string, node *memoryNode) model.FileModel {
+
+	file := node.file
+	file.StSize = int64(len(node.content))
+	file.AclBit = len(node.acl) > 0
+	if file.HadoopType == model.HadoopDir {
+		file.ChildrenNum = len(memory.children(path))
+	}
+
+	return file
+}
+
+// getDir 获取目录，不存在或者不是目录时返回错误
+func (memory *MemoryController) getDir(path string) (*memoryNode, error) {
+
+	node, ok := memory.nodes[path]
+	if !ok {
+		return nil, herr.ErrNoFound
+	}
+	if node.file.HadoopType != model.HadoopDir {
+		return nil, remoteException("ParentNotDirectoryException", "org.apache.hadoop.fs.ParentNotDirectoryException",
+			fmt.Sprintf("%s (is not a directory)", path))
+	}
+
+	return node, nil
+}
+
+// getFile 获取文件，不存在或者不是文件时返回错误
+func (memory *MemoryController) getFile(path string) (*memoryNode, error) {
+
+	node, ok := memory.nodes[path]
+	if !ok {
+		return nil, herr.ErrNoFound
+	}
+	if node.file.HadoopType != model.HadoopFile {
+		return nil, remoteException("FileNotFoundException", "java.io.FileNotFoundException",
+			fmt.Sprintf("Path is not a file: %s", path))
+	}
+
+	return node, nil
+}
+
+// List 列出目录下的文件
+func (memory *MemoryController) List(ctx context.Context, path, startAfter string) (fileList []model.FileModel, remain int, err error) {
+
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+
+	path = cleanPath(path)
+
+	node, ok := memory.nodes[path]
+	if !ok {
+		return nil, 0, herr.ErrNoFound
+	}
+
+	fileList = make([]model.FileModel, 0)
+
+	if node.file.HadoopType != model.HadoopDir {
+		// 与HDFS一样，对文件list时返回文件本身
+		return append(fileList, memory.status(path, node)), 0, nil
+	}
+
+	names := memory.children(path)
+	start := sort.SearchStrings(names, startAfter)
+	if start < len(names) && names[start] == startAfter {
+		start++
+	}
+	names = names[start:]
+
+	for i, name := range names {
+		if i >= memory.listLimit {
+			remain = len(names) - i
+			break
+		}
+		filePath := util.MergePath(path, name)
+		fileList = append(fileList, memory.status(filePath, memory.nodes[filePath]))
+	}
+
+	return fileList, remain, nil
+}
+
+// GetHomeDirectory 与HDFS一样，home目录是 /user/<username>
+func (memory *MemoryController) GetHomeDirectory(ctx context.Context) (home string, err error) {
+	return "/user/" + memory.username, nil
+}
+
+// GetFileStatus 获取文件信息
+func (memory *MemoryController) GetFileStatus(ctx context.Context, filePath string) (file model.FileModel, err error) {
+
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+
+	filePath = cleanPath(filePath)
+
+	node, ok := memory.nodes[filePath]
+	if !ok {
+		return file, herr.ErrNoFound
+	}
+
+	file = memory.status(filePath, node)
+	// GETFILESTATUS 返回的pathSuffix为空
+	file.Name = ""
+
+	return file, nil
+}
+
+// Read 读取文件内容
+func (memory *MemoryController) Read(ctx context.Context, filePath string, offset uint64, length uint32, buffersize int) (content []byte, err error) {
+
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+
+	node, err := memory.getFile(cleanPath(filePath))
+	if err != nil {
+		return nil, err
+	}
+
+	size := uint64(len(node.content))
+	if offset > size {
+		return nil, herr.ErrEOF
+	}
+
+	if length <= 0 {
+		length = uint32(defaultLength)
+	}
+
+	end := offset + uint64(length)
+	if end > size {
+		end = size
+	}
+
+	content = make([]byte, end-offset)
+	copy(content, node.content[offset:end])
+
+	node.file.StAtime = util.NsToMs(time.Now().UnixNano())
+
+	return content, nil
+}
+
+// MakeDir 创建目录，与HDFS一样会创建不存在的父目录
+func (memory *MemoryController) MakeDir(ctx context.Context, pathname, permission string) (result bool, err error) {
+
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+
+	pathname = cleanPath(pathname)
+
+	if permission == "" {
+		permission = memoryDirPermission
+	}
+
+	// 从根目录开始逐级检查
+	current := "/"
+	for _, name := range strings.Split(strings.TrimLeft(pathname, "/"), "/") {
+		if name == "" {
+			continue
+		}
+
+		current = util.MergePath(current, name)
+
+		node, ok := memory.nodes[current]
+		if !ok {
+			memory.nodes[current] = memory.newNode(name, model.HadoopDir, permission)
+			continue
+		}
+		if node.file.HadoopType != model.HadoopDir {
+			return false, remoteException("FileAlreadyExistsException", "org.apache.hadoop.fs.FileAlreadyExistsException",
+				fmt.Sprintf("Path is not a directory: %s", current))
+		}
+	}
+
+	return true, nil
+}
+
+// Create 创建文件
+func (memory *MemoryController) Create(ctx context.Context, filepath, permission string) (err error) {
+
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+
+	filepath = cleanPath(filepath)
+
+	if _, ok := memory.nodes[filepath]; ok {
+		return herr.ErrExist
+	}
+
+	parent, err := memory.getDir(cleanPath(util.GetParentPath(filepath)))
+	if err != nil {
+		return err
+	}
+
+	if permission == "" {
+		permission = memoryFilePermission
+	}
+
+	memory.nodes[filepath] = memory.newNode(util.GetFileName(filepath), model.HadoopFile, permission)
+	parent.file.StMtime = util.NsToMs(time.Now().UnixNano())
+
+	return nil
+}
+
+// ModificationTime 设置文件Mtime和Atime，-1表示不变
+func (memory *MemoryController) ModificationTime(ctx context.Context, filepath string, mtime, atime int64) (err error) {
+
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+
+	node, ok := memory.nodes[cleanPath(filepath)]
+	if !ok {
+		return herr.ErrNoFound
+	}
+
+	if mtime >= 0 {
+		node.file.StMtime = mtime
+	}
+	if atime >= 0 {
+		node.file.StAtime = atime
+	}
+
+	return nil
+}
+
+// AppendFile 追加文件内容
+func (memory *MemoryController) AppendFile(ctx context.Context, filepath string, content []byte) (err error) {
+
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+
+	node, err := memory.getFile(cleanPath(filepath))
+	if err != nil {
+		return err
+	}
+
+	node.content = append(node.content, content...)
+	node.file.StMtime = util.NsToMs(time.Now().UnixNano())
+
+	return nil
+}
+
+// TruncateFile Truncate 文件
+func (memory *MemoryController) TruncateFile(ctx context.Context, filepath string, newlength int64) (result bool, err error) {
+
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+
+	node, err := memory.getFile(cleanPath(filepath))
+	if err != nil {
+		return false, err
+	}
+
+	if newlength < 0 || newlength > int64(len(node.content)) {
+		return false, remoteException("HadoopIllegalArgumentException", "org.apache.hadoop.HadoopIllegalArgumentException",
+			fmt.Sprintf("Cannot truncate to a larger file size. Current size: %d, truncate size: %d.", len(node.content), newlength))
+	}
+
+	node.content = node.content[:newlength]
+	node.file.StMtime = util.NsToMs(time.Now().UnixNano())
+
+	return true, nil
+}
+
+// Delete 删除文件或者目录，不会递归删除
+func (memory *MemoryController) Delete(ctx context.Context, filepath string) (result bool, err error) {
+
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+
+	filepath = cleanPath(filepath)
+
+	node, ok := memory.nodes[filepath]
+	if !ok || filepath == "/" {
+		return false, nil
+	}
+
+	if node.file.HadoopType == model.HadoopDir && len(memory.children(filepath)) > 0 {
+		return false, remoteException("PathIsNotEmptyDirectoryException", "org.apache.hadoop.fs.PathIsNotEmptyDirectoryException",
+			fmt.Sprintf("`%s is non empty': Directory is not empty", filepath))
+	}
+
+	delete(memory.nodes, filepath)
+
+	return true, nil
+}
+
+// SetPermission 设置文件权限
+func (memory *MemoryController) SetPermission(ctx context.Context, filepath, permission string) (err error) {
+
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+
+	node, ok := memory.nodes[cleanPath(filepath)]
+	if !ok {
+		return herr.ErrNoFound
+	}
+
+	node.file.HadoopPermission = permission
+
+	return nil
+}
+
+// SetOwner 设置文件的owner和group，为空的不修改。挂载的用户是超级用户，代理的其他用户只能修改自己文件的group
+func (memory *MemoryController) SetOwner(ctx context.Context, filepath, owner, group string) (err error) {
+
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+
+	node, ok := memory.nodes[cleanPath(filepath)]
+	if !ok {
+		return herr.ErrNoFound
+	}
+
+	if user := proxyUser(ctx); user != "" && user != memory.username {
+		if owner != "" {
+			return remoteException("AccessControlException", "org.apache.hadoop.security.AccessControlException",
+				"Non-super user cannot change owner")
+		}
+		if user != node.file.HadoopOwner {
+			return remoteException("AccessControlException", "org.apache.hadoop.security.AccessControlException",
+				fmt.Sprintf("Permission denied. user=%s is not the owner of inode=%s", user, filepath))
+		}
+	}
+
+	if owner != "" {
+		node.file.HadoopOwner = owner
+	}
+	if group != "" {
+		node.file.HadoopGroup = group
+	}
+
+	return nil
+}
+
+// CheckAccess 按文件的权限位检查，owner之外的用户使用other的权限
+func (memory *MemoryController) CheckAccess(ctx context.Context, filepath, fsaction string) (err error) {
+
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+
+	node, ok := memory.nodes[cleanPath(filepath)]
+	if !ok {
+		return herr.ErrNoFound
+	}
+
+	user := proxyUser(ctx)
+	if user == "" {
+		user = memory.username
+	}
+
+	mode, _ := strconv.ParseUint(node.file.HadoopPermission, 8, 16)
+	if user != node.file.HadoopOwner {
+		mode <<= 6
+	}
+
+	for i, bit := range []uint64{0400, 0200, 0100} {
+		if fsaction[i] != '-' && mode&bit == 0 {
+			return remoteException("AccessControlException", "org.apache.hadoop.security.AccessControlException",
+				fmt.Sprintf("Permission denied: user=%s, access=%s, inode=\"%s\"", user, fsaction, filepath))
+		}
+	}
+
+	return nil
+}
+
+// Rename 文件重命名，目标已存在时返回false
+func (memory *MemoryController) Rename(ctx context.Context, src, dest string) (result bool, err error) {
+
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+
+	src = cleanPath(src)
+	dest = cleanPath(dest)
+
+	if _, ok := memory.nodes[src]; !ok || src == "/" {
+		return false, nil
+	}
+	if _, ok := memory.nodes[dest]; ok {
+		return false, nil
+	}
+	if _, err := memory.getDir(cleanPath(util.GetParentPath(dest))); err != nil {
+		return false, nil
+	}
+	if strings.HasPrefix(dest, src+"/") {
+		// 不能移动到自己的子目录中
+		return false, nil
+	}
+
+	moved := make(map[string]*memoryNode)
+	for path, node := range memory.nodes {
+		if path == src || strings.HasPrefix(path, src+"/") {
+			moved[dest+strings.TrimPrefix(path, src)] = node
+			delete(memory.nodes, path)
+		}
+	}
+	for path, node := range moved {
+		memory.nodes[path] = node
+	}
+	memory.nodes[dest].file.Name = util.GetFileName(dest)
+
+	return true, nil
+}
+
+// CreateSymlink 与未开启symlink的HDFS一样，不支持
+func (memory *MemoryController) CreateSymlink(ctx context.Context, src, link string) (err error) {
+	return remoteException("UnsupportedOperationException", "java.lang.UnsupportedOperationException",
+		"Symlinks not supported")
+}
+
+// checkXattrName 检查xattr的namespace是否合法
+func checkXattrName(name string) error {
+	for _, prefix := range []string{"user.", "trusted.", "system.", "security.", "raw."} {
+		if strings.HasPrefix(name, prefix) && len(name) > len(prefix) {
+			return nil
+		}
+	}
+	return herr.ErrNotsup
+}
+
+// Setxattr setxattr
+func (memory *MemoryController) Setxattr(ctx context.Context, filepath, name, value, flag string) (err error) {
+
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+
+	if err = checkXattrName(name); err != nil {
+		return err
+	}
+
+	node, ok := memory.nodes[cleanPath(filepath)]
+	if !ok {
+		return herr.ErrNoFound
+	}
+
+	_, exist := node.xattrs[name]
+	if flag == "REPLACE" && !exist {
+		return herr.ErrNoAttr
+	} else if flag != "REPLACE" && exist {
+		return herr.ErrExist
+	}
+
+	node.xattrs[name] = value
+
+	return nil
+}
+
+// Getxattr getxattr
+func (memory *MemoryController) Getxattr(ctx context.Context, filepath, name string) (value string, err error) {
+
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+
+	node, ok := memory.nodes[cleanPath(filepath)]
+	if !ok {
+		return "", herr.ErrNoFound
+	}
+
+	value, ok = node.xattrs[name]
+	if !ok {
+		return "", herr.ErrNoAttr
+	}
+
+	return value, nil
+}
+
+// Listxattr lisstxattr
+func (memory *MemoryController) Listxattr(ctx context.Context, filepath string) (attrs []Xattr, err error) {
+
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+
+	node, ok := memory.nodes[cleanPath(filepath)]
+	if !ok {
+		return nil, herr.ErrNoFound
+	}
+
+	attrs = make([]Xattr, 0, len(node.xattrs))
+	for name, value := range node.xattrs {
+		attrs = append(attrs, Xattr{Name: name, Value: value})
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Name < attrs[j].Name })
+
+	return attrs, nil
+}
+
+// Removexattr removexattr
+func (memory *MemoryController) Removexattr(ctx context.Context, filepath, name string) (err error) {
+
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+
+	if err = checkXattrName(name); err != nil {
+		return err
+	}
+
+	node, ok := memory.nodes[cleanPath(filepath)]
+	if !ok {
+		return herr.ErrNoFound
+	}
+
+	if _, ok = node.xattrs[name]; !ok {
+		return herr.ErrNoAttr
+	}
+	delete(node.xattrs, name)
+
+	return nil
+}
+
+// GetAclStatus 获取文件的ACL
+func (memory *MemoryController) GetAclStatus(ctx context.Context, filepath string) (status AclStatus, err error) {
+
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+
+	node, ok := memory.nodes[cleanPath(filepath)]
+	if !ok {
+		return status, herr.ErrNoFound
+	}
+
+	mode, _ := strconv.ParseUint(node.file.HadoopPermission, 8, 16)
+
+	status.Entries = aclEntries(node.acl)
+	status.Owner = node.file.HadoopOwner
+	status.Group = node.file.HadoopGroup
+	status.Permission = node.file.HadoopPermission
+	status.StickyBit = mode&01000 != 0
+
+	return status, nil
+}
+
+// SetAcl 替换文件的ACL，aclspec 中只有access或者default项时，另一类保持不变
+func (memory *MemoryController) SetAcl(ctx context.Context, filepath, aclspec string) (err error) {
+
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+
+	node, ok := memory.nodes[cleanPath(filepath)]
+	if !ok {
+		return herr.ErrNoFound
+	}
+
+	spec, err := model.ParseAclSpec(aclspec)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, herr.ErrInvalid)
+	}
+
+	acl := replaceAcl(logicalAcl(node.file.HadoopPermission, node.acl), spec)
+	permission, entries, err := splitAcl(node.file.HadoopPermission, acl)
+	if err != nil {
+		return err
+	}
+
+	node.file.HadoopPermission = permission
+	node.acl = entries
+
+	return nil
+}
+
+// RemoveDefaultAcl 删除目录的default ACL
+func (memory *MemoryController) RemoveDefaultAcl(ctx context.Context, filepath string) (err error) {
+
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+
+	node, ok := memory.nodes[cleanPath(filepath)]
+	if !ok {
+		return herr.ErrNoFound
+	}
+
+	entries := make([]model.AclEntry, 0, len(node.acl))
+	for _, entry := range node.acl {
+		if !entry.Default {
+			entries = append(entries, entry)
+		}
+	}
+	node.acl = entries
+
+	return nil
+}
+
+// RemoveAclEntries 删除ACL项。与HDFS一样，删除了项的那一类重新计算mask，没有有名字的项时不再有mask，
+// access ACL的权限位的group恢复为group项的权限
+func (memory *MemoryController) RemoveAclEntries(ctx context.Context, filepath, aclspec string) (err error) {
+
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+
+	node, ok := memory.nodes[cleanPath(filepath)]
+	if !ok {
+		return herr.ErrNoFound
+	}
+
+	spec, err := model.ParseAclRemoveSpec(aclspec)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, herr.ErrInvalid)
+	}
+
+	type aclKey struct {
+		defaultAcl bool
+		kind, name string
+	}
+	removed := map[aclKey]bool{}
+	dirty := map[bool]bool{}
+	for _, entry := range spec {
+		removed[aclKey{entry.Default, entry.Type, entry.Name}] = true
+		dirty[entry.Default] = true
+	}
+
+	acl := make([]model.AclEntry, 0)
+	for _, entry := range logicalAcl(node.file.HadoopPermission, node.acl) {
+		if removed[aclKey{entry.Default, entry.Type, entry.Name}] || entry.Type == model.AclMask && dirty[entry.Default] {
+			continue
+		}
+		acl = append(acl, entry)
+	}
+
+	permission, entries, err := splitAcl(node.file.HadoopPermission, acl)
+	if err != nil {
+		return err
+	}
+
+	node.file.HadoopPermission = permission
+	node.acl = entries
+
+	return nil
+}
+
+// GetStatus 文件系统的容量固定为1TiB
+func (memory *MemoryController) GetStatus(ctx context.Context, path string) (status FsStatus, err error) {
+
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+
+	for _, node := range memory.nodes {
+		status.Used += int64(len(node.content))
+	}
+	status.Capacity = memoryCapacity
+	status.Remaining = status.Capacity - status.Used
+
+	return status, nil
+}
+
+// GetQuotaUsage 获取目录的配额和使用量
+func (memory *MemoryController) GetQuotaUsage(ctx context.Context, path string) (usage QuotaUsage, err error) {
+
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+
+	path = cleanPath(path)
+
+	node, ok := memory.nodes[path]
+	if !ok {
+		return usage, herr.ErrNoFound
+	}
+
+	usage.Quota = node.nsQuota
+	usage.SpaceQuota = node.spaceQuota
+	for filePath, child := range memory.nodes {
+		if filePath == path || path == "/" || strings.HasPrefix(filePath, path+"/") {
+			usage.FileAndDirectoryCount++
+			usage.SpaceConsumed += int64(len(child.content))
+		}
+	}
+
+	return usage, nil
+}
+
+// SetQuota 设置目录的配额，-1表示没有配额
+func (memory *MemoryController) SetQuota(path string, nsQuota, spaceQuota int64) error {
+
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+
+	node, err := memory.getDir(cleanPath(path))
+	if err != nil {
+		return err
+	}
+	node.nsQuota = nsQuota
+	node.spaceQuota = spaceQuota
+
+	return nil
+}
@@ -0,0 +1,646 @@
+package controler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hadoop-fs/fs/config"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"hadoop-fs/fs/logger"
+	"hadoop-fs/fs/model"
+	"hash/fnv"
+	"io"
+	"math"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 挂载表中虚拟目录的权限，虚拟目录不能修改
+const mountInternalPermission = "555"
+
+// MountTable 与ViewFS的mount table一样，把挂载点中的子目录映射到不同HDFS集群中的目录，
+// 按路径的最长前缀把请求转发给对应集群的后端。挂载点的上级目录是只读的虚拟目录
+type MountTable struct {
+	clusters []mountCluster
+	// mounts 按路径从长到短排序，先匹配最长的前缀
+	mounts []mountEntry
+
+	// 虚拟目录的修改时间(ms)
+	created int64
+}
+
+// mountCluster 一个集群和它的后端
+type mountCluster struct {
+	name    string
+	backend Backend
+}
+
+// mountEntry 挂载表中的一项
+type mountEntry struct {
+	path    string // 挂载点中的路径
+	remote  string // 集群中的路径
+	cluster int    // clusters 的下标
+}
+
+// Init 初始化函数
+func (table *MountTable) Init() {
+	table.clusters = nil
+	table.mounts = nil
+	table.created = time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+// AddCluster 添加一个集群，需要在 Mount 之前调用
+func (table *MountTable) AddCluster(name string, backend Backend) error {
+
+	if table.clusterIndex(name) >= 0 {
+		return fmt.Errorf("mount table: duplicate cluster [%s]", name)
+	}
+
+	table.clusters = append(table.clusters, mountCluster{name: name, backend: backend})
+	return nil
+}
+
+func (table *MountTable) clusterIndex(name string) int {
+	for i, cluster := range table.clusters {
+		if cluster.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Mount 把挂载点中的 localPath 映射到集群 cluster 中的 remotePath，挂载点之间不能嵌套
+func (table *MountTable) Mount(localPath, cluster, remotePath string) error {
+
+	if !strings.HasPrefix(localPath, "/") || !strings.HasPrefix(remotePath, "/") {
+		return fmt.Errorf("mount table: [%s] and [%s] must be absolute paths", localPath, remotePath)
+	}
+	localPath = path.Clean(localPath)
+	remotePath = path.Clean(remotePath)
+
+	index := table.clusterIndex(cluster)
+	if index < 0 {
+		return fmt.Errorf("mount table: unknown cluster [%s] of [%s]", cluster, localPath)
+	}
+
+	for _, entry := range table.mounts {
+		if isSubPath(localPath, entry.path) || isSubPath(entry.path, localPath) {
+			return fmt.Errorf("mount table: [%s] overlaps with [%s]", localPath, entry.path)
+		}
+	}
+
+	table.mounts = append(table.mounts, mountEntry{path: localPath, remote: remotePath, cluster: index})
+	sort.Slice(table.mounts, func(i, j int) bool {
+		return len(table.mounts[i].path) > len(table.mounts[j].path)
+	})
+
+	logger.Info.Printf("mount table: [%s] -> %s:[%s]\n", localPath, cluster, remotePath)
+
+	return nil
+}
+
+// isSubPath p 是否是 dir 或者在 dir 之下
+func isSubPath(p, dir string) bool {
+	return p == dir || dir == "/" || strings.HasPrefix(p, dir+"/")
+}
+
+// resolve 返回 p 所在的挂载项和在集群中的路径，不在任何挂载点下时返回nil
+func (table *MountTable) resolve(p string) (*mountEntry, string) {
+
+	if p == "" {
+		return nil, ""
+	}
+	p = path.Clean(p)
+
+	for i := range table.mounts {
+		entry := &table.mounts[i]
+		if isSubPath(p, entry.path) {
+			return entry, path.Join(entry.remote, strings.TrimPrefix(p, entry.path))
+		}
+	}
+
+	return nil, ""
+}
+
+// internalChildren 虚拟目录 p 下的子目录名，p 不是虚拟目录时返回nil
+func (table *MountTable) internalChildren(p string) []string {
+
+	if p == "" {
+		return nil
+	}
+	p = path.Clean(p)
+
+	prefix := p + "/"
+	if p == "/" {
+		prefix = "/"
+	}
+
+	names := make(map[string]struct{})
+	for _, entry := range table.mounts {
+		if entry.path != p && strings.HasPrefix(entry.path, prefix) {
+			name := strings.SplitN(strings.TrimPrefix(entry.path, prefix), "/", 2)[0]
+			names[name] = struct{}{}
+		}
+	}
+
+	children := make([]string, 0, len(names))
+	for name := range names {
+		children = append(children, name)
+	}
+	sort.Strings(children)
+
+	if len(children) == 0 {
+		return nil
+	}
+	return children
+}
+
+// route 返回 p 对应的后端和集群中的路径，用于会修改文件的操作：虚拟目录不能修改
+func (table *MountTable) route(p string) (*mountEntry, Backend, string, error) {
+
+	entry, remote := table.resolve(p)
+	if entry == nil {
+		if table.internalChildren(path.Dir(p)) != nil || table.internalChildren(p) != nil {
+			return nil, nil, "", fmt.Errorf("mount table: [%s] is in a read-only internal directory: %w", p, herr.ErrAccess)
+		}
+		return nil, nil, "", herr.ErrNoFound
+	}
+
+	return entry, table.clusters[entry.cluster].backend, remote, nil
+}
+
+// routeMountPoint 与 route 一样，但是不能是挂载点本身(删除或者重命名挂载点会使挂载表失效)
+func (table *MountTable) routeMountPoint(p string) (Backend, string, error) {
+
+	entry, backend, remote, err := table.route(p)
+	if err != nil {
+		return nil, "", err
+	}
+	if path.Clean(p) == entry.path {
+		return nil, "", fmt.Errorf("mount table: can not remove or rename mount point [%s]: %w", entry.path, herr.ErrAccess)
+	}
+
+	return backend, remote, nil
+}
+
+// fileID 让不同集群的fileId不会重复：集群i的fileId为 id*n+i，n 为集群数加1，最后一个留给虚拟目录。
+// fileId 超过 2^64/n 时无法映射，返回错误
+func (table *MountTable) fileID(cluster int, file *model.FileModel) error {
+
+	slots := uint64(len(table.clusters) + 1)
+
+	if file.StIno > (math.MaxUint64-uint64(cluster))/slots {
+		return fmt.Errorf("mount table: fileId %d of cluster %d is too large to map to an inode number", file.StIno, cluster)
+	}
+
+	file.StIno = file.StIno*slots + uint64(cluster)
+	if file.StIno <= 1 {
+		// 1 是FUSE根目录的nodeid
+		file.StIno += slots
+	}
+
+	return nil
+}
+
+// internalStatus 虚拟目录的文件信息
+func (table *MountTable) internalStatus(p string) model.FileModel {
+
+	file := model.FileModel{
+		HadoopType:       model.HadoopDir,
+		HadoopPermission: mountInternalPermission,
+		StMtime:          table.created,
+		StAtime:          table.created,
+		ChildrenNum:      len(table.internalChildren(p)),
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(path.Clean(p)))
+	file.StIno = uint64(hash.Sum32())
+	// 32位的hash不会溢出
+	table.fileID(len(table.clusters), &file)
+
+	return file
+}
+
+// List 列出目录下的文件，虚拟目录下列出挂载点和下一级的虚拟目录
+func (table *MountTable) List(ctx context.Context, dir, startAfter string) (fileList []model.FileModel, remain int, err error) {
+
+	if entry, remote := table.resolve(dir); entry != nil {
+		fileList, remain, err = table.clusters[entry.cluster].backend.List(ctx, remote, startAfter)
+		if err != nil {
+			return nil, 0, err
+		}
+		for i := range fileList {
+			if err = table.fileID(entry.cluster, &fileList[i]); err != nil {
+				return nil, 0, err
+			}
+		}
+		return fileList, remain, nil
+	}
+
+	children := table.internalChildren(dir)
+	if children == nil {
+		return nil, 0, herr.ErrNoFound
+	}
+
+	for _, name := range children {
+		if name <= startAfter {
+			continue
+		}
+
+		file, err := table.GetFileStatus(ctx, path.Join(dir, name))
+		if err != nil {
+			return nil, 0, err
+		}
+		file.Name = name
+		fileList = append(fileList, file)
+	}
+
+	return fileList, 0, nil
+}
+
+// GetHomeDirectory 挂载表中的home目录与ViewFS一样是 /user/<用户名>，用户名由挂载在 /user 的集群返回
+func (table *MountTable) GetHomeDirectory(ctx context.Context) (home string, err error) {
+
+	entry, _ := table.resolve("/user")
+	if entry == nil {
+		return "", fmt.Errorf("mount table: no cluster is mounted on /user: %w", herr.ErrNoFound)
+	}
+
+	home, err = table.clusters[entry.cluster].backend.GetHomeDirectory(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join("/user", path.Base(home)), nil
+}
+
+// GetFileStatus 获取文件信息
+func (table *MountTable) GetFileStatus(ctx context.Context, filePath string) (file model.FileModel, err error) {
+
+	if entry, remote := table.resolve(filePath); entry != nil {
+		file, err = table.clusters[entry.cluster].backend.GetFileStatus(ctx, remote)
+		if err == nil {
+			err = table.fileID(entry.cluster, &file)
+		}
+		return file, err
+	}
+
+	if table.internalChildren(filePath) == nil {
+		return file, herr.ErrNoFound
+	}
+
+	return table.internalStatus(filePath), nil
+}
+
+// Read 读取文件内容
+func (table *MountTable) Read(ctx context.Context, filePath string, offset uint64, length uint32, buffersize int) (content []byte, err error) {
+
+	_, backend, remote, err := table.route(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return backend.Read(ctx, remote, offset, length, buffersize)
+}
+
+// MakeDir 创建目录
+func (table *MountTable) MakeDir(ctx context.Context, pathname, permission string) (result bool, err error) {
+
+	_, backend, remote, err := table.route(pathname)
+	if err != nil {
+		return false, err
+	}
+
+	return backend.MakeDir(ctx, remote, permission)
+}
+
+// Create 创建空文件
+func (table *MountTable) Create(ctx context.Context, filepath, permission string) (err error) {
+
+	_, backend, remote, err := table.route(filepath)
+	if err != nil {
+		return err
+	}
+
+	return backend.Create(ctx, remote, permission)
+}
+
+// ModificationTime 设置文件Mtime和Atime
+func (table *MountTable) ModificationTime(ctx context.Context, filepath string, mtime, atime int64) (err error) {
+
+	_, backend, remote, err := table.route(filepath)
+	if err != nil {
+		return err
+	}
+
+	return backend.ModificationTime(ctx, remote, mtime, atime)
+}
+
+// AppendFile 追加文件内容
+func (table *MountTable) AppendFile(ctx context.Context, filepath string, content []byte) (err error) {
+
+	_, backend, remote, err := table.route(filepath)
+	if err != nil {
+		return err
+	}
+
+	return backend.AppendFile(ctx, remote, content)
+}
+
+// TruncateFile Truncate 文件
+func (table *MountTable) TruncateFile(ctx context.Context, filepath string, newlength int64) (result bool, err error) {
+
+	_, backend, remote, err := table.route(filepath)
+	if err != nil {
+		return false, err
+	}
+
+	return backend.TruncateFile(ctx, remote, newlength)
+}
+
+// Delete 删除文件或者目录，不能删除挂载点
+func (table *MountTable) Delete(ctx context.Context, filepath string) (result bool, err error) {
+
+	backend, remote, err := table.routeMountPoint(filepath)
+	if err != nil {
+		return false, err
+	}
+
+	return backend.Delete(ctx, remote)
+}
+
+// SetPermission 设置文件权限
+func (table *MountTable) SetPermission(ctx context.Context, filepath, permission string) (err error) {
+
+	_, backend, remote, err := table.route(filepath)
+	if err != nil {
+		return err
+	}
+
+	return backend.SetPermission(ctx, remote, permission)
+}
+
+// GetAclStatus 获取文件的ACL，虚拟目录没有扩展ACL
+func (table *MountTable) GetAclStatus(ctx context.Context, filepath string) (status AclStatus, err error) {
+
+	entry, remote := table.resolve(filepath)
+	if entry == nil {
+		if table.internalChildren(filepath) == nil {
+			return status, herr.ErrNoFound
+		}
+		file := table.internalStatus(filepath)
+		return AclStatus{Entries: []string{}, Owner: file.HadoopOwner, Group: file.HadoopGroup, Permission: file.HadoopPermission}, nil
+	}
+
+	return table.clusters[entry.cluster].backend.GetAclStatus(ctx, remote)
+}
+
+// SetAcl 替换文件的ACL
+func (table *MountTable) SetAcl(ctx context.Context, filepath, aclspec string) (err error) {
+
+	_, backend, remote, err := table.route(filepath)
+	if err != nil {
+		return err
+	}
+
+	return backend.SetAcl(ctx, remote, aclspec)
+}
+
+// RemoveDefaultAcl 删除目录的default ACL
+func (table *MountTable) RemoveDefaultAcl(ctx context.Context, filepath string) (err error) {
+
+	_, backend, remote, err := table.route(filepath)
+	if err != nil {
+		return err
+	}
+
+	return backend.RemoveDefaultAcl(ctx, remote)
+}
+
+// RemoveAclEntries 删除ACL项
+func (table *MountTable) RemoveAclEntries(ctx context.Context, filepath, aclspec string) (err error) {
+
+	_, backend, remote, err := table.route(filepath)
+	if err != nil {
+		return err
+	}
+
+	return backend.RemoveAclEntries(ctx, remote, aclspec)
+}
+
+// SetOwner 设置文件的owner和group
+func (table *MountTable) SetOwner(ctx context.Context, filepath, owner, group string) (err error) {
+
+	_, backend, remote, err := table.route(filepath)
+	if err != nil {
+		return err
+	}
+
+	return backend.SetOwner(ctx, remote, owner, group)
+}
+
+// CheckAccess 检查权限，虚拟目录是只读的
+func (table *MountTable) CheckAccess(ctx context.Context, filepath, fsaction string) (err error) {
+
+	entry, remote := table.resolve(filepath)
+	if entry == nil {
+		if table.internalChildren(filepath) == nil {
+			return herr.ErrNoFound
+		}
+		if strings.Contains(fsaction, "w") {
+			return fmt.Errorf("mount table: [%s] is a read-only internal directory: %w", filepath, herr.ErrAccess)
+		}
+		return nil
+	}
+
+	return table.clusters[entry.cluster].backend.CheckAccess(ctx, remote, fsaction)
+}
+
+// Rename 文件重命名，只能在同一个集群中进行，跨集群或者目标是挂载点时返回 herr.ErrCrossDevice
+func (table *MountTable) Rename(ctx context.Context, src, dest string) (result bool, err error) {
+
+	srcBackend, srcRemote, err := table.routeMountPoint(src)
+	if err != nil {
+		return false, err
+	}
+	destEntry, _, destRemote, err := table.route(dest)
+	if err != nil {
+		return false, err
+	}
+	if path.Clean(dest) == destEntry.path {
+		// 与重命名到另一个文件系统的挂载点一样，mv 会改为复制
+		return false, fmt.Errorf("mount table: can not rename [%s] onto mount point [%s]: %w", src, destEntry.path, herr.ErrCrossDevice)
+	}
+
+	srcEntry, _ := table.resolve(src)
+	if srcEntry.cluster != destEntry.cluster {
+		return false, fmt.Errorf("mount table: rename [%s] on %s to [%s] on %s: %w", src,
+			table.clusters[srcEntry.cluster].name, dest, table.clusters[destEntry.cluster].name, herr.ErrCrossDevice)
+	}
+
+	return srcBackend.Rename(ctx, srcRemote, destRemote)
+}
+
+// CreateSymlink 创建软连接，src 是链接的目标，不转换
+func (table *MountTable) CreateSymlink(ctx context.Context, src, link string) (err error) {
+
+	_, backend, remote, err := table.route(link)
+	if err != nil {
+		return err
+	}
+
+	return backend.CreateSymlink(ctx, src, remote)
+}
+
+// Setxattr 设置文件额外属性
+func (table *MountTable) Setxattr(ctx context.Context, filepath, name, value, flag string) (err error) {
+
+	_, backend, remote, err := table.route(filepath)
+	if err != nil {
+		return err
+	}
+
+	return backend.Setxattr(ctx, remote, name, value, flag)
+}
+
+// Getxattr 获取指定名字的文件额外属性值，虚拟目录没有额外属性
+func (table *MountTable) Getxattr(ctx context.Context, filepath, name string) (value string, err error) {
+
+	entry, remote := table.resolve(filepath)
+	if entry == nil {
+		if table.internalChildren(filepath) != nil {
+			return "", herr.ErrNoAttr
+		}
+		return "", herr.ErrNoFound
+	}
+
+	return table.clusters[entry.cluster].backend.Getxattr(ctx, remote, name)
+}
+
+// Listxattr 列出文件所有的额外属性
+func (table *MountTable) Listxattr(ctx context.Context, filepath string) (attrs []Xattr, err error) {
+
+	entry, remote := table.resolve(filepath)
+	if entry == nil {
+		if table.internalChildren(filepath) != nil {
+			return nil, nil
+		}
+		return nil, herr.ErrNoFound
+	}
+
+	return table.clusters[entry.cluster].backend.Listxattr(ctx, remote)
+}
+
+// Removexattr 删除文件额外属性
+func (table *MountTable) Removexattr(ctx context.Context, filepath, name string) (err error) {
+
+	_, backend, remote, err := table.route(filepath)
+	if err != nil {
+		return err
+	}
+
+	return backend.Removexattr(ctx, remote, name)
+}
+
+// MountPoint 返回 p 所在的挂载点，在虚拟目录中时返回 "/"
+func (table *MountTable) MountPoint(p string) string {
+
+	entry, _ := table.resolve(p)
+	if entry == nil {
+		return "/"
+	}
+
+	return entry.path
+}
+
+// GetStatus 获取 path 所在集群的容量，虚拟目录中时返回所有集群的总和
+func (table *MountTable) GetStatus(ctx context.Context, p string) (status FsStatus, err error) {
+
+	if entry, remote := table.resolve(p); entry != nil {
+		return table.clusters[entry.cluster].backend.GetStatus(ctx, remote)
+	}
+
+	supported := false
+	for _, cluster := range table.clusters {
+		clusterStatus, err := cluster.backend.GetStatus(ctx, "/")
+		if errors.Is(err, herr.ErrNotsup) {
+			continue
+		}
+		if err != nil {
+			return FsStatus{}, fmt.Errorf("cluster [%s]: %w", cluster.name, err)
+		}
+
+		supported = true
+		status.Capacity += clusterStatus.Capacity
+		status.Used += clusterStatus.Used
+		status.Remaining += clusterStatus.Remaining
+	}
+
+	if !supported {
+		return status, herr.ErrNotsup
+	}
+	return status, nil
+}
+
+// GetQuotaUsage 获取目录的配额和使用量，虚拟目录没有配额
+func (table *MountTable) GetQuotaUsage(ctx context.Context, p string) (usage QuotaUsage, err error) {
+
+	if entry, remote := table.resolve(p); entry != nil {
+		return table.clusters[entry.cluster].backend.GetQuotaUsage(ctx, remote)
+	}
+
+	if table.internalChildren(p) == nil {
+		return usage, herr.ErrNoFound
+	}
+
+	return QuotaUsage{Quota: -1, SpaceQuota: -1}, nil
+}
+
+// Close 释放所有集群的后端的资源
+func (table *MountTable) Close() error {
+
+	var result error
+	for _, cluster := range table.clusters {
+		if closer, ok := cluster.backend.(io.Closer); ok {
+			if err := closer.Close(); err != nil && result == nil {
+				result = fmt.Errorf("cluster [%s]: %v", cluster.name, err)
+			}
+		}
+	}
+
+	return result
+}
+
+// newMountTable 根据配置创建每个集群的后端和挂载表，集群按名字排序，保证fileId不变
+func newMountTable(cg config.Config) (*MountTable, error) {
+
+	table := &MountTable{}
+	table.Init()
+
+	names := make([]string, 0, len(cg.Clusters))
+	for name := range cg.Clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		backend, err := newBackend(cg.Backend, cg.Clusters[name])
+		if err != nil {
+			table.Close()
+			return nil, fmt.Errorf("cluster [%s]: %v", name, err)
+		}
+		table.AddCluster(name, backend)
+	}
+
+	for _, mount := range cg.Mounts {
+		if err := table.Mount(mount.Path, mount.Cluster, mount.Remote); err != nil {
+			table.Close()
+			return nil, err
+		}
+	}
+
+	return table, nil
+}
@@ -0,0 +1,222 @@
+package controler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"hadoop-fs/fs/model"
+	"math"
+	"testing"
+)
+
+func newTestMountTable(t *testing.T) (*MountTable, *MemoryController, *MemoryController) {
+	t.Helper()
+	ctx := context.Background()
+
+	sales := &MemoryController{}
+	sales.Init("alice")
+	logs := &MemoryController{}
+	logs.Init("alice")
+
+	for _, dir := range []string{"/data/sales/2024", "/user/alice"} {
+		if _, err := sales.MakeDir(ctx, dir, "755"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := logs.MakeDir(ctx, "/logs/web", "755"); err != nil {
+		t.Fatal(err)
+	}
+
+	table := &MountTable{}
+	table.Init()
+	if err := table.AddCluster("sales", sales); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.AddCluster("logs", logs); err != nil {
+		t.Fatal(err)
+	}
+
+	mounts := [][3]string{
+		{"/sales", "sales", "/data/sales"},
+		{"/user", "sales", "/user"},
+		{"/archive/logs", "logs", "/logs"},
+	}
+	for _, mount := range mounts {
+		if err := table.Mount(mount[0], mount[1], mount[2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return table, sales, logs
+}
+
+func TestMountTableRouting(t *testing.T) {
+	table, sales, logs := newTestMountTable(t)
+	ctx := context.Background()
+
+	if err := table.Create(ctx, "/sales/2024/q1.csv", "644"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := sales.GetFileStatus(ctx, "/data/sales/2024/q1.csv"); err != nil {
+		t.Errorf("file should be created in the sales cluster: %v", err)
+	}
+
+	if _, err := table.MakeDir(ctx, "/archive/logs/app", "755"); err != nil {
+		t.Fatalf("MakeDir: %v", err)
+	}
+	if _, err := logs.GetFileStatus(ctx, "/logs/app"); err != nil {
+		t.Errorf("directory should be created in the logs cluster: %v", err)
+	}
+
+	files, remain, err := table.List(ctx, "/archive/logs", "")
+	if err != nil || remain != 0 || len(files) != 2 || files[0].Name != "app" || files[1].Name != "web" {
+		t.Errorf("List /archive/logs: got %+v, %d, %v", files, remain, err)
+	}
+
+	home, err := table.GetHomeDirectory(ctx)
+	if err != nil || home != "/user/alice" {
+		t.Errorf("GetHomeDirectory: got %q, %v", home, err)
+	}
+}
+
+func TestMountTableInternalDirectories(t *testing.T) {
+	table, _, _ := newTestMountTable(t)
+	ctx := context.Background()
+
+	files, _, err := table.List(ctx, "/", "")
+	if err != nil || len(files) != 3 {
+		t.Fatalf("List /: got %+v, %v", files, err)
+	}
+	for i, name := range []string{"archive", "sales", "user"} {
+		if files[i].Name != name || files[i].HadoopType != model.HadoopDir {
+			t.Errorf("List / [%d]: got %q %s, want directory %q", i, files[i].Name, files[i].HadoopType, name)
+		}
+	}
+
+	archive, err := table.GetFileStatus(ctx, "/archive")
+	if err != nil || archive.HadoopPermission != mountInternalPermission || archive.ChildrenNum != 1 {
+		t.Errorf("GetFileStatus /archive: got %+v, %v", archive, err)
+	}
+
+	if _, err = table.GetFileStatus(ctx, "/missing"); !errors.Is(err, herr.ErrNoFound) {
+		t.Errorf("GetFileStatus /missing: got %v, want ErrNoFound", err)
+	}
+	if _, err = table.MakeDir(ctx, "/archive/new", "755"); !errors.Is(err, herr.ErrAccess) {
+		t.Errorf("MakeDir in internal directory: got %v, want ErrAccess", err)
+	}
+	if _, err = table.Delete(ctx, "/sales"); !errors.Is(err, herr.ErrAccess) {
+		t.Errorf("Delete mount point: got %v, want ErrAccess", err)
+	}
+	if _, err = table.Getxattr(ctx, "/archive", "user.a"); !errors.Is(err, herr.ErrNoAttr) {
+		t.Errorf("Getxattr of internal directory: got %v, want ErrNoAttr", err)
+	}
+}
+
+func TestMountTableRename(t *testing.T) {
+	table, sales, _ := newTestMountTable(t)
+	ctx := context.Background()
+
+	if err := table.Create(ctx, "/sales/2024/q1.csv", "644"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 同一个集群中的不同挂载点
+	if _, err := table.Rename(ctx, "/sales/2024/q1.csv", "/user/alice/q1.csv"); err != nil {
+		t.Fatalf("Rename in the same cluster: %v", err)
+	}
+	if _, err := sales.GetFileStatus(ctx, "/user/alice/q1.csv"); err != nil {
+		t.Errorf("renamed file: %v", err)
+	}
+
+	_, err := table.Rename(ctx, "/user/alice/q1.csv", "/archive/logs/q1.csv")
+	if !errors.Is(err, herr.ErrCrossDevice) {
+		t.Errorf("Rename across clusters: got %v, want ErrCrossDevice", err)
+	}
+	if _, err = table.Rename(ctx, "/user", "/users"); !errors.Is(err, herr.ErrAccess) {
+		t.Errorf("Rename mount point: got %v, want ErrAccess", err)
+	}
+	// 同一个集群中重命名到挂载点本身
+	if _, err = table.Rename(ctx, "/user/alice/q1.csv", "/sales/"); !errors.Is(err, herr.ErrCrossDevice) {
+		t.Errorf("Rename onto mount point: got %v, want ErrCrossDevice", err)
+	}
+	if _, err = sales.GetFileStatus(ctx, "/user/alice/q1.csv"); err != nil {
+		t.Errorf("file renamed onto mount point: %v", err)
+	}
+}
+
+func TestMountTableFileID(t *testing.T) {
+	table, _, _ := newTestMountTable(t)
+
+	seen := map[uint64]string{}
+	for cluster := 0; cluster <= len(table.clusters); cluster++ {
+		// 0 表示没有fileId，只要求不是根目录的nodeid
+		zero := model.FileModel{}
+		if table.fileID(cluster, &zero); zero.StIno <= 1 {
+			t.Errorf("fileID(%d, 0) = %d, must not be the root nodeid", cluster, zero.StIno)
+		}
+
+		// 超过32位的fileId也不会重复
+		for _, id := range []uint64{1, 16385, 16386, 1 << 32, 1<<32 + 1} {
+			file := model.FileModel{StIno: id}
+			if err := table.fileID(cluster, &file); err != nil {
+				t.Fatalf("fileID(%d, %d): %v", cluster, id, err)
+			}
+			key := fmt.Sprintf("cluster %d id %d", cluster, id)
+			if other, ok := seen[file.StIno]; ok {
+				t.Errorf("%s and %s are both mapped to %d", key, other, file.StIno)
+			}
+			seen[file.StIno] = key
+		}
+	}
+
+	// 无法映射时返回错误
+	if err := table.fileID(1, &model.FileModel{StIno: math.MaxUint64 / 2}); err == nil {
+		t.Errorf("fileID of a too large fileId: expected error")
+	}
+}
+
+func TestMountTableMountErrors(t *testing.T) {
+	table, _, _ := newTestMountTable(t)
+
+	cases := [][3]string{
+		{"/sales/2024", "sales", "/other"},
+		{"/archive", "logs", "/"},
+		{"/new", "missing", "/"},
+		{"relative", "sales", "/"},
+	}
+	for _, c := range cases {
+		if err := table.Mount(c[0], c[1], c[2]); err == nil {
+			t.Errorf("Mount(%q, %q, %q): expected error", c[0], c[1], c[2])
+		}
+	}
+	if err := table.AddCluster("sales", &MemoryController{}); err == nil {
+		t.Errorf("AddCluster with duplicate name: expected error")
+	}
+}
+
+func TestMountTableStatus(t *testing.T) {
+	table, sales, _ := newTestMountTable(t)
+	ctx := context.Background()
+
+	if err := sales.SetQuota("/data/sales", 10, -1); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := table.MountPoint("/sales/2024"); got != "/sales" {
+		t.Errorf("MountPoint(/sales/2024) = %q", got)
+	}
+	if got := table.MountPoint("/archive"); got != "/" {
+		t.Errorf("MountPoint(/archive) = %q", got)
+	}
+
+	status, err := table.GetStatus(ctx, "/")
+	if err != nil || status.Capacity != 2*memoryCapacity {
+		t.Errorf("GetStatus of internal directory should be the sum of clusters: got %+v, %v", status, err)
+	}
+
+	usage, err := table.GetQuotaUsage(ctx, "/sales")
+	if err != nil || usage.Quota != 10 || usage.FileAndDirectoryCount != 2 {
+		t.Errorf("GetQuotaUsage /sales: got %+v, %v", usage, err)
+	}
+}
@@ -0,0 +1,215 @@
+package controler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hadoop-fs/fs/logger"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OAuth2的授权方式
+const (
+	grantClientCredentials = "client_credentials"
+	grantRefreshToken      = "refresh_token"
+)
+
+// 与Kerberos一样，在access token有效期过了80%时重新获取
+const oauth2RefreshFactor = 0.8
+
+// 请求token endpoint的超时时间
+const oauth2Timeout = 30 * time.Second
+
+// OAuth2Authenticator 从token endpoint获取access token，以 Authorization: Bearer 的方式认证，
+// 对应WebHDFS的 dfs.webhdfs.oauth2.enabled。refreshToken 不为空时使用refresh_token授权，否则使用client_credentials
+type OAuth2Authenticator struct {
+	lock sync.Mutex
+
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	refreshToken string
+	scope        string
+
+	// clientSecretFile 不为空时从文件读取client secret，服务端返回401时重新读取
+	clientSecretFile string
+
+	client *http.Client
+
+	accessToken string
+	refresh     time.Time // 为零值时只在服务端返回401时重新获取
+}
+
+// oauth2Token token endpoint 返回的token，失败时返回error
+type oauth2Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Init 初始化函数，会先获取一次access token。clientSecretFile、refreshTokenFile 不为空时从文件读取，
+// 否则使用 clientSecret、refreshToken。refresh token只在初始化时读取，之后使用服务端换过的
+func (oauth *OAuth2Authenticator) Init(tokenURL, clientID, clientSecret, clientSecretFile, refreshToken, refreshTokenFile, scope string) error {
+
+	if tokenURL == "" || clientID == "" {
+		return errors.New("oauth2 token url and client id are required")
+	}
+
+	if refreshTokenFile != "" {
+		token, err := readSecretFile("oauth2 refresh token", refreshTokenFile)
+		if err != nil {
+			return err
+		}
+		refreshToken = token
+	}
+	if refreshToken == "" && clientSecret == "" && clientSecretFile == "" {
+		return errors.New("oauth2 client secret or refresh token is required")
+	}
+
+	oauth.tokenURL = tokenURL
+	oauth.clientID = clientID
+	oauth.clientSecret = clientSecret
+	oauth.clientSecretFile = clientSecretFile
+	oauth.refreshToken = refreshToken
+	oauth.scope = scope
+	oauth.client = &http.Client{Timeout: oauth2Timeout}
+
+	oauth.lock.Lock()
+	defer oauth.lock.Unlock()
+
+	if err := oauth.loadClientSecret(); err != nil {
+		return err
+	}
+
+	return oauth.fetch()
+}
+
+// loadClientSecret 从文件读取client secret，调用前需要持有锁
+func (oauth *OAuth2Authenticator) loadClientSecret() error {
+
+	if oauth.clientSecretFile == "" {
+		return nil
+	}
+
+	secret, err := readSecretFile("oauth2 client secret", oauth.clientSecretFile)
+	if err != nil {
+		return err
+	}
+	if oauth.clientSecret != "" && oauth.clientSecret != secret {
+		logger.Info.Printf("oauth2 client secret of [%s] is changed\n", oauth.clientID)
+	}
+	oauth.clientSecret = secret
+
+	return nil
+}
+
+// grantType 使用的授权方式
+func (oauth *OAuth2Authenticator) grantType() string {
+	if oauth.refreshToken != "" {
+		return grantRefreshToken
+	}
+	return grantClientCredentials
+}
+
+// fetch 从token endpoint获取新的access token，调用前需要持有锁
+func (oauth *OAuth2Authenticator) fetch() error {
+
+	grant := oauth.grantType()
+
+	form := url.Values{}
+	form.Set("grant_type", grant)
+	form.Set("client_id", oauth.clientID)
+	if oauth.clientSecret != "" {
+		form.Set("client_secret", oauth.clientSecret)
+	}
+	if grant == grantRefreshToken {
+		form.Set("refresh_token", oauth.refreshToken)
+	}
+	if oauth.scope != "" {
+		form.Set("scope", oauth.scope)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, oauth.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := oauth.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("oauth2 request token from [%s] failed: %v", oauth.tokenURL, err)
+	}
+	defer resp.Body.Close()
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("oauth2 request token from [%s] failed: %v", oauth.tokenURL, err)
+	}
+
+	token := oauth2Token{}
+	if err = json.Unmarshal(buf, &token); err != nil && resp.StatusCode == http.StatusOK {
+		return fmt.Errorf("oauth2 decode token from [%s] failed: %v", oauth.tokenURL, err)
+	}
+	if resp.StatusCode != http.StatusOK || token.Error != "" || token.AccessToken == "" {
+		return fmt.Errorf("oauth2 request token from [%s] failed: status %d, %s %s",
+			oauth.tokenURL, resp.StatusCode, token.Error, token.ErrorDescription)
+	}
+	if token.TokenType != "" && !strings.EqualFold(token.TokenType, "bearer") {
+		return fmt.Errorf("oauth2 unsupported token type [%s]", token.TokenType)
+	}
+
+	oauth.accessToken = token.AccessToken
+	if token.RefreshToken != "" && grant == grantRefreshToken {
+		// 有的服务端每次都会换一个新的refresh token
+		oauth.refreshToken = token.RefreshToken
+	}
+
+	oauth.refresh = time.Time{}
+	if token.ExpiresIn > 0 {
+		expiresIn := time.Duration(token.ExpiresIn) * time.Second
+		oauth.refresh = time.Now().Add(time.Duration(float64(expiresIn) * oauth2RefreshFactor))
+	}
+
+	logger.Info.Printf("oauth2 got access token from [%s] by %s, expires in %ds\n", oauth.tokenURL, grant, token.ExpiresIn)
+
+	return nil
+}
+
+// Authenticate 添加 Authorization: Bearer 请求头，access token快过期时先重新获取
+func (oauth *OAuth2Authenticator) Authenticate(req *http.Request) error {
+
+	oauth.lock.Lock()
+	defer oauth.lock.Unlock()
+
+	if oauth.accessToken == "" || (!oauth.refresh.IsZero() && time.Now().After(oauth.refresh)) {
+		if err := oauth.fetch(); err != nil {
+			return err
+		}
+	}
+
+	req.Header.Set("Authorization", "Bearer "+oauth.accessToken)
+	return nil
+}
+
+// Refresh 重新读取client secret后重新获取access token
+func (oauth *OAuth2Authenticator) Refresh() error {
+
+	oauth.lock.Lock()
+	defer oauth.lock.Unlock()
+
+	if err := oauth.loadClientSecret(); err != nil {
+		return err
+	}
+
+	return oauth.fetch()
+}
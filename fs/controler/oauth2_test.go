@@ -0,0 +1,176 @@
+package controler
+
+import (
+	"context"
+	"errors"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"hadoop-fs/fs/controler/webhdfstest"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newOAuth2TestController(t *testing.T, tokens *webhdfstest.TokenServer, refreshToken string) (*HadoopController, *webhdfstest.Server, *OAuth2Authenticator) {
+	t.Helper()
+
+	hadoop, server := newTestController(t)
+	server.Authorize = tokens.Authorize
+
+	oauth := &OAuth2Authenticator{}
+	if err := oauth.Init(tokens.URL, tokens.ClientID, tokens.ClientSecret, "", refreshToken, "", ""); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	hadoop.SetAuthenticator(oauth)
+
+	return hadoop, server, oauth
+}
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	ctx := context.Background()
+	tokens := webhdfstest.NewTokenServer("hadoop-fs", "secret")
+	defer tokens.Close()
+
+	hadoop, server, _ := newOAuth2TestController(t, tokens, "")
+	server.AddFile("/file", []byte("data"))
+
+	if _, err := hadoop.GetFileStatus(ctx, "/file"); err != nil {
+		t.Fatalf("GetFileStatus: %v", err)
+	}
+	req, _ := server.LastRequest("GETFILESTATUS")
+	if auth := req.Header.Get("Authorization"); auth != "Bearer access-1" {
+		t.Errorf("Authorization = %q, want Bearer access-1", auth)
+	}
+
+	// token被撤销后返回401，重新获取后重试
+	tokens.Revoke()
+	if err := hadoop.AppendFile(ctx, "/file", []byte("more")); err != nil {
+		t.Fatalf("AppendFile after revoke: %v", err)
+	}
+	if content, _ := server.Content("/file"); string(content) != "datamore" {
+		t.Errorf("content = %q, want datamore", content)
+	}
+
+	want := []string{grantClientCredentials, grantClientCredentials}
+	if grants := tokens.Grants(); !reflect.DeepEqual(grants, want) {
+		t.Errorf("grants = %v, want %v", grants, want)
+	}
+}
+
+func TestOAuth2RefreshToken(t *testing.T) {
+	ctx := context.Background()
+	tokens := webhdfstest.NewTokenServer("hadoop-fs", "")
+	tokens.RefreshToken = "refresh-0"
+	tokens.RotateRefreshToken = true
+	defer tokens.Close()
+
+	hadoop, server, oauth := newOAuth2TestController(t, tokens, "refresh-0")
+	server.AddDir("/dir")
+
+	// 使用服务端换过的refresh token
+	tokens.Revoke()
+	if _, _, err := hadoop.List(ctx, "/dir", ""); err != nil {
+		t.Fatalf("List after revoke: %v", err)
+	}
+
+	// 有效期过了80%时在发送请求前重新获取
+	oauth.lock.Lock()
+	oauth.refresh = time.Now().Add(-time.Second)
+	oauth.lock.Unlock()
+	if _, _, err := hadoop.List(ctx, "/dir", ""); err != nil {
+		t.Fatalf("List after expiry: %v", err)
+	}
+	if req, _ := server.LastRequest("LISTSTATUS_BATCH"); req.Header.Get("Authorization") != "Bearer access-3" {
+		t.Errorf("Authorization = %q, want Bearer access-3", req.Header.Get("Authorization"))
+	}
+
+	if grants := tokens.Grants(); len(grants) != 3 || grants[2] != grantRefreshToken {
+		t.Errorf("grants = %v, want 3 refresh_token grants", grants)
+	}
+}
+
+func TestOAuth2SecretFiles(t *testing.T) {
+	ctx := context.Background()
+	tokens := webhdfstest.NewTokenServer("hadoop-fs", "secret")
+	defer tokens.Close()
+
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "client_secret")
+	if err := ioutil.WriteFile(secretFile, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	hadoop, server := newTestController(t)
+	server.Authorize = tokens.Authorize
+	server.AddFile("/file", []byte("data"))
+
+	oauth := &OAuth2Authenticator{}
+	if err := oauth.Init(tokens.URL, "hadoop-fs", "", secretFile, "", "", ""); err != nil {
+		t.Fatalf("Init with client secret file: %v", err)
+	}
+	hadoop.SetAuthenticator(oauth)
+
+	// secret更换后返回401，重新读取文件
+	tokens.SetClientSecret("changed")
+	if err := ioutil.WriteFile(secretFile, []byte("changed"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tokens.Revoke()
+	if _, err := hadoop.GetFileStatus(ctx, "/file"); err != nil {
+		t.Fatalf("GetFileStatus after secret changed: %v", err)
+	}
+
+	tokens.RefreshToken = "refresh-0"
+	refreshFile := filepath.Join(dir, "refresh_token")
+	if err := ioutil.WriteFile(refreshFile, []byte("refresh-0\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	oauth = &OAuth2Authenticator{}
+	if err := oauth.Init(tokens.URL, "hadoop-fs", "", "", "", refreshFile, ""); err != nil {
+		t.Fatalf("Init with refresh token file: %v", err)
+	}
+	if grants := tokens.Grants(); grants[len(grants)-1] != grantRefreshToken {
+		t.Errorf("grants = %v, want refresh_token at last", grants)
+	}
+
+	if err := oauth.Init(tokens.URL, "hadoop-fs", "", filepath.Join(dir, "missing"), "", "", ""); err == nil {
+		t.Errorf("Init with missing client secret file: expected error")
+	}
+}
+
+func TestOAuth2ExpiresIn(t *testing.T) {
+	tokens := webhdfstest.NewTokenServer("hadoop-fs", "secret")
+	tokens.ExpiresIn = 3600
+	defer tokens.Close()
+
+	oauth := &OAuth2Authenticator{}
+	if err := oauth.Init(tokens.URL, "hadoop-fs", "secret", "", "", "", "read"); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	refresh := time.Until(oauth.refresh)
+	if refresh < 47*time.Minute || refresh > 48*time.Minute {
+		t.Errorf("refresh in %s, want 80%% of 1h", refresh)
+	}
+}
+
+func TestOAuth2Failed(t *testing.T) {
+	tokens := webhdfstest.NewTokenServer("hadoop-fs", "secret")
+	defer tokens.Close()
+
+	oauth := &OAuth2Authenticator{}
+	err := oauth.Init(tokens.URL, "hadoop-fs", "wrong", "", "", "", "")
+	if err == nil || !strings.Contains(err.Error(), "invalid_client") {
+		t.Fatalf("Init with wrong secret: got err %v, want invalid_client", err)
+	}
+
+	// 请求时获取token失败返回 ErrAuth
+	hadoop, server := newTestController(t)
+	server.Authorize = tokens.Authorize
+	hadoop.SetAuthenticator(oauth)
+	if _, err = hadoop.GetFileStatus(context.Background(), "/"); !errors.Is(err, herr.ErrAuth) {
+		t.Errorf("GetFileStatus: got err %v, want ErrAuth", err)
+	}
+}
@@ -0,0 +1,17 @@
+package controler
+
+import "context"
+
+type proxyUserKey struct{}
+
+// WithProxyUser 返回带有代理用户的ctx，使用这个ctx的请求会带上doas参数，
+// 由HDFS按这个用户检查权限和设置文件的owner
+func WithProxyUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, proxyUserKey{}, user)
+}
+
+// proxyUser ctx中的代理用户，没有时返回空字符串
+func proxyUser(ctx context.Context) string {
+	user, _ := ctx.Value(proxyUserKey{}).(string)
+	return user
+}
@@ -0,0 +1,46 @@
+package controler
+
+import (
+	"context"
+	"errors"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"testing"
+)
+
+func TestProxyUser(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.Impersonate = func(user, doas string) bool {
+		return user == testUser && doas != "mallory"
+	}
+
+	ctx := WithProxyUser(context.Background(), "alice")
+	if err := hadoop.Create(ctx, "/file", "644"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := hadoop.AppendFile(ctx, "/file", []byte("data")); err != nil {
+		t.Fatalf("AppendFile: %v", err)
+	}
+	if status, _ := server.Status("/file"); status.Owner != "alice" {
+		t.Errorf("owner = %q, want alice", status.Owner)
+	}
+	// DataNode的请求使用NameNode重定向时给出的地址，只检查NameNode的请求
+	for _, req := range server.Requests() {
+		if !req.DataNode && (req.Query.Get("doas") != "alice" || req.Query.Get("user.name") != testUser) {
+			t.Errorf("%s: doas=%q user.name=%q", req.Op, req.Query.Get("doas"), req.Query.Get("user.name"))
+		}
+	}
+
+	// 不允许代理的用户
+	denied := WithProxyUser(context.Background(), "mallory")
+	if _, err := hadoop.GetFileStatus(denied, "/file"); !errors.Is(err, herr.ErrAccess) {
+		t.Errorf("GetFileStatus as mallory: got err %v, want ErrAccess", err)
+	}
+
+	// 没有代理用户时不带doas
+	if _, err := hadoop.GetFileStatus(context.Background(), "/file"); err != nil {
+		t.Fatalf("GetFileStatus: %v", err)
+	}
+	if req, _ := server.LastRequest("GETFILESTATUS"); req.Query.Get("doas") != "" {
+		t.Errorf("GETFILESTATUS without proxy user: doas=%q", req.Query.Get("doas"))
+	}
+}
@@ -0,0 +1,95 @@
+package controler
+
+import (
+	"context"
+	"errors"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"hadoop-fs/fs/logger"
+	"net/http"
+	"sync/atomic"
+)
+
+// FsStatus 文件系统的容量(字节)，包括所有的副本
+type FsStatus struct {
+	Capacity  int64 `json:"capacity"`
+	Used      int64 `json:"used"`
+	Remaining int64 `json:"remaining"`
+}
+
+// QuotaUsage 目录的配额和使用量，Quota 是文件和目录数的配额，SpaceQuota 是空间配额(包括副本)，没有配额时为-1
+type QuotaUsage struct {
+	FileAndDirectoryCount int64 `json:"fileAndDirectoryCount"`
+	Quota                 int64 `json:"quota"`
+	SpaceConsumed         int64 `json:"spaceConsumed"`
+	SpaceQuota            int64 `json:"spaceQuota"`
+}
+
+// GetStatus 获取文件系统的容量(GETSTATUS，Hadoop 3.4之后才支持)，不支持时返回 herr.ErrNotsup，之后不再发送请求
+func (hadoop *HadoopController) GetStatus(ctx context.Context, path string) (status FsStatus, err error) {
+
+	if atomic.LoadInt32(&hadoop.noStatus) != 0 {
+		return FsStatus{}, herr.ErrNotsup
+	}
+
+	resp := FsStatusResp{}
+	err = hadoop.call(ctx, webhdfsRequest{
+		method: http.MethodGet,
+		op:     opGetStatus,
+		path:   "/",
+		errors: map[int]error{400: herr.ErrNotsup},
+	}, &resp)
+
+	if err != nil {
+		if errors.Is(err, herr.ErrNotsup) && atomic.CompareAndSwapInt32(&hadoop.noStatus, 0, 1) {
+			logger.Info.Println("quota: GETSTATUS is not supported, the capacity of the cluster is unknown")
+		}
+		return FsStatus{}, err
+	}
+
+	return resp.FsStatus, nil
+}
+
+// GetQuotaUsage 获取目录的配额和使用量，NameNode不支持GETQUOTAUSAGE时使用开销更大的GETCONTENTSUMMARY
+func (hadoop *HadoopController) GetQuotaUsage(ctx context.Context, path string) (usage QuotaUsage, err error) {
+
+	if atomic.LoadInt32(&hadoop.noQuotaUsage) == 0 {
+		resp := QuotaUsageResp{}
+		err = hadoop.call(ctx, webhdfsRequest{
+			method: http.MethodGet,
+			op:     opGetQuotaUsage,
+			path:   path,
+			errors: map[int]error{400: herr.ErrNotsup},
+		}, &resp)
+
+		if err == nil {
+			return resp.QuotaUsage, nil
+		}
+		if !errors.Is(err, herr.ErrNotsup) {
+			return QuotaUsage{}, err
+		}
+		if atomic.CompareAndSwapInt32(&hadoop.noQuotaUsage, 0, 1) {
+			logger.Info.Println("quota: GETQUOTAUSAGE is not supported, use GETCONTENTSUMMARY")
+		}
+	}
+
+	resp := ContentSummaryResp{}
+	err = hadoop.call(ctx, webhdfsRequest{
+		method: http.MethodGet,
+		op:     opGetContent,
+		path:   path,
+	}, &resp)
+
+	if err != nil {
+		return QuotaUsage{}, err
+	}
+
+	summary := resp.ContentSummary
+	usage = QuotaUsage{
+		FileAndDirectoryCount: summary.DirectoryCount + summary.FileCount,
+		Quota:                 summary.Quota,
+		SpaceConsumed:         summary.SpaceConsumed,
+		SpaceQuota:            summary.SpaceQuota,
+	}
+
+	return usage, nil
+}
@@ -0,0 +1,69 @@
+package controler
+
+import (
+	"context"
+	"errors"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"testing"
+)
+
+func TestGetStatus(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.Capacity = 1 << 30
+	server.AddFile("/data/a", make([]byte, 100))
+
+	status, err := hadoop.GetStatus(context.Background(), "/data")
+	if err != nil {
+		t.Fatalf("GetStatus: %v", err)
+	}
+	// 3个副本
+	if status.Capacity != 1<<30 || status.Used != 300 || status.Remaining != 1<<30-300 {
+		t.Errorf("GetStatus: got %+v", status)
+	}
+
+	// Hadoop 3.4之前没有GETSTATUS，只尝试一次
+	server.Disable(opGetStatus)
+	for i := 0; i < 2; i++ {
+		if _, err = hadoop.GetStatus(context.Background(), "/"); !errors.Is(err, herr.ErrNotsup) {
+			t.Errorf("GetStatus not supported: got %v, want ErrNotsup", err)
+		}
+	}
+	if count := countOps(server, opGetStatus); count != 2 {
+		t.Errorf("GETSTATUS requests: got %d, want 2", count)
+	}
+}
+
+func TestGetQuotaUsage(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/data/a", make([]byte, 100))
+	server.AddFile("/data/b/c", make([]byte, 10))
+	server.SetQuota("/data", 1000, 1<<20)
+
+	want := QuotaUsage{FileAndDirectoryCount: 4, Quota: 1000, SpaceConsumed: 330, SpaceQuota: 1 << 20}
+
+	usage, err := hadoop.GetQuotaUsage(context.Background(), "/data")
+	if err != nil || usage != want {
+		t.Errorf("GetQuotaUsage: got %+v, %v, want %+v", usage, err, want)
+	}
+
+	// Hadoop 3.1之前没有GETQUOTAUSAGE，只尝试一次
+	server.Disable(opGetQuotaUsage)
+	for i := 0; i < 2; i++ {
+		usage, err = hadoop.GetQuotaUsage(context.Background(), "/data")
+		if err != nil || usage != want {
+			t.Errorf("GetQuotaUsage by GETCONTENTSUMMARY: got %+v, %v, want %+v", usage, err, want)
+		}
+	}
+
+	count := map[string]int{}
+	for _, request := range server.Requests() {
+		count[request.Op]++
+	}
+	if count[opGetQuotaUsage] != 2 || count[opGetContent] != 2 {
+		t.Errorf("requests: got %v", count)
+	}
+
+	if _, err = hadoop.GetQuotaUsage(context.Background(), "/missing"); !errors.Is(err, herr.ErrNoFound) {
+		t.Errorf("GetQuotaUsage of missing directory: got %v, want ErrNoFound", err)
+	}
+}
@@ -0,0 +1,261 @@
+package controler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// webhdfsPrefix WebHDFS REST API 默认的路径前缀，通过网关访问时可以用 SetBasePath 修改
+const webhdfsPrefix = "/webhdfs/v1"
+
+// webhdfsRequest 一次WebHDFS请求，由 call 发送并解析响应
+type webhdfsRequest struct {
+	method string
+	op     string
+	path   string
+
+	// params 除了op、user.name和delegation以外的参数，值为空的参数不会发送
+	params map[string]string
+
+	// body 不为nil时作为请求体发送
+	body        []byte
+	contentType string
+
+	// status 成功时的状态码，为0时是200
+	status int
+
+	// errors 无法按 JavaClassName 分类时，状态码对应的错误，比如OPEN超出文件末尾时的403
+	errors map[int]error
+}
+
+// statusErrors 响应不是 RemoteException 时，状态码对应的错误
+var statusErrors = map[int]error{
+	http.StatusUnauthorized: herr.ErrAuth,
+	http.StatusForbidden:    herr.ErrAccess,
+	http.StatusNotFound:     herr.ErrNoFound,
+}
+
+// requestURL 生成请求的URL，路径和参数都会编码
+func (hadoop *HadoopController) requestURL(op, path string, params map[string]string) string {
+
+	query := url.Values{}
+	query.Set("op", op)
+
+	for name, value := range params {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+
+	if delegation := hadoop.getDelegation(); delegation != "" && !isDelegationOp(op) {
+		query.Set("delegation", delegation)
+	} else if hadoop.username != "" && hadoop.auth == nil {
+		query.Set("user.name", hadoop.username)
+	}
+
+	_, namenode := hadoop.activeNameNode()
+
+	u := url.URL{
+		Scheme: hadoop.httpPrefix,
+		Host:   namenode,
+		Path:   hadoop.basePath + path,
+		// 空格编码成%20而不是+，+本身会被编码成%2B
+		RawQuery: strings.ReplaceAll(query.Encode(), "+", "%20"),
+	}
+
+	return u.String()
+}
+
+// webhdfsResponse 读取完的响应，datanode 不为空时是DataNode返回的
+type webhdfsResponse struct {
+	status   int
+	body     []byte
+	datanode string
+}
+
+// call 发送请求，成功时把响应解析到out中：
+// out 为nil时忽略响应，为 *[]byte 时保存原始内容，否则按JSON解析
+func (hadoop *HadoopController) call(ctx context.Context, request webhdfsRequest, out interface{}) error {
+
+	ctx, cancel := hadoop.opContext(ctx, request.op)
+	defer cancel()
+
+	var resp webhdfsResponse
+	var err error
+	if coalescedOps[request.op] && request.method == http.MethodGet {
+		// 相同的元数据请求同时只发送一个
+		resp, err = hadoop.coalesce(ctx, request)
+	} else {
+		resp, err = hadoop.fetch(ctx, request)
+	}
+	if request.method != http.MethodGet {
+		// 修改请求失败时也可能已经生效了
+		paths := []string{request.path}
+		if request.op == opRename {
+			paths = append(paths, request.params["destination"])
+		}
+		hadoop.forget(paths...)
+	}
+	if err != nil {
+		return err
+	}
+
+	status := request.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if resp.status != status {
+		code := resp.status
+		if code == http.StatusInternalServerError && hadoop.isHttpFS() {
+			// HttpFS对IOException返回500，WebHDFS返回403
+			code = http.StatusForbidden
+		}
+		return request.dataNodeError(resp.datanode, request.responseError(code, resp.body))
+	}
+
+	switch out := out.(type) {
+	case nil:
+	case *[]byte:
+		*out = resp.body
+	default:
+		if err = json.Unmarshal(resp.body, out); err != nil {
+			return fmt.Errorf("%s %s: decode response failed: %v", request.op, request.path, err)
+		}
+	}
+
+	return nil
+}
+
+// fetch 发送请求并读取完整的响应，读写数据时会跟随NameNode返回的DataNode地址
+func (hadoop *HadoopController) fetch(ctx context.Context, request webhdfsRequest) (webhdfsResponse, error) {
+
+	// WebHDFS 读写数据分两步：先不带数据向NameNode获取DataNode的地址，再把请求发到DataNode
+	redirect := isDataOp(request.op) && !hadoop.isHttpFS()
+
+	params, body := request.params, request.body
+	doas := proxyUser(ctx)
+	if doas != "" && isDelegationOp(request.op) {
+		// delegation token属于挂载的用户
+		doas = ""
+	}
+	if redirect || doas != "" {
+		params = map[string]string{"doas": doas}
+		if redirect {
+			body = nil
+			// Knox等网关只改写307的Location，noredirect时返回的DataNode地址不会被改写
+			if hadoop.basePath == webhdfsPrefix {
+				params["noredirect"] = "true"
+			}
+		}
+		for name, value := range request.params {
+			params[name] = value
+		}
+	}
+
+	result := webhdfsResponse{}
+
+	req, err := hadoop.newRequest(ctx, request.method, hadoop.requestURL(request.op, request.path, params), body, request.contentType)
+	if err != nil {
+		return result, err
+	}
+
+	resp, err := hadoop.do(req)
+	if err != nil {
+		return result, err
+	}
+
+	if redirect {
+		location, err := hadoop.dataLocation(resp)
+		if err != nil {
+			resp.Body.Close()
+			return result, err
+		}
+		if location != nil {
+			resp.Body.Close()
+
+			hadoop.rewriteDataNode(location)
+			if !hadoop.isNameNode(location.Host) {
+				result.datanode = location.Host
+			}
+
+			if req, err = hadoop.newRequest(ctx, request.method, location.String(), request.body, request.contentType); err != nil {
+				return result, err
+			}
+			if resp, err = hadoop.retry(req, hadoop.sendData); err != nil {
+				return result, request.dataNodeError(result.datanode, err)
+			}
+		}
+	}
+	defer resp.Body.Close()
+
+	buf := bytes.NewBuffer(nil)
+	if _, err = buf.ReadFrom(resp.Body); err != nil {
+		return result, request.dataNodeError(result.datanode, err)
+	}
+
+	result.status = resp.StatusCode
+	result.body = buf.Bytes()
+
+	return result, nil
+}
+
+// newRequest 创建请求，body 不为nil时作为请求体发送，会带上 SetHeaders 设置的请求头
+func (hadoop *HadoopController) newRequest(ctx context.Context, method, target string, body []byte, contentType string) (*http.Request, error) {
+
+	var req *http.Request
+	var err error
+
+	if body != nil {
+		req, err = http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	} else {
+		req, err = http.NewRequestWithContext(ctx, method, target, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	hadoop.setHeaders(req)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	return req, nil
+}
+
+// dataNodeError 请求是发往DataNode的时候，把错误包装成 DataNodeError
+func (request *webhdfsRequest) dataNodeError(datanode string, err error) error {
+	if datanode == "" {
+		return err
+	}
+	return &DataNodeError{Op: request.op, Addr: datanode, Err: err}
+}
+
+// responseError 把失败的响应转换成错误：
+// 能按 JavaClassName 分类的 HadoopException 直接返回，否则使用 errors 中状态码对应的错误
+func (request *webhdfsRequest) responseError(status int, body []byte) error {
+
+	exception := HadoopException{}
+	if err := json.Unmarshal(body, &exception); err != nil || exception.RemoteException.Exception == "" {
+		if err, ok := request.errors[status]; ok {
+			return err
+		}
+		if err, ok := statusErrors[status]; ok {
+			return fmt.Errorf("%s %s: unexpected response status %d: %w", request.op, request.path, status, err)
+		}
+		return fmt.Errorf("%s %s: unexpected response status %d", request.op, request.path, status)
+	}
+
+	if exception.Kind() != nil {
+		return exception
+	}
+	if err, ok := request.errors[status]; ok {
+		return err
+	}
+
+	return exception
+}
@@ -0,0 +1,98 @@
+package controler
+
+import (
+	"context"
+	"net/url"
+	"testing"
+)
+
+// 需要编码的文件名
+var specialNames = []string{
+	"with space",
+	"100%",
+	"a#b",
+	"what?",
+	"a&b=c",
+	"a+b",
+	"中文 文件",
+}
+
+func TestSpecialPaths(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddDir("/dir")
+
+	for _, name := range specialNames {
+		path := "/dir/" + name
+
+		if err := hadoop.Create(context.Background(), path, "644"); err != nil {
+			t.Fatalf("Create %q: %v", path, err)
+		}
+		if err := hadoop.AppendFile(context.Background(), path, []byte(name)); err != nil {
+			t.Fatalf("AppendFile %q: %v", path, err)
+		}
+		if content, err := hadoop.Read(context.Background(), path, 0, 100, 0); err != nil || string(content) != name {
+			t.Errorf("Read %q: got %q, err %v", path, content, err)
+		}
+
+		moved := "/dir/moved " + name
+		if ok, err := hadoop.Rename(context.Background(), path, moved); err != nil || !ok {
+			t.Fatalf("Rename %q: got %v, err %v", path, ok, err)
+		}
+		if file, err := hadoop.GetFileStatus(context.Background(), moved); err != nil || file.StSize != int64(len(name)) {
+			t.Errorf("GetFileStatus %q: got %+v, err %v", moved, file, err)
+		}
+	}
+
+	files, _, err := hadoop.List(context.Background(), "/dir", "")
+	if err != nil || len(files) != len(specialNames) {
+		t.Fatalf("List: got %d files, err %v", len(files), err)
+	}
+
+	// startAfter 也需要编码
+	files, _, err = hadoop.List(context.Background(), "/dir", files[0].Name)
+	if err != nil || len(files) != len(specialNames)-1 {
+		t.Errorf("List startAfter: got %d files, err %v", len(files), err)
+	}
+}
+
+func TestSpecialXattrValue(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddFile("/file", nil)
+
+	value := "a=b&c=d e+f%"
+	if err := hadoop.Setxattr(context.Background(), "/file", "user.special", value, "CREATE"); err != nil {
+		t.Fatalf("Setxattr: %v", err)
+	}
+	if got := server.Xattrs("/file")["user.special"]; got != value {
+		t.Errorf("Setxattr: server got %q, want %q", got, value)
+	}
+	if got, err := hadoop.Getxattr(context.Background(), "/file", "user.special"); err != nil || got != value {
+		t.Errorf("Getxattr: got %q, err %v", got, err)
+	}
+}
+
+func TestRequestURL(t *testing.T) {
+	hadoop := &HadoopController{}
+	hadoop.Init(false, "::1", 50070, "hdfs user")
+
+	target := hadoop.requestURL(opRename, "/a b/c#d", map[string]string{"destination": "/e?f", "empty": ""})
+
+	u, err := url.Parse(target)
+	if err != nil {
+		t.Fatalf("requestURL %q: %v", target, err)
+	}
+	if u.Host != "[::1]:50070" {
+		t.Errorf("host = %q", u.Host)
+	}
+	if u.Path != "/webhdfs/v1/a b/c#d" {
+		t.Errorf("path = %q", u.Path)
+	}
+
+	query := u.Query()
+	if query.Get("op") != opRename || query.Get("destination") != "/e?f" || query.Get("user.name") != "hdfs user" {
+		t.Errorf("query = %v", query)
+	}
+	if _, ok := query["empty"]; ok {
+		t.Errorf("empty param should not be sent: %q", target)
+	}
+}
@@ -0,0 +1,205 @@
+package controler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"hadoop-fs/fs/logger"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// RetriableException 的javaClassName，NameNode处于safemode等情况时返回
+const retriableException = "org.apache.hadoop.ipc.RetriableException"
+
+// 重复执行结果不变的op，网络错误时可以重试，
+// 其它op(APPEND、RENAME、DELETE等)只在请求确定没有发出去时重试
+var idempotentOps = map[string]bool{
+	opGetFileStatus:        true,
+	opRead:                 true,
+	opListStatusBatch:      true,
+	opSetTimes:             true,
+	opSetPermission:        true,
+	opMkDir:                true,
+	opGetXattr:             true,
+	opGetHomeDir:           true,
+	opGetStatus:            true,
+	opGetQuotaUsage:        true,
+	opGetContent:           true,
+	opCheckAccess:          true,
+	opGetAclStatus:         true,
+	opRenewDelegationToken: true,
+}
+
+// RetryOptions 请求失败时的重试参数
+type RetryOptions struct {
+	// MaxRetries 最多重试的次数，0表示不重试
+	MaxRetries int
+	// BaseDelay 第一次重试前等待的时间，之后每次翻倍
+	BaseDelay time.Duration
+	// MaxDelay 两次重试之间最长的等待时间
+	MaxDelay time.Duration
+}
+
+// DefaultRetryOptions 默认的重试参数
+var DefaultRetryOptions = RetryOptions{
+	MaxRetries: 3,
+	BaseDelay:  200 * time.Millisecond,
+	MaxDelay:   5 * time.Second,
+}
+
+// SetRetryOptions 设置重试参数，BaseDelay 和 MaxDelay 为0时使用默认值
+func (hadoop *HadoopController) SetRetryOptions(opts RetryOptions) {
+	if opts.MaxRetries < 0 {
+		opts.MaxRetries = 0
+	}
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = DefaultRetryOptions.BaseDelay
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = DefaultRetryOptions.MaxDelay
+	}
+	hadoop.retryOptions = opts
+}
+
+// backoff 第retries次重试前等待的时间，指数增长并加上随机抖动，范围是[delay/2, delay)
+func (opts RetryOptions) backoff(retries int) time.Duration {
+
+	delay := opts.BaseDelay
+	for i := 0; i < retries && delay < opts.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > opts.MaxDelay {
+		delay = opts.MaxDelay
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// do 发送请求到NameNode，遇到暂时性的错误时按 RetryOptions 等待后重试
+func (hadoop *HadoopController) do(req *http.Request) (*http.Response, error) {
+	return hadoop.retry(req, hadoop.sendNameNode)
+}
+
+// retry 通过send发送请求，遇到暂时性的错误时按 RetryOptions 等待后重试
+func (hadoop *HadoopController) retry(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+
+	opts := hadoop.retryOptions
+	op := req.URL.Query().Get("op")
+
+	for retries := 0; ; retries++ {
+
+		attempt := req
+		if retries > 0 {
+			var err error
+			if attempt, err = cloneRequest(req); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := send(attempt)
+
+		reason := retryReason(attempt, resp, err)
+		if reason == "" {
+			return resp, err
+		}
+
+		if retries >= opts.MaxRetries {
+			if opts.MaxRetries > 0 {
+				logger.Error.Printf("retry: %s %s still failed after %d retries: %s\n", op, req.URL.Path, retries, reason)
+			}
+			return resp, err
+		}
+
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		delay := opts.backoff(retries)
+		logger.Warning.Printf("retry: %s %s [%d/%d] after %s: %s\n", op, req.URL.Path, retries+1, opts.MaxRetries, delay, reason)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryReason 判断请求是否可以重试，返回重试的原因，不能重试时返回空字符串
+func retryReason(req *http.Request, resp *http.Response, err error) string {
+
+	if err != nil {
+		if req.Context().Err() != nil || errors.Is(err, errNoRetryBody) {
+			return ""
+		}
+
+		// 连接失败时请求还没有发出去，任何op都可以重试
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return "connect failed: " + err.Error()
+		}
+
+		// 请求可能已经被执行，只有重复执行结果不变的op才重试
+		if idempotentOps[req.URL.Query().Get("op")] && isTransient(err) {
+			return "request failed: " + err.Error()
+		}
+		return ""
+	}
+
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		return "service unavailable"
+	}
+
+	if resp.StatusCode < 400 {
+		return ""
+	}
+
+	// 读出body判断是否是RetriableException，再放回去给调用者使用
+	buf, rerr := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(buf))
+	if rerr != nil {
+		return ""
+	}
+
+	exception := HadoopException{}
+	if json.Unmarshal(buf, &exception) != nil {
+		return ""
+	}
+	if exception.RemoteException.JavaClassName == retriableException ||
+		exception.RemoteException.Exception == "RetriableException" {
+		return "RetriableException: " + exception.RemoteException.Message
+	}
+
+	return ""
+}
+
+// isTransient 是否是连接断开、超时等暂时性的网络错误，证书错误等不会因为重试而成功
+func isTransient(err error) bool {
+
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	// 连接在读写时被断开，TLS握手被拒绝("remote error")等不重试
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && (opErr.Op == "read" || opErr.Op == "write") {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,136 @@
+package controler
+
+import (
+	"context"
+	"errors"
+	"hadoop-fs/fs/controler/webhdfstest"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newRetryTestController(t *testing.T, maxRetries int) (*HadoopController, *webhdfstest.Server) {
+	t.Helper()
+
+	hadoop, server := newTestController(t)
+	hadoop.SetRetryOptions(RetryOptions{MaxRetries: maxRetries, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond})
+
+	return hadoop, server
+}
+
+func countOps(server *webhdfstest.Server, op string) int {
+	count := 0
+	for _, req := range server.Requests() {
+		if req.Op == op && !req.DataNode {
+			count++
+		}
+	}
+	return count
+}
+
+func TestRetryRetriableException(t *testing.T) {
+	hadoop, server := newRetryTestController(t, 3)
+	server.AddFile("/file", []byte("data"))
+
+	for i := 0; i < 2; i++ {
+		server.Fail("GETFILESTATUS", http.StatusForbidden, "RetriableException",
+			retriableException, "NameNode still not started")
+	}
+
+	file, err := hadoop.GetFileStatus(context.Background(), "/file")
+	if err != nil || file.StSize != 4 {
+		t.Fatalf("GetFileStatus: got %+v, err %v", file, err)
+	}
+	if count := countOps(server, "GETFILESTATUS"); count != 3 {
+		t.Errorf("GETFILESTATUS sent %d times, want 3", count)
+	}
+
+	// 非幂等的op遇到 RetriableException 也可以重试，请求体要重新发送
+	server.Fail("APPEND", http.StatusServiceUnavailable, "RetriableException", retriableException, "safe mode")
+	if err = hadoop.AppendFile(context.Background(), "/file", []byte("more")); err != nil {
+		t.Fatalf("AppendFile: %v", err)
+	}
+	if content, _ := server.Content("/file"); string(content) != "datamore" {
+		t.Errorf("AppendFile: content = %q", content)
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	hadoop, server := newRetryTestController(t, 2)
+	server.AddFile("/file", nil)
+
+	for i := 0; i < 5; i++ {
+		server.Fail("GETFILESTATUS", http.StatusForbidden, "RetriableException", retriableException, "busy")
+	}
+
+	_, err := hadoop.GetFileStatus(context.Background(), "/file")
+	exception, ok := err.(HadoopException)
+	if !ok || exception.RemoteException.JavaClassName != retriableException {
+		t.Fatalf("GetFileStatus: got err %v, want RetriableException", err)
+	}
+	if count := countOps(server, "GETFILESTATUS"); count != 3 {
+		t.Errorf("GETFILESTATUS sent %d times, want 3", count)
+	}
+}
+
+func TestRetryReason(t *testing.T) {
+	reset := &url.Error{Op: "Get", URL: "http://nn/", Err: io.ErrUnexpectedEOF}
+	closed := &url.Error{Op: "Get", URL: "http://nn/", Err: &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}}
+	certificate := &url.Error{Op: "Get", URL: "https://nn/", Err: errors.New("x509: certificate signed by unknown authority")}
+	dial := &url.Error{Op: "Get", URL: "http://nn/", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}
+
+	cases := []struct {
+		op    string
+		err   error
+		retry bool
+	}{
+		{opGetFileStatus, reset, true},
+		{opRead, reset, true},
+		{opSetPermission, reset, true},
+		{opGetHomeDir, reset, true},
+		{opGetStatus, reset, true},
+		{opGetQuotaUsage, reset, true},
+		{opGetContent, reset, true},
+		{opCheckAccess, reset, true},
+		{opGetAclStatus, reset, true},
+		{opAppend, reset, false},
+		{opRename, reset, false},
+		{opDelete, reset, false},
+		{opRename, dial, true},
+		{opListStatusBatch, closed, true},
+		{opDelete, closed, false},
+		{opGetFileStatus, certificate, false},
+		{opGetFileStatus, errNoRetryBody, false},
+	}
+
+	for _, c := range cases {
+		req, _ := http.NewRequest("GET", "http://nn/webhdfs/v1/file?op="+c.op, nil)
+		if reason := retryReason(req, nil, c.err); (reason != "") != c.retry {
+			t.Errorf("retryReason(%s, %v) = %q, want retry %v", c.op, c.err, reason, c.retry)
+		}
+	}
+
+	// 取消的请求不重试
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://nn/webhdfs/v1/file?op="+opGetFileStatus, nil)
+	if reason := retryReason(req, nil, reset); reason != "" {
+		t.Errorf("retryReason for canceled request = %q", reason)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	opts := RetryOptions{MaxRetries: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for retries, max := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		max *= time.Millisecond
+		for i := 0; i < 20; i++ {
+			if delay := opts.backoff(retries); delay < max/2 || delay > max {
+				t.Errorf("backoff(%d) = %s, want in [%s, %s]", retries, delay, max/2, max)
+			}
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package controler
 
 import (
+	"context"
 	"hadoop-fs/fs/model"
 	"os/user"
 	"strconv"
@@ -16,13 +17,44 @@ func init() {
 	ROOT = RootController{}
 }
 
+// rootNodeID FUSE根目录的nodeid
+const rootNodeID = 1
+
 // RootController 根目录的控制类，主要与根目录部分操作相关联
 type RootController struct {
 	rootFile *model.FileModel
+
+	// backend 为空时根目录使用当前用户的0777目录
+	backend    Backend
+	remoteRoot string
+}
+
+// Init 设置挂载点对应的HDFS目录，根目录的属性从这个目录获取
+func (rc *RootController) Init(backend Backend, remoteRoot string) {
+	rc.backend = backend
+	rc.remoteRoot = remoteRoot
+}
+
+// GetRoot 获取根目录的文件信息，inode固定为FUSE根目录的nodeid
+func (rc *RootController) GetRoot(ctx context.Context, req fuse.Req) (model.FileModel, error) {
+
+	if rc.backend == nil {
+		return rc.defaultRoot(req), nil
+	}
+
+	file, err := rc.backend.GetFileStatus(ctx, rc.remoteRoot)
+	if err != nil {
+		return file, err
+	}
+
+	file.AdjustNormal()
+	file.StIno = rootNodeID
+
+	return file, nil
 }
 
-// GetRoot 获取根目录的文件信息
-func (rc *RootController) GetRoot(req fuse.Req) model.FileModel {
+// defaultRoot 没有设置HDFS目录时的根目录，属于当前用户的0777目录
+func (rc *RootController) defaultRoot(req fuse.Req) model.FileModel {
 	if rc.rootFile != nil {
 		return *rc.rootFile
 	}
@@ -0,0 +1,193 @@
+package controler
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"hadoop-fs/fs/logger"
+	"hadoop-fs/fs/model"
+	"hadoop-fs/fs/util"
+	"sync"
+)
+
+// SymlinkXattr 模拟的软连接的标记，值是链接的目标
+const SymlinkXattr = "user.hadoopfs.symlink"
+
+// MaxSymlinkLength 软连接目标的最大长度(PATH_MAX)，更大的文件不是模拟的软连接
+const MaxSymlinkLength = 4096
+
+// 缓存的最大数量，超过时淘汰最久没有使用的
+const maxSymlinkEntries = 4096
+
+// SymlinkEmulation 在不支持软连接的HDFS(HDFS-4559)上模拟软连接：软连接保存为带有 SymlinkXattr 的普通文件，
+// xattr和文件的内容都是链接的目标。
+//
+// GetFileStatus 和 List 对长度不超过 MaxSymlinkLength 的普通文件读取 SymlinkXattr，
+// 是软连接时设置 model.FileModel 的 Symlink。结果按文件的fileId、mtime和长度缓存，最多缓存 maxSymlinkEntries 个(LRU)
+type SymlinkEmulation struct {
+	Backend
+
+	lock    sync.Mutex
+	entries map[string]*list.Element
+	// lru 最近使用的在前面，元素是 *symlinkEntry
+	lru *list.List
+}
+
+type symlinkEntry struct {
+	path   string
+	fileID uint64
+	mtime  int64
+	size   int64
+	target string
+}
+
+// Init 初始化，backend 是实际的存储后端
+func (emulation *SymlinkEmulation) Init(backend Backend) {
+	emulation.Backend = backend
+	emulation.entries = make(map[string]*list.Element)
+	emulation.lru = list.New()
+}
+
+// CreateSymlink 创建空文件后设置 SymlinkXattr，再写入目标。
+// 先设置xattr再写入内容，缓存不会把还没有标记的文件当作普通文件保存
+func (emulation *SymlinkEmulation) CreateSymlink(ctx context.Context, src, link string) (err error) {
+
+	if src == "" || len(src) > MaxSymlinkLength {
+		return fmt.Errorf("symlink target length %d: %w", len(src), herr.ErrInvalid)
+	}
+
+	if err = emulation.Backend.Create(ctx, link, "644"); err != nil {
+		return err
+	}
+
+	err = emulation.Backend.Setxattr(ctx, link, SymlinkXattr, src, "CREATE")
+	if err == nil {
+		err = emulation.Backend.AppendFile(ctx, link, []byte(src))
+	}
+	if err != nil {
+		// 不留下没有目标的软连接
+		if _, delErr := emulation.Backend.Delete(ctx, link); delErr != nil {
+			logger.Warning.Printf("symlink: delete [%s] failed: %v\n", link, delErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetFileStatus 获取文件信息，模拟的软连接设置 Symlink
+func (emulation *SymlinkEmulation) GetFileStatus(ctx context.Context, filePath string) (file model.FileModel, err error) {
+
+	file, err = emulation.Backend.GetFileStatus(ctx, filePath)
+	if err != nil {
+		return file, err
+	}
+
+	emulation.resolve(ctx, filePath, &file)
+
+	return file, nil
+}
+
+// List 列出目录，模拟的软连接设置 Symlink
+func (emulation *SymlinkEmulation) List(ctx context.Context, path, startAfter string) (fileList []model.FileModel, remain int, err error) {
+
+	fileList, remain, err = emulation.Backend.List(ctx, path, startAfter)
+	if err != nil {
+		return fileList, remain, err
+	}
+
+	for i := range fileList {
+		emulation.resolve(ctx, util.MergePath(path, fileList[i].Name), &fileList[i])
+	}
+
+	return fileList, remain, nil
+}
+
+// Setxattr 设置xattr，修改 SymlinkXattr 时清除缓存
+func (emulation *SymlinkEmulation) Setxattr(ctx context.Context, filepath, name, value, flag string) (err error) {
+
+	if name == SymlinkXattr {
+		emulation.forget(filepath)
+	}
+
+	return emulation.Backend.Setxattr(ctx, filepath, name, value, flag)
+}
+
+// Removexattr 删除xattr，删除 SymlinkXattr 时清除缓存
+func (emulation *SymlinkEmulation) Removexattr(ctx context.Context, filepath, name string) (err error) {
+
+	if name == SymlinkXattr {
+		emulation.forget(filepath)
+	}
+
+	return emulation.Backend.Removexattr(ctx, filepath, name)
+}
+
+// resolve 判断文件是否是模拟的软连接，xattr的值与文件长度不一致时(被追加或者截断过)不是软连接。
+// 读取xattr失败时作为普通文件，不缓存
+func (emulation *SymlinkEmulation) resolve(ctx context.Context, filepath string, file *model.FileModel) {
+
+	if file.HadoopType != model.HadoopFile || file.StSize <= 0 || file.StSize > MaxSymlinkLength {
+		return
+	}
+
+	entry, ok := emulation.get(filepath)
+	if !ok || entry.fileID != file.StIno || entry.mtime != file.StMtime || entry.size != file.StSize {
+		target, err := emulation.Backend.Getxattr(ctx, filepath, SymlinkXattr)
+		if err != nil && !errors.Is(err, herr.ErrNoAttr) {
+			logger.Trace.Printf("symlink: get xattr of [%s] failed: %v\n", filepath, err)
+			return
+		}
+
+		entry = symlinkEntry{path: filepath, fileID: file.StIno, mtime: file.StMtime, size: file.StSize, target: target}
+		emulation.put(entry)
+	}
+
+	if int64(len(entry.target)) == file.StSize {
+		file.Symlink = entry.target
+	}
+}
+
+// get 获取缓存并标记为最近使用
+func (emulation *SymlinkEmulation) get(filepath string) (symlinkEntry, bool) {
+	emulation.lock.Lock()
+	defer emulation.lock.Unlock()
+
+	elem, ok := emulation.entries[filepath]
+	if !ok {
+		return symlinkEntry{}, false
+	}
+	emulation.lru.MoveToFront(elem)
+	return *elem.Value.(*symlinkEntry), true
+}
+
+// put 保存缓存，超过 maxSymlinkEntries 时淘汰最久没有使用的
+func (emulation *SymlinkEmulation) put(entry symlinkEntry) {
+	emulation.lock.Lock()
+	defer emulation.lock.Unlock()
+
+	if elem, ok := emulation.entries[entry.path]; ok {
+		*elem.Value.(*symlinkEntry) = entry
+		emulation.lru.MoveToFront(elem)
+		return
+	}
+
+	emulation.entries[entry.path] = emulation.lru.PushFront(&entry)
+	for emulation.lru.Len() > maxSymlinkEntries {
+		oldest := emulation.lru.Back()
+		emulation.lru.Remove(oldest)
+		delete(emulation.entries, oldest.Value.(*symlinkEntry).path)
+	}
+}
+
+func (emulation *SymlinkEmulation) forget(filepath string) {
+	emulation.lock.Lock()
+	defer emulation.lock.Unlock()
+
+	if elem, ok := emulation.entries[filepath]; ok {
+		emulation.lru.Remove(elem)
+		delete(emulation.entries, filepath)
+	}
+}
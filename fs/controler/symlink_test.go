@@ -0,0 +1,95 @@
+package controler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"hadoop-fs/fs/model"
+	"testing"
+)
+
+func TestSymlinkEmulation(t *testing.T) {
+	hadoop, server := newTestController(t)
+	server.AddDir("/dir")
+	// 与模拟的软连接内容相同的普通文件
+	server.AddFile("/dir/plain", []byte("../target"))
+
+	emulation := &SymlinkEmulation{}
+	emulation.Init(hadoop)
+	ctx := context.Background()
+
+	if err := emulation.CreateSymlink(ctx, "../target", "/dir/link"); err != nil {
+		t.Fatalf("CreateSymlink: %v", err)
+	}
+	if status, _ := server.Status("/dir/link"); status.Permission != "644" {
+		t.Errorf("permission of link after umask: got %s", status.Permission)
+	}
+
+	file, err := emulation.GetFileStatus(ctx, "/dir/link")
+	if err != nil || file.Symlink != "../target" {
+		t.Fatalf("GetFileStatus: got %+v, %v", file, err)
+	}
+	file.AdjustNormal()
+	if file.FileType != model.TypeSymlink || file.StMode != 0777 || file.StSize != int64(len("../target")) {
+		t.Errorf("AdjustNormal: type %o, mode %o, size %d", file.FileType, file.StMode, file.StSize)
+	}
+
+	// chmod 不影响标记
+	if err = emulation.SetPermission(ctx, "/dir/link", "600"); err != nil {
+		t.Fatal(err)
+	}
+	files, _, err := emulation.List(ctx, "/dir", "")
+	if err != nil || len(files) != 2 {
+		t.Fatalf("List: got %+v, %v", files, err)
+	}
+	for _, file := range files {
+		if want := map[string]string{"link": "../target", "plain": ""}[file.Name]; file.Symlink != want {
+			t.Errorf("List: %s symlink %q, want %q", file.Name, file.Symlink, want)
+		}
+	}
+
+	// 没有变化的文件使用缓存
+	before := countOps(server, opGetXattr)
+	if file, _ = emulation.GetFileStatus(ctx, "/dir/link"); file.Symlink != "../target" {
+		t.Errorf("cached GetFileStatus: got %q", file.Symlink)
+	}
+	if after := countOps(server, opGetXattr); after != before {
+		t.Errorf("GETXATTRS for unchanged link: %d requests", after-before)
+	}
+
+	// 追加内容后不再是软连接
+	if err = emulation.AppendFile(ctx, "/dir/link", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if file, _ = emulation.GetFileStatus(ctx, "/dir/link"); file.Symlink != "" {
+		t.Errorf("appended link: got symlink %q", file.Symlink)
+	}
+
+	if err = emulation.CreateSymlink(ctx, "", "/dir/empty"); !errors.Is(err, herr.ErrInvalid) {
+		t.Errorf("CreateSymlink of empty target: got %v, want ErrInvalid", err)
+	}
+}
+
+func TestSymlinkCacheEviction(t *testing.T) {
+	emulation := &SymlinkEmulation{}
+	emulation.Init(nil)
+
+	for i := 0; i < maxSymlinkEntries; i++ {
+		emulation.put(symlinkEntry{path: fmt.Sprintf("/link%d", i), target: "target"})
+	}
+	// 使用过的不会被淘汰
+	if _, ok := emulation.get("/link0"); !ok {
+		t.Fatal("/link0 not cached")
+	}
+	emulation.put(symlinkEntry{path: "/new", target: "target"})
+
+	if len(emulation.entries) != maxSymlinkEntries || emulation.lru.Len() != maxSymlinkEntries {
+		t.Errorf("cache size: %d entries, %d in lru", len(emulation.entries), emulation.lru.Len())
+	}
+	for path, want := range map[string]bool{"/link0": true, "/link1": false, "/link2": true, "/new": true} {
+		if _, ok := emulation.get(path); ok != want {
+			t.Errorf("%s cached: got %v, want %v", path, ok, want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package controler
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"hadoop-fs/fs/logger"
+	"io/ioutil"
+	"net/http"
+)
+
+// NewTLSConfig 创建https使用的TLS配置
+//
+// caFile 为空时使用系统的CA证书，certFile 和 keyFile 是双向认证时客户端的证书和私钥，
+// insecure 为true时不验证服务端的证书，只应该在测试环境使用
+func NewTLSConfig(caFile, certFile, keyFile string, insecure bool) (*tls.Config, error) {
+
+	tlsConfig := &tls.Config{}
+
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA file [%s] failed: %v", caFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in CA file [%s]", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate [%s] and key [%s] failed: %v", certFile, keyFile, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if insecure {
+		logger.Warning.Println("TLS certificate verification is disabled, connections are vulnerable to MITM attacks!")
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	return tlsConfig, nil
+}
+
+// SetTLSConfig 设置https使用的TLS配置，NameNode和重定向后的DataNode都使用这个配置，
+// serverName 不为空时用于验证NameNode的证书，比如通过IP或者负载均衡访问NameNode时
+func (hadoop *HadoopController) SetTLSConfig(tlsConfig *tls.Config, serverName string) {
+	hadoop.tlsConfig = tlsConfig
+	hadoop.tlsServerName = serverName
+	hadoop.buildClient()
+}
+
+// namenodeTransport 发往NameNode和DataNode的请求使用不同的Transport
+type namenodeTransport struct {
+	hadoop   *HadoopController
+	namenode http.RoundTripper
+	datanode http.RoundTripper
+}
+
+func (t *namenodeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.hadoop.isNameNode(req.URL.Host) {
+		return t.namenode.RoundTrip(req)
+	}
+	return t.datanode.RoundTrip(req)
+}
+
+// isNameNode addr(host:port) 是否是NameNode
+func (hadoop *HadoopController) isNameNode(addr string) bool {
+
+	hadoop.namenodeLock.RLock()
+	defer hadoop.namenodeLock.RUnlock()
+
+	for _, namenode := range hadoop.namenodes {
+		if namenode == addr {
+			return true
+		}
+	}
+	return false
+}
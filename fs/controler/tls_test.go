@@ -0,0 +1,181 @@
+package controler
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"hadoop-fs/fs/controler/webhdfstest"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTLSTestController(t *testing.T, serverConfig *tls.Config) (*HadoopController, *webhdfstest.Server, string) {
+	t.Helper()
+
+	server := webhdfstest.NewTLSServer(serverConfig)
+	t.Cleanup(server.Close)
+	server.AddFile("/file", []byte("secure"))
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, ca, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	hadoop := &HadoopController{}
+	hadoop.Init(true, server.Host(), server.Port(), testUser)
+
+	return hadoop, server, caFile
+}
+
+// writeClientCert 生成一个自签名的客户端证书
+func writeClientCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: testUser},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "client.pem")
+	keyFile = filepath.Join(dir, "client.key")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+
+	return certFile, keyFile
+}
+
+func TestTLSWithCA(t *testing.T) {
+	hadoop, server, caFile := newTLSTestController(t, nil)
+
+	tlsConfig, err := NewTLSConfig(caFile, "", "", false)
+	if err != nil {
+		t.Fatalf("NewTLSConfig: %v", err)
+	}
+	hadoop.SetTLSConfig(tlsConfig, "")
+
+	// OPEN 和 APPEND 会重定向到使用https的DataNode
+	content, err := hadoop.Read(context.Background(), "/file", 0, 100, 0)
+	if err != nil || string(content) != "secure" {
+		t.Fatalf("Read: got %q, err %v", content, err)
+	}
+	if err = hadoop.AppendFile(context.Background(), "/file", []byte("!")); err != nil {
+		t.Fatalf("AppendFile: %v", err)
+	}
+	if content, _ := server.Content("/file"); string(content) != "secure!" {
+		t.Errorf("AppendFile: content = %q", content)
+	}
+}
+
+func TestTLSUnknownAuthority(t *testing.T) {
+	hadoop, _, _ := newTLSTestController(t, nil)
+
+	tlsConfig, err := NewTLSConfig("", "", "", false)
+	if err != nil {
+		t.Fatalf("NewTLSConfig: %v", err)
+	}
+	hadoop.SetTLSConfig(tlsConfig, "")
+
+	if _, err = hadoop.GetFileStatus(context.Background(), "/file"); err == nil {
+		t.Errorf("GetFileStatus: expected certificate error")
+	}
+}
+
+func TestTLSInsecure(t *testing.T) {
+	hadoop, _, _ := newTLSTestController(t, nil)
+
+	tlsConfig, err := NewTLSConfig("", "", "", true)
+	if err != nil {
+		t.Fatalf("NewTLSConfig: %v", err)
+	}
+	hadoop.SetTLSConfig(tlsConfig, "")
+
+	if _, err = hadoop.GetFileStatus(context.Background(), "/file"); err != nil {
+		t.Errorf("GetFileStatus: %v", err)
+	}
+}
+
+func TestTLSServerName(t *testing.T) {
+	hadoop, _, caFile := newTLSTestController(t, nil)
+
+	tlsConfig, err := NewTLSConfig(caFile, "", "", false)
+	if err != nil {
+		t.Fatalf("NewTLSConfig: %v", err)
+	}
+
+	// httptest 的证书包含 example.com，DataNode 仍然使用自己的地址验证
+	hadoop.SetTLSConfig(tlsConfig, "example.com")
+	if _, err = hadoop.Read(context.Background(), "/file", 0, 100, 0); err != nil {
+		t.Errorf("Read with server name example.com: %v", err)
+	}
+
+	hadoop.SetTLSConfig(tlsConfig, "namenode.invalid")
+	if _, err = hadoop.GetFileStatus(context.Background(), "/file"); err == nil {
+		t.Errorf("GetFileStatus with wrong server name: expected certificate error")
+	}
+}
+
+func TestTLSClientCertificate(t *testing.T) {
+	hadoop, _, caFile := newTLSTestController(t, &tls.Config{ClientAuth: tls.RequireAnyClientCert})
+
+	tlsConfig, err := NewTLSConfig(caFile, "", "", false)
+	if err != nil {
+		t.Fatalf("NewTLSConfig: %v", err)
+	}
+	hadoop.SetTLSConfig(tlsConfig, "")
+	if _, err = hadoop.GetFileStatus(context.Background(), "/file"); err == nil {
+		t.Errorf("GetFileStatus without client certificate: expected error")
+	}
+
+	certFile, keyFile := writeClientCert(t)
+	tlsConfig, err = NewTLSConfig(caFile, certFile, keyFile, false)
+	if err != nil {
+		t.Fatalf("NewTLSConfig: %v", err)
+	}
+	hadoop.SetTLSConfig(tlsConfig, "")
+	if _, err = hadoop.Read(context.Background(), "/file", 0, 100, 0); err != nil {
+		t.Errorf("Read with client certificate: %v", err)
+	}
+}
+
+func TestNewTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := NewTLSConfig(filepath.Join(dir, "missing.pem"), "", "", false); err == nil {
+		t.Errorf("missing CA file: expected error")
+	}
+
+	empty := filepath.Join(dir, "empty.pem")
+	ioutil.WriteFile(empty, []byte("not a certificate"), 0644)
+	if _, err := NewTLSConfig(empty, "", "", false); err == nil {
+		t.Errorf("invalid CA file: expected error")
+	}
+
+	if _, err := NewTLSConfig("", empty, "", false); err == nil {
+		t.Errorf("invalid client certificate: expected error")
+	}
+}
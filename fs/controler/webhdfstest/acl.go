@@ -0,0 +1,284 @@
+package webhdfstest
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// aclEntry ACL中的一项，scope 为空或者 default
+type aclEntry struct {
+	scope string
+	kind  string
+	name  string
+	perm  string
+}
+
+func (e aclEntry) String() string {
+	s := e.kind + ":" + e.name
+	if e.perm != "" {
+		s += ":" + e.perm
+	}
+	if e.scope != "" {
+		s = e.scope + ":" + s
+	}
+	return s
+}
+
+// key 同一个key的ACL项只能有一个
+func (e aclEntry) key() string {
+	return e.scope + ":" + e.kind + ":" + e.name
+}
+
+// parseAclSpec 解析aclspec，withPerm 为false时是 REMOVEACLENTRIES 的格式，没有权限
+func parseAclSpec(spec string, withPerm bool) ([]aclEntry, *remoteError) {
+
+	entries := make([]aclEntry, 0)
+	for _, item := range strings.Split(spec, ",") {
+		if item == "" {
+			continue
+		}
+		fields := strings.Split(item, ":")
+		e := aclEntry{}
+		if fields[0] == "default" {
+			e.scope = "default"
+			fields = fields[1:]
+		}
+		if withPerm && len(fields) == 3 {
+			e.perm = fields[2]
+			fields = fields[:2]
+		}
+		valid := len(fields) == 2 && (!withPerm || len(e.perm) == 3 && strings.Trim(e.perm, "rwx-") == "")
+		switch {
+		case !valid:
+		case fields[0] == "user" || fields[0] == "group":
+		case (fields[0] == "mask" || fields[0] == "other") && fields[1] == "":
+		default:
+			valid = false
+		}
+		if !valid {
+			return nil, newRemoteError(http.StatusBadRequest, "IllegalArgumentException", "java.lang.IllegalArgumentException",
+				fmt.Sprintf("Invalid value for webhdfs parameter \"aclspec\": %s", spec))
+		}
+		e.kind, e.name = fields[0], fields[1]
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// permBits rwx 字符串转换成权限位
+func permBits(perm string) uint64 {
+	bits := uint64(0)
+	for i, bit := range []uint64{4, 2, 1} {
+		if perm[i] != '-' {
+			bits |= bit
+		}
+	}
+	return bits
+}
+
+func permString(bits uint64) string {
+	buf := []byte("---")
+	for i, c := range "rwx" {
+		if bits&(4>>uint(i)) != 0 {
+			buf[i] = byte(c)
+		}
+	}
+	return string(buf)
+}
+
+// logicalAcl 完整的ACL，access部分的owner、mask(没有扩展ACL时是group)和other来自权限位
+func (n *node) logicalAcl() []aclEntry {
+
+	mode, _ := strconv.ParseUint(n.status.Permission, 8, 16)
+
+	group := "group"
+	for _, e := range n.acl {
+		if e.scope == "" {
+			group = "mask"
+		}
+	}
+
+	acl := []aclEntry{
+		{kind: "user", perm: permString(mode >> 6 & 7)},
+		{kind: group, perm: permString(mode >> 3 & 7)},
+		{kind: "other", perm: permString(mode & 7)},
+	}
+	return append(acl, n.acl...)
+}
+
+// setLogicalAcl 保存完整的ACL，spec 中没有mask时重新计算mask，与HDFS的 AclTransformation 一样
+func (n *node) setLogicalAcl(acl []aclEntry, spec []aclEntry) *remoteError {
+
+	mode, _ := strconv.ParseUint(n.status.Permission, 8, 16)
+	mode &^= 0777
+
+	stored := make([]aclEntry, 0)
+	for _, scope := range []string{"", "default"} {
+
+		entries := map[string]aclEntry{}
+		named := false
+		for _, e := range acl {
+			if e.scope == scope {
+				entries[e.key()] = e
+				named = named || e.name != ""
+			}
+		}
+		if len(entries) == 0 {
+			continue
+		}
+
+		user, hasUser := entries[scope+":user:"]
+		group, hasGroup := entries[scope+":group:"]
+		other, hasOther := entries[scope+":other:"]
+		if !hasUser || !hasGroup || !hasOther {
+			return newRemoteError(http.StatusForbidden, "AclException", "org.apache.hadoop.hdfs.protocol.AclException",
+				"Invalid ACL: the user, group and other entries are required.")
+		}
+
+		mask, hasMask := entries[scope+":mask:"]
+		specMask := false
+		for _, e := range spec {
+			specMask = specMask || e.scope == scope && e.kind == "mask"
+		}
+		if named && !specMask {
+			bits := uint64(0)
+			for _, e := range entries {
+				if e.name != "" || e.kind == "group" {
+					bits |= permBits(e.perm)
+				}
+			}
+			mask, hasMask = aclEntry{scope: scope, kind: "mask", perm: permString(bits)}, true
+		}
+		if !named && scope == "" {
+			hasMask = false
+		}
+
+		if scope == "" {
+			mode |= permBits(user.perm)<<6 | permBits(other.perm)
+			if hasMask {
+				mode |= permBits(mask.perm) << 3
+			} else {
+				mode |= permBits(group.perm) << 3
+			}
+		}
+
+		for key, e := range entries {
+			if e.kind == "mask" || scope == "" && (key == ":user:" || key == ":other:" || !named && key == ":group:") {
+				continue
+			}
+			stored = append(stored, e)
+		}
+		if scope == "default" && hasMask {
+			stored = append(stored, mask)
+		}
+	}
+
+	order := map[string]int{"user": 0, "group": 1, "mask": 2, "other": 3}
+	sort.Slice(stored, func(i, j int) bool {
+		a, b := stored[i], stored[j]
+		if a.scope != b.scope {
+			return a.scope == ""
+		}
+		if a.kind != b.kind {
+			return order[a.kind] < order[b.kind]
+		}
+		return a.name < b.name
+	})
+
+	n.status.Permission = strconv.FormatUint(mode, 8)
+	n.acl = stored
+	return nil
+}
+
+func (s *Server) getAclStatus(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+	n, ok := s.nodes[path]
+	if !ok {
+		return fileNotFound(path)
+	}
+
+	entries := make([]string, len(n.acl))
+	for i, e := range n.acl {
+		entries[i] = e.String()
+	}
+	mode, _ := strconv.ParseUint(n.status.Permission, 8, 16)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"AclStatus": map[string]interface{}{
+		"entries":    entries,
+		"owner":      n.status.Owner,
+		"group":      n.status.Group,
+		"permission": n.status.Permission,
+		"stickyBit":  mode&01000 != 0,
+	}})
+	return nil
+}
+
+// modifyAcl SETACL、MODIFYACLENTRIES、REMOVEACLENTRIES、REMOVEDEFAULTACL、REMOVEACL
+func (s *Server) modifyAcl(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+	n, ok := s.nodes[path]
+	if !ok {
+		return fileNotFound(path)
+	}
+
+	op := strings.ToUpper(query.Get("op"))
+	spec, err := parseAclSpec(query.Get("aclspec"), op != "REMOVEACLENTRIES")
+	if err != nil {
+		return err
+	}
+
+	replaced := map[string]bool{}
+	removed := map[string]bool{}
+	for _, e := range spec {
+		replaced[e.scope] = true
+		removed[e.key()] = true
+	}
+
+	acl := make([]aclEntry, 0)
+	switch op {
+	case "SETACL":
+		// 每一类(access、default)分别替换，spec 中没有的那一类保持不变
+		acl = append(acl, spec...)
+		for _, e := range n.logicalAcl() {
+			if !replaced[e.scope] {
+				acl = append(acl, e)
+			}
+		}
+	case "MODIFYACLENTRIES":
+		for _, e := range n.logicalAcl() {
+			if !removed[e.key()] {
+				acl = append(acl, e)
+			}
+		}
+		acl = append(acl, spec...)
+	case "REMOVEACLENTRIES":
+		for _, e := range n.logicalAcl() {
+			if !removed[e.key()] {
+				acl = append(acl, e)
+			}
+		}
+	case "REMOVEDEFAULTACL":
+		for _, e := range n.logicalAcl() {
+			if e.scope == "" {
+				acl = append(acl, e)
+			}
+		}
+	case "REMOVEACL":
+		// 权限位的group恢复为group项的权限
+		for _, e := range n.logicalAcl() {
+			if e.scope == "" && e.name == "" && e.kind != "mask" {
+				acl = append(acl, e)
+			}
+		}
+	}
+
+	if err = n.setLogicalAcl(acl, spec); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
@@ -0,0 +1,121 @@
+package webhdfstest
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Knox 假的Apache Knox网关：/gateway/<topology>/webhdfs/v1 转发到NameNode，使用HTTP Basic认证，
+// NameNode重定向到DataNode的Location会被改写成经过网关的 /gateway/<topology>/webhdfs/data/v1
+type Knox struct {
+	*httptest.Server
+
+	Username string
+	Password string
+
+	topology string
+	server   *Server
+
+	lock     sync.Mutex
+	requests int
+}
+
+// NewKnox 创建并启动一个转发到server的假Knox网关
+func NewKnox(server *Server, topology, username, password string) *Knox {
+	k := &Knox{Username: username, Password: password, topology: topology, server: server}
+	k.Server = httptest.NewServer(http.HandlerFunc(k.serve))
+	return k
+}
+
+// BasePath 网关上WebHDFS的路径前缀
+func (k *Knox) BasePath() string {
+	return "/gateway/" + k.topology + "/webhdfs/v1"
+}
+
+// dataPath 网关上DataNode的路径前缀
+func (k *Knox) dataPath() string {
+	return "/gateway/" + k.topology + "/webhdfs/data/v1"
+}
+
+// SetPassword 修改Basic认证的密码
+func (k *Knox) SetPassword(password string) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	k.Password = password
+}
+
+// Requests 网关收到的请求数量
+func (k *Knox) Requests() int {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	return k.requests
+}
+
+func (k *Knox) serve(w http.ResponseWriter, r *http.Request) {
+
+	k.lock.Lock()
+	k.requests++
+	username, password := k.Username, k.Password
+	k.lock.Unlock()
+
+	if user, pass, ok := r.BasicAuth(); !ok || user != username || pass != password {
+		w.Header().Set("WWW-Authenticate", `Basic realm="application"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var target *url.URL
+	switch {
+	case strings.HasPrefix(r.URL.Path, k.BasePath()):
+		target, _ = url.Parse(k.server.NameNode.URL)
+		target.Path = PathPrefix + strings.TrimPrefix(r.URL.Path, k.BasePath())
+		query := r.URL.Query()
+		// Knox 以自己认证过的用户访问WebHDFS
+		query.Set("user.name", username)
+		target.RawQuery = query.Encode()
+
+	case strings.HasPrefix(r.URL.Path, k.dataPath()):
+		// 原来的DataNode地址保存在 _ 参数中
+		original, err := base64.RawURLEncoding.DecodeString(r.URL.Query().Get("_"))
+		if err != nil {
+			http.Error(w, "invalid data url", http.StatusBadRequest)
+			return
+		}
+		target, _ = url.Parse(string(original))
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	out, _ := http.NewRequest(r.Method, target.String(), r.Body)
+	out.ContentLength = r.ContentLength
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		out.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := http.DefaultTransport.RoundTrip(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	if location := resp.Header.Get("Location"); location != "" && resp.StatusCode == http.StatusTemporaryRedirect {
+		if u, err := url.Parse(location); err == nil {
+			w.Header().Set("Location", k.URL+k.dataPath()+strings.TrimPrefix(u.Path, PathPrefix)+
+				"?_="+base64.RawURLEncoding.EncodeToString([]byte(location)))
+		}
+	}
+
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
@@ -0,0 +1,1429 @@
+// Package webhdfstest 提供一个进程内的假WebHDFS服务，用于测试与WebHDFS交互的代码
+//
+// NameNode 和 DataNode 分别是两个 httptest.Server，OPEN、CREATE、APPEND 与真实的集群一样
+// 先由 NameNode 返回307重定向到 DataNode(带noredirect=true时返回JSON格式的地址)，错误以 RemoteException 的JSON格式返回
+package webhdfstest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PathPrefix WebHDFS REST API 的路径前缀
+const PathPrefix = "/webhdfs/v1"
+
+// 与HDFS的默认值保持一致
+const (
+	rootFileID      = 16385
+	blockSize       = 134217728
+	defaultGroup    = "supergroup"
+	defaultDirPerm  = "755"
+	defaultFilePerm = "644"
+	defaultLimit    = 1000
+)
+
+// 文件类型
+const (
+	typeDir  = "DIRECTORY"
+	typeFile = "FILE"
+)
+
+// FileStatus WebHDFS 返回的文件信息
+type FileStatus struct {
+	AccessTime       int64  `json:"accessTime"`
+	BlockSize        int64  `json:"blockSize"`
+	ChildrenNum      int    `json:"childrenNum"`
+	FileID           uint64 `json:"fileId"`
+	Group            string `json:"group"`
+	Length           int64  `json:"length"`
+	ModificationTime int64  `json:"modificationTime"`
+	Owner            string `json:"owner"`
+	PathSuffix       string `json:"pathSuffix"`
+	Permission       string `json:"permission"`
+	Replication      int    `json:"replication"`
+	StoragePolicy    int    `json:"storagePolicy"`
+	Type             string `json:"type"`
+	AclBit           bool   `json:"aclBit,omitempty"`
+}
+
+// Request 服务收到的请求记录
+type Request struct {
+	Method string
+	Path   string
+	Op     string
+	Query  url.Values
+	Header http.Header
+	// DataNode 为true表示是DataNode收到的请求
+	DataNode bool
+	// NameNode 收到请求的NameNode的序号
+	NameNode int
+}
+
+// RemoteException WebHDFS 的异常
+type RemoteException struct {
+	Exception     string `json:"exception"`
+	JavaClassName string `json:"javaClassName"`
+	Message       string `json:"message"`
+}
+
+type failure struct {
+	status    int
+	exception RemoteException
+}
+
+type node struct {
+	status  FileStatus
+	content []byte
+	xattrs  map[string][]byte
+	// acl 扩展的ACL项和default ACL，与GETACLSTATUS返回的entries一样
+	acl []aclEntry
+
+	// 目录的配额，没有配额时为-1，只用于 GETQUOTAUSAGE 和 GETCONTENTSUMMARY，写入时不检查
+	nsQuota    int64
+	spaceQuota int64
+}
+
+// Server 假的WebHDFS服务
+type Server struct {
+	// NameNode 是第一个NameNode，HA时通过 AddNameNode 添加更多的NameNode
+	NameNode  *httptest.Server
+	NameNodes []*httptest.Server
+	DataNode  *httptest.Server
+
+	// ListLimit LISTSTATUS_BATCH 每次最多返回的数量
+	ListLimit int
+
+	// Owner 新建文件的owner，为空时使用请求中的user.name
+	Owner string
+
+	// Authorize 不为空时，NameNode 对返回false的请求返回401，与开启了SPNEGO的集群一样
+	Authorize func(r *http.Request) bool
+
+	// CSRFHeader 不为空时，NameNode和DataNode拒绝没有这个请求头的PUT、POST、DELETE请求，
+	// 与开启了 dfs.webhdfs.rest-csrf.enabled 的集群一样
+	CSRFHeader string
+
+	// Impersonate 不为空时，对返回false的doas请求返回403 AuthorizationException，
+	// 与没有配置hadoop.proxyuser的集群一样
+	Impersonate func(user, doas string) bool
+
+	// HttpFS 为true时模拟HttpFS网关：OPEN直接返回数据，CREATE和APPEND需要带上data=true，
+	// 否则重定向到带data=true的自己，IOException返回500，文件信息中没有fileId
+	HttpFS bool
+
+	// DataNodeAddr 不为空时，重定向的地址中使用这个host:port代替DataNode真实的地址，
+	// 模拟DataNode使用客户端无法解析的内部地址
+	DataNodeAddr string
+
+	// IgnoreNoRedirect 为true时模拟Hadoop 2，忽略noredirect参数，总是返回307重定向
+	IgnoreNoRedirect bool
+
+	// Superuser HDFS的超级用户，只有它可以修改文件的owner，默认是 hdfs
+	Superuser string
+
+	// Umask 与NameNode的 fs.permissions.umask-mode 一样，CREATE和MKDIRS的权限去掉这些位，默认是022
+	Umask uint32
+
+	// Capacity GETSTATUS 返回的集群容量
+	Capacity int64
+
+	// TokenRenewInterval 和 TokenMaxLifetime 是delegation token的续期时间和最长有效期
+	TokenRenewInterval time.Duration
+	TokenMaxLifetime   time.Duration
+
+	lock      sync.Mutex
+	nextID    uint64
+	nodes     map[string]*node
+	failures  map[string][]failure
+	dnFails   map[string][]failure
+	hangs     map[string]chan struct{}
+	disabled  map[string]bool
+	requests  []Request
+	tokens    map[string]*token
+	nextToken int
+	active    int
+
+	// tlsConfig 不为空时NameNode和DataNode都使用https
+	tlsConfig *tls.Config
+}
+
+type token struct {
+	owner      string
+	renewer    string
+	expiration time.Time
+	maxDate    time.Time
+}
+
+// NewServer 创建并启动一个假的WebHDFS服务
+func NewServer() *Server {
+	return newServer(nil)
+}
+
+// NewTLSServer 创建并启动一个使用https的假WebHDFS服务(swebhdfs)，
+// tlsConfig 可以为空，证书由 httptest 生成，可以通过 Certificate 获取
+func NewTLSServer(tlsConfig *tls.Config) *Server {
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	return newServer(tlsConfig)
+}
+
+func newServer(tlsConfig *tls.Config) *Server {
+
+	s := &Server{
+		ListLimit:          defaultLimit,
+		TokenRenewInterval: 24 * time.Hour,
+		TokenMaxLifetime:   7 * 24 * time.Hour,
+		nextID:             rootFileID,
+		nodes:              make(map[string]*node),
+		failures:           make(map[string][]failure),
+		dnFails:            make(map[string][]failure),
+		hangs:              make(map[string]chan struct{}),
+		disabled:           make(map[string]bool),
+		Capacity:           1 << 40,
+		Superuser:          "hdfs",
+		Umask:              022,
+		tokens:             make(map[string]*token),
+		tlsConfig:          tlsConfig,
+	}
+	s.nodes["/"] = s.newNode("", typeDir, defaultDirPerm, "")
+
+	s.NameNode = s.newNameNode()
+	s.DataNode = s.start(http.HandlerFunc(s.serveDataNode))
+
+	return s
+}
+
+func (s *Server) start(handler http.Handler) *httptest.Server {
+	if s.tlsConfig == nil {
+		return httptest.NewServer(handler)
+	}
+	server := httptest.NewUnstartedServer(handler)
+	server.TLS = s.tlsConfig.Clone()
+	server.StartTLS()
+	return server
+}
+
+func (s *Server) newNameNode() *httptest.Server {
+	index := len(s.NameNodes)
+	nn := s.start(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.serveNameNode(w, r, index)
+	}))
+	s.NameNodes = append(s.NameNodes, nn)
+	return nn
+}
+
+// Certificate 使用https时服务端的证书，NameNode和DataNode使用同一个证书
+func (s *Server) Certificate() *x509.Certificate {
+	return s.NameNode.Certificate()
+}
+
+// AddNameNode 添加一个共享同一个namespace的NameNode，返回它的地址(host:port)，
+// 新的NameNode是standby状态
+func (s *Server) AddNameNode() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.newNameNode().Listener.Addr().String()
+}
+
+// NameNodeAddr 第index个NameNode的地址(host:port)
+func (s *Server) NameNodeAddr(index int) string {
+	return s.NameNodes[index].Listener.Addr().String()
+}
+
+// SetActive 切换active的NameNode，其它的NameNode都返回StandbyException
+func (s *Server) SetActive(index int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.active = index
+}
+
+// Close 关闭服务
+func (s *Server) Close() {
+	for _, nn := range s.NameNodes {
+		nn.Close()
+	}
+	s.DataNode.Close()
+}
+
+// Host NameNode 的地址
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.NameNode.Listener.Addr().String())
+	return host
+}
+
+// Port NameNode 的端口
+func (s *Server) Port() int {
+	_, port, _ := net.SplitHostPort(s.NameNode.Listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return p
+}
+
+// AddDir 添加目录，会创建不存在的父目录
+func (s *Server) AddDir(path string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.mkdirs(cleanPath(path), defaultDirPerm, s.Owner)
+}
+
+// AddFile 添加文件，会创建不存在的父目录
+func (s *Server) AddFile(path string, content []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	path = cleanPath(path)
+	s.mkdirs(parentPath(path), defaultDirPerm, s.Owner)
+
+	n := s.newNode(baseName(path), typeFile, defaultFilePerm, s.Owner)
+	n.content = append([]byte(nil), content...)
+	s.nodes[path] = n
+}
+
+// Content 获取文件的内容
+func (s *Server) Content(path string) ([]byte, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	n, ok := s.nodes[cleanPath(path)]
+	if !ok {
+		return nil, false
+	}
+	return append([]byte(nil), n.content...), true
+}
+
+// Status 获取文件信息
+func (s *Server) Status(path string) (FileStatus, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	path = cleanPath(path)
+	n, ok := s.nodes[path]
+	if !ok {
+		return FileStatus{}, false
+	}
+	return s.fileStatus(path, n), true
+}
+
+// Xattrs 获取文件的所有额外属性
+func (s *Server) Xattrs(path string) map[string]string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	n, ok := s.nodes[cleanPath(path)]
+	if !ok {
+		return nil
+	}
+	attrs := make(map[string]string)
+	for name, value := range n.xattrs {
+		attrs[name] = string(value)
+	}
+	return attrs
+}
+
+// Fail 让下一个op请求返回指定的异常，可以多次调用让之后的多个请求失败
+func (s *Server) Fail(op string, status int, exception, javaClassName, message string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.failures[op] = append(s.failures[op], failure{
+		status:    status,
+		exception: RemoteException{Exception: exception, JavaClassName: javaClassName, Message: message},
+	})
+}
+
+// Disable 让NameNode不支持op，与较早版本的Hadoop一样返回400 IllegalArgumentException
+func (s *Server) Disable(op string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.disabled[op] = true
+}
+
+// SetQuota 设置目录的文件数配额和空间配额，-1表示没有配额
+func (s *Server) SetQuota(path string, nsQuota, spaceQuota int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	n := s.nodes[cleanPath(path)]
+	n.nsQuota = nsQuota
+	n.spaceQuota = spaceQuota
+}
+
+// FailDataNode 让下一个发到DataNode的op请求返回指定的异常
+func (s *Server) FailDataNode(op string, status int, exception, javaClassName, message string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.dnFails[op] = append(s.dnFails[op], failure{
+		status:    status,
+		exception: RemoteException{Exception: exception, JavaClassName: javaClassName, Message: message},
+	})
+}
+
+// Hang 之后op的请求在NameNode上挂起，直到调用返回的release或者客户端断开连接，
+// 用于测试超时和取消
+func (s *Server) Hang(op string) (release func()) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	ch := make(chan struct{})
+	s.hangs[op] = ch
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.lock.Lock()
+			if s.hangs[op] == ch {
+				delete(s.hangs, op)
+			}
+			s.lock.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// hang 如果op设置了 Hang，等待release或者客户端断开连接
+func (s *Server) hang(r *http.Request) {
+	s.lock.Lock()
+	ch := s.hangs[r.URL.Query().Get("op")]
+	s.lock.Unlock()
+
+	if ch == nil {
+		return
+	}
+	select {
+	case <-ch:
+	case <-r.Context().Done():
+	}
+}
+
+// ValidToken delegation token 是否有效
+func (s *Server) ValidToken(t string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.checkToken(t) == nil
+}
+
+func (s *Server) checkToken(t string) *remoteError {
+	tk, ok := s.tokens[t]
+	if !ok {
+		return newRemoteError(http.StatusForbidden, "InvalidToken", "org.apache.hadoop.security.token.SecretManager$InvalidToken",
+			"token ("+t+") can't be found in cache")
+	}
+	if time.Now().After(tk.expiration) {
+		return newRemoteError(http.StatusForbidden, "InvalidToken", "org.apache.hadoop.security.token.SecretManager$InvalidToken",
+			"token ("+t+") is expired, current time: "+time.Now().String()+" expected renewal time: "+tk.expiration.String())
+	}
+	return nil
+}
+
+// Requests 获取收到的所有请求
+func (s *Server) Requests() []Request {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+// LastRequest 获取最后收到的op请求
+func (s *Server) LastRequest(op string) (Request, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for i := len(s.requests) - 1; i >= 0; i-- {
+		if s.requests[i].Op == op {
+			return s.requests[i], true
+		}
+	}
+	return Request{}, false
+}
+
+func (s *Server) newNode(name, fileType, permission, owner string) *node {
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+
+	n := &node{xattrs: make(map[string][]byte), nsQuota: -1, spaceQuota: -1}
+	n.status = FileStatus{
+		FileID:           s.nextID,
+		Group:            defaultGroup,
+		ModificationTime: now,
+		Owner:            owner,
+		PathSuffix:       name,
+		Permission:       permission,
+		Type:             fileType,
+	}
+	if fileType == typeFile {
+		n.status.AccessTime = now
+		n.status.BlockSize = blockSize
+		n.status.Replication = 3
+	}
+	s.nextID++
+
+	return n
+}
+
+func (s *Server) fileStatus(path string, n *node) FileStatus {
+	status := n.status
+	status.Length = int64(len(n.content))
+	status.AclBit = len(n.acl) > 0
+	if status.Type == typeDir {
+		status.ChildrenNum = len(s.children(path))
+	}
+	if s.HttpFS {
+		status.FileID = 0
+	}
+	return status
+}
+
+func (s *Server) children(dir string) []string {
+	names := make([]string, 0)
+	for path := range s.nodes {
+		if path != "/" && parentPath(path) == dir {
+			names = append(names, baseName(path))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// mkdirs 逐级创建目录，返回遇到的异常
+func (s *Server) mkdirs(path, permission, owner string) *remoteError {
+
+	current := "/"
+	for _, name := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		if name == "" {
+			continue
+		}
+		current = joinPath(current, name)
+
+		n, ok := s.nodes[current]
+		if !ok {
+			s.nodes[current] = s.newNode(name, typeDir, permission, owner)
+		} else if n.status.Type != typeDir {
+			return newRemoteError(http.StatusForbidden, "ParentNotDirectoryException",
+				"org.apache.hadoop.fs.ParentNotDirectoryException", current+" (is not a directory)")
+		}
+	}
+	return nil
+}
+
+func cleanPath(path string) string {
+	path = "/" + strings.Trim(path, "/")
+	return path
+}
+
+func parentPath(path string) string {
+	index := strings.LastIndex(path, "/")
+	if index <= 0 {
+		return "/"
+	}
+	return path[:index]
+}
+
+func baseName(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+func joinPath(dir, name string) string {
+	if dir == "/" {
+		return "/" + name
+	}
+	return dir + "/" + name
+}
+
+// remoteError 带HTTP状态码的RemoteException
+type remoteError struct {
+	status    int
+	exception RemoteException
+}
+
+func newRemoteError(status int, exception, javaClassName, message string) *remoteError {
+	return &remoteError{
+		status:    status,
+		exception: RemoteException{Exception: exception, JavaClassName: javaClassName, Message: message},
+	}
+}
+
+func fileNotFound(path string) *remoteError {
+	return newRemoteError(http.StatusNotFound, "FileNotFoundException", "java.io.FileNotFoundException",
+		"File does not exist: "+path)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err *remoteError) {
+	writeJSON(w, err.status, map[string]RemoteException{"RemoteException": err.exception})
+}
+
+func writeBoolean(w http.ResponseWriter, b bool) {
+	writeJSON(w, http.StatusOK, map[string]bool{"boolean": b})
+}
+
+// record 记录请求，并返回请求的路径和需要注入的异常
+func (s *Server) record(r *http.Request, dataNode bool) (string, *remoteError) {
+
+	path := cleanPath(strings.TrimPrefix(r.URL.Path, PathPrefix))
+	query := r.URL.Query()
+	op := strings.ToUpper(query.Get("op"))
+
+	s.requests = append(s.requests, Request{
+		Method:   r.Method,
+		Path:     path,
+		Op:       op,
+		Query:    query,
+		Header:   r.Header.Clone(),
+		DataNode: dataNode,
+	})
+
+	failures := s.failures
+	if dataNode {
+		failures = s.dnFails
+	}
+
+	if pending := failures[op]; len(pending) > 0 {
+		failures[op] = pending[1:]
+		return path, &remoteError{status: pending[0].status, exception: pending[0].exception}
+	}
+
+	return path, nil
+}
+
+func (s *Server) owner(query url.Values) string {
+	if s.Owner != "" {
+		return s.Owner
+	}
+	if doas := query.Get("doas"); doas != "" {
+		return doas
+	}
+	return s.realUser(query)
+}
+
+// realUser 发送请求的用户，使用doas时是代理其他用户的那个用户
+func (s *Server) realUser(query url.Values) string {
+	if tk, ok := s.tokens[query.Get("delegation")]; ok {
+		return tk.owner
+	}
+	if user := query.Get("user.name"); user != "" {
+		return user
+	}
+	return "dr.who"
+}
+
+func (s *Server) serveNameNode(w http.ResponseWriter, r *http.Request, index int) {
+
+	s.hang(r)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !strings.HasPrefix(r.URL.Path, PathPrefix) {
+		http.NotFound(w, r)
+		return
+	}
+
+	path, ferr := s.record(r, false)
+	s.requests[len(s.requests)-1].NameNode = index
+
+	if s.csrfRejected(w, r) {
+		return
+	}
+
+	if index != s.active {
+		writeError(w, newRemoteError(http.StatusForbidden, "StandbyException", "org.apache.hadoop.ipc.StandbyException",
+			"Operation category READ is not supported in state standby. Visit https://s.apache.org/sbnn-error"))
+		return
+	}
+
+	if s.Authorize != nil && !s.Authorize(r) {
+		w.Header().Set("WWW-Authenticate", "Negotiate")
+		w.Header().Set("Content-Type", "text/html;charset=iso-8859-1")
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, "<html><body><h2>HTTP ERROR 401</h2><p>Authentication required</p></body></html>")
+		return
+	}
+
+	if ferr != nil {
+		writeError(w, ferr)
+		return
+	}
+
+	query := r.URL.Query()
+	op := strings.ToUpper(query.Get("op"))
+
+	if doas := query.Get("doas"); doas != "" && s.Impersonate != nil && !s.Impersonate(s.realUser(query), doas) {
+		writeError(w, newRemoteError(http.StatusForbidden, "AuthorizationException",
+			"org.apache.hadoop.security.authorize.AuthorizationException",
+			fmt.Sprintf("User: %s is not allowed to impersonate %s", s.realUser(query), doas)))
+		return
+	}
+
+	if t := query.Get("delegation"); t != "" {
+		if err := s.checkToken(t); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	handlers := map[string]opHandler{
+		"GETFILESTATUS":    {http.MethodGet, s.getFileStatus},
+		"LISTSTATUS_BATCH": {http.MethodGet, s.listStatusBatch},
+		"OPEN":             {http.MethodGet, s.redirect},
+		"GETXATTRS":        {http.MethodGet, s.getXattrs},
+		"GETHOMEDIRECTORY": {http.MethodGet, s.getHomeDirectory},
+		"GETSTATUS":        {http.MethodGet, s.getStatus},
+		"GETQUOTAUSAGE":    {http.MethodGet, s.getQuotaUsage},
+		"CHECKACCESS":      {http.MethodGet, s.checkAccess},
+		"GETACLSTATUS":     {http.MethodGet, s.getAclStatus},
+		"MKDIRS":           {http.MethodPut, s.mkdirsOp},
+		"CREATE":           {http.MethodPut, s.createRedirect},
+		"RENAME":           {http.MethodPut, s.rename},
+		"SETTIMES":         {http.MethodPut, s.setTimes},
+		"SETPERMISSION":    {http.MethodPut, s.setPermission},
+		"SETOWNER":         {http.MethodPut, s.setOwner},
+		"SETACL":           {http.MethodPut, s.modifyAcl},
+		"MODIFYACLENTRIES": {http.MethodPut, s.modifyAcl},
+		"REMOVEACLENTRIES": {http.MethodPut, s.modifyAcl},
+		"REMOVEDEFAULTACL": {http.MethodPut, s.modifyAcl},
+		"REMOVEACL":        {http.MethodPut, s.modifyAcl},
+		"SETXATTR":         {http.MethodPut, s.setXattr},
+		"REMOVEXATTR":      {http.MethodPut, s.removeXattr},
+		"CREATESYMLINK":    {http.MethodPut, s.createSymlink},
+		"APPEND":           {http.MethodPost, s.appendRedirect},
+		"TRUNCATE":         {http.MethodPost, s.truncate},
+		"DELETE":           {http.MethodDelete, s.delete},
+
+		"GETCONTENTSUMMARY": {http.MethodGet, s.getContentSummary},
+
+		"GETDELEGATIONTOKEN":    {http.MethodGet, s.getDelegationToken},
+		"RENEWDELEGATIONTOKEN":  {http.MethodPut, s.renewDelegationToken},
+		"CANCELDELEGATIONTOKEN": {http.MethodPut, s.cancelDelegationToken},
+	}
+
+	if s.HttpFS {
+		handlers["OPEN"] = handlers["OPEN"].withHandler(s.httpfsOpen)
+		handlers["CREATE"] = handlers["CREATE"].withHandler(s.httpfsUpload(s.createRedirect, s.create))
+		handlers["APPEND"] = handlers["APPEND"].withHandler(s.httpfsUpload(s.appendRedirect, func(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+			return s.append(w, r, path)
+		}))
+	}
+
+	h, ok := handlers[op]
+	if !ok || h.method != r.Method || s.disabled[op] {
+		writeError(w, newRemoteError(http.StatusBadRequest, "IllegalArgumentException", "java.lang.IllegalArgumentException",
+			fmt.Sprintf("Invalid value for webhdfs parameter \"op\": No enum constant %s.%s", r.Method, op)))
+		return
+	}
+
+	if err := h.handler(w, r, path, query); err != nil {
+		if s.HttpFS && err.status == http.StatusForbidden {
+			// HttpFS 对IOException返回500
+			err.status = http.StatusInternalServerError
+		}
+		writeError(w, err)
+	}
+}
+
+// csrfRejected 设置了 CSRFHeader 时，修改操作没有这个请求头则返回400
+func (s *Server) csrfRejected(w http.ResponseWriter, r *http.Request) bool {
+	if s.CSRFHeader == "" || r.Method == http.MethodGet || r.Header.Get(s.CSRFHeader) != "" {
+		return false
+	}
+	http.Error(w, "Missing Required Header for CSRF Vulnerability Protection", http.StatusBadRequest)
+	return true
+}
+
+type handlerFunc func(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError
+
+// opHandler 一个op的HTTP方法和处理函数
+type opHandler struct {
+	method  string
+	handler handlerFunc
+}
+
+func (h opHandler) withHandler(handler handlerFunc) opHandler {
+	h.handler = handler
+	return h
+}
+
+// httpfsOpen HttpFS直接返回文件内容，offset超出文件末尾时返回空内容
+func (s *Server) httpfsOpen(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+	n, err := s.file(path)
+	if err != nil {
+		return err
+	}
+	if offset, _ := strconv.ParseInt(query.Get("offset"), 10, 64); offset > int64(len(n.content)) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+	return s.open(w, path, query)
+}
+
+// httpfsUpload HttpFS的CREATE和APPEND：没有data=true时检查后重定向到带data=true的自己
+func (s *Server) httpfsUpload(check, upload handlerFunc) handlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+		if query.Get("data") != "true" {
+			if err := check(httptest.NewRecorder(), r, path, copyValues(query)); err != nil {
+				return err
+			}
+			query.Set("data", "true")
+			location := *r.URL
+			location.Scheme, location.Host = "http", r.Host
+			if s.tlsConfig != nil {
+				location.Scheme = "https"
+			}
+			location.RawQuery = query.Encode()
+			w.Header().Set("Location", location.String())
+			w.WriteHeader(http.StatusTemporaryRedirect)
+			return nil
+		}
+		if r.Header.Get("Content-Type") != "application/octet-stream" {
+			return newRemoteError(http.StatusBadRequest, "IllegalArgumentException", "java.lang.IllegalArgumentException",
+				"Data upload requests must have content-type set to 'application/octet-stream'")
+		}
+		if err := check(httptest.NewRecorder(), r, path, copyValues(query)); err != nil {
+			return err
+		}
+		return upload(w, r, path, query)
+	}
+}
+
+func copyValues(values url.Values) url.Values {
+	c := url.Values{}
+	for k, v := range values {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
+}
+
+func (s *Server) getFileStatus(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+	n, ok := s.nodes[path]
+	if !ok {
+		return fileNotFound(path)
+	}
+	status := s.fileStatus(path, n)
+	status.PathSuffix = ""
+	writeJSON(w, http.StatusOK, map[string]FileStatus{"FileStatus": status})
+	return nil
+}
+
+func (s *Server) getHomeDirectory(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+	writeJSON(w, http.StatusOK, map[string]string{"Path": "/user/" + s.owner(query)})
+	return nil
+}
+
+func (s *Server) getStatus(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+	used := int64(0)
+	for _, n := range s.nodes {
+		used += int64(len(n.content)) * int64(n.status.Replication)
+	}
+	writeJSON(w, http.StatusOK, map[string]map[string]int64{"FsStatus": {
+		"capacity":  s.Capacity,
+		"used":      used,
+		"remaining": s.Capacity - used,
+	}})
+	return nil
+}
+
+// contentSummary 统计目录下(包括目录本身)的目录数、文件数、长度和包括副本的空间
+func (s *Server) contentSummary(path string) (dirs, files, length, consumed int64) {
+	for p, n := range s.nodes {
+		if p != path && path != "/" && !strings.HasPrefix(p, path+"/") {
+			continue
+		}
+		if n.status.Type == typeDir {
+			dirs++
+		} else {
+			files++
+		}
+		length += int64(len(n.content))
+		consumed += int64(len(n.content)) * int64(n.status.Replication)
+	}
+	return dirs, files, length, consumed
+}
+
+func (s *Server) getQuotaUsage(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+	n, ok := s.nodes[path]
+	if !ok {
+		return fileNotFound(path)
+	}
+	dirs, files, _, consumed := s.contentSummary(path)
+	writeJSON(w, http.StatusOK, map[string]map[string]int64{"QuotaUsage": {
+		"fileAndDirectoryCount": dirs + files,
+		"quota":                 n.nsQuota,
+		"spaceConsumed":         consumed,
+		"spaceQuota":            n.spaceQuota,
+	}})
+	return nil
+}
+
+func (s *Server) getContentSummary(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+	n, ok := s.nodes[path]
+	if !ok {
+		return fileNotFound(path)
+	}
+	dirs, files, length, consumed := s.contentSummary(path)
+	writeJSON(w, http.StatusOK, map[string]map[string]int64{"ContentSummary": {
+		"directoryCount": dirs,
+		"fileCount":      files,
+		"length":         length,
+		"quota":          n.nsQuota,
+		"spaceConsumed":  consumed,
+		"spaceQuota":     n.spaceQuota,
+	}})
+	return nil
+}
+
+// checkAccess 按权限位检查，不是owner的用户使用other的权限
+func (s *Server) checkAccess(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+	n, ok := s.nodes[path]
+	if !ok {
+		return fileNotFound(path)
+	}
+
+	action := query.Get("fsaction")
+	if len(action) != 3 || strings.Trim(action, "rwx-") != "" {
+		return newRemoteError(http.StatusBadRequest, "IllegalArgumentException", "java.lang.IllegalArgumentException",
+			fmt.Sprintf("Invalid value for webhdfs parameter \"fsaction\": %s", action))
+	}
+
+	user := query.Get("doas")
+	if user == "" {
+		user = s.realUser(query)
+	}
+
+	mode, _ := strconv.ParseUint(n.status.Permission, 8, 16)
+	if user != n.status.Owner {
+		mode <<= 6
+	}
+	for i, bit := range []uint64{0400, 0200, 0100} {
+		if action[i] != '-' && mode&bit == 0 {
+			return newRemoteError(http.StatusForbidden, "AccessControlException", "org.apache.hadoop.security.AccessControlException",
+				fmt.Sprintf("Permission denied: user=%s, access=%s, inode=\"%s\"", user, action, path))
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *Server) listStatusBatch(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+	n, ok := s.nodes[path]
+	if !ok {
+		return fileNotFound(path)
+	}
+
+	statuses := make([]FileStatus, 0)
+	remaining := 0
+
+	if n.status.Type == typeDir {
+		names := s.children(path)
+		startAfter := query.Get("startAfter")
+		start := sort.SearchStrings(names, startAfter)
+		if start < len(names) && names[start] == startAfter {
+			start++
+		}
+		names = names[start:]
+		for i, name := range names {
+			if i >= s.ListLimit {
+				remaining = len(names) - i
+				break
+			}
+			child := joinPath(path, name)
+			statuses = append(statuses, s.fileStatus(child, s.nodes[child]))
+		}
+	} else {
+		status := s.fileStatus(path, n)
+		status.PathSuffix = ""
+		statuses = append(statuses, status)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"DirectoryListing": map[string]interface{}{
+			"partialListing": map[string]interface{}{
+				"FileStatuses": map[string]interface{}{"FileStatus": statuses},
+			},
+			"remainingEntries": remaining,
+		},
+	})
+	return nil
+}
+
+// redirect 返回重定向到DataNode的307，noredirect=true时返回200和JSON格式的地址(Hadoop 3)
+func (s *Server) redirect(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+
+	datanode := s.DataNode.URL
+	if s.DataNodeAddr != "" {
+		u, _ := url.Parse(datanode)
+		u.Host = s.DataNodeAddr
+		datanode = u.String()
+	}
+
+	noRedirect := query.Get("noredirect") == "true" && !s.IgnoreNoRedirect
+	query.Del("noredirect")
+
+	location := datanode + PathPrefix + r.URL.EscapedPath()[len(PathPrefix):]
+	query.Set("namenoderpcaddress", "localhost:8020")
+	location += "?" + query.Encode()
+
+	if noRedirect {
+		writeJSON(w, http.StatusOK, map[string]string{"Location": location})
+		return nil
+	}
+
+	w.Header().Set("Location", location)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusTemporaryRedirect)
+	return nil
+}
+
+func (s *Server) createRedirect(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+	if n, ok := s.nodes[path]; ok {
+		if n.status.Type == typeDir {
+			return newRemoteError(http.StatusForbidden, "FileAlreadyExistsException",
+				"org.apache.hadoop.fs.FileAlreadyExistsException", path+" already exists as a directory")
+		}
+		if query.Get("overwrite") != "true" {
+			return newRemoteError(http.StatusForbidden, "FileAlreadyExistsException",
+				"org.apache.hadoop.fs.FileAlreadyExistsException", path+" for client 127.0.0.1 already exists")
+		}
+	}
+	return s.redirect(w, r, path, query)
+}
+
+func (s *Server) appendRedirect(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+	if _, err := s.file(path); err != nil {
+		return err
+	}
+	return s.redirect(w, r, path, query)
+}
+
+func (s *Server) file(path string) (*node, *remoteError) {
+	n, ok := s.nodes[path]
+	if !ok {
+		return nil, fileNotFound(path)
+	}
+	if n.status.Type != typeFile {
+		return nil, newRemoteError(http.StatusNotFound, "FileNotFoundException", "java.io.FileNotFoundException",
+			"Path is not a file: "+path)
+	}
+	return n, nil
+}
+
+// applyUmask 去掉新建文件和目录权限中 Umask 的位
+func (s *Server) applyUmask(permission string) string {
+	mode, err := strconv.ParseUint(permission, 8, 16)
+	if err != nil {
+		return permission
+	}
+	return strconv.FormatUint(mode&^uint64(s.Umask), 8)
+}
+
+func (s *Server) mkdirsOp(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+	permission := query.Get("permission")
+	if permission == "" {
+		permission = defaultDirPerm
+	}
+	if err := s.mkdirs(path, s.applyUmask(permission), s.owner(query)); err != nil {
+		return err
+	}
+	writeBoolean(w, true)
+	return nil
+}
+
+func (s *Server) rename(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+
+	dest := cleanPath(query.Get("destination"))
+
+	if _, ok := s.nodes[path]; !ok || path == "/" {
+		writeBoolean(w, false)
+		return nil
+	}
+	// 目标是目录时移动到目录下
+	if n, ok := s.nodes[dest]; ok && n.status.Type == typeDir {
+		dest = joinPath(dest, baseName(path))
+	}
+	if _, ok := s.nodes[dest]; ok || dest == path || strings.HasPrefix(dest, path+"/") {
+		writeBoolean(w, false)
+		return nil
+	}
+	if n, ok := s.nodes[parentPath(dest)]; !ok || n.status.Type != typeDir {
+		writeBoolean(w, false)
+		return nil
+	}
+
+	moved := make(map[string]*node)
+	for p, n := range s.nodes {
+		if p == path || strings.HasPrefix(p, path+"/") {
+			moved[dest+strings.TrimPrefix(p, path)] = n
+			delete(s.nodes, p)
+		}
+	}
+	for p, n := range moved {
+		s.nodes[p] = n
+	}
+	s.nodes[dest].status.PathSuffix = baseName(dest)
+
+	writeBoolean(w, true)
+	return nil
+}
+
+func (s *Server) setTimes(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+	n, ok := s.nodes[path]
+	if !ok {
+		return fileNotFound(path)
+	}
+	if mtime, err := strconv.ParseInt(query.Get("modificationtime"), 10, 64); err == nil && mtime >= 0 {
+		n.status.ModificationTime = mtime
+	}
+	if atime, err := strconv.ParseInt(query.Get("accesstime"), 10, 64); err == nil && atime >= 0 {
+		n.status.AccessTime = atime
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *Server) setPermission(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+	n, ok := s.nodes[path]
+	if !ok {
+		return fileNotFound(path)
+	}
+	permission := query.Get("permission")
+	if _, err := strconv.ParseUint(permission, 8, 16); err != nil {
+		return newRemoteError(http.StatusBadRequest, "IllegalArgumentException", "java.lang.IllegalArgumentException",
+			"Invalid value for webhdfs parameter \"permission\": "+permission)
+	}
+	n.status.Permission = permission
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// setOwner 与HDFS一样，只有超级用户可以修改owner，文件的owner可以修改group(不检查是否属于这个组)
+func (s *Server) setOwner(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+	n, ok := s.nodes[path]
+	if !ok {
+		return fileNotFound(path)
+	}
+
+	user := query.Get("doas")
+	if user == "" {
+		user = s.realUser(query)
+	}
+
+	owner, group := query.Get("owner"), query.Get("group")
+	if user != s.Superuser {
+		if owner != "" {
+			return newRemoteError(http.StatusForbidden, "AccessControlException", "org.apache.hadoop.security.AccessControlException",
+				"Non-super user cannot change owner")
+		}
+		if user != n.status.Owner {
+			return newRemoteError(http.StatusForbidden, "AccessControlException", "org.apache.hadoop.security.AccessControlException",
+				fmt.Sprintf("Permission denied. user=%s is not the owner of inode=%s", user, path))
+		}
+	}
+
+	if owner != "" {
+		n.status.Owner = owner
+	}
+	if group != "" {
+		n.status.Group = group
+	}
+
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *Server) truncate(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+	n, err := s.file(path)
+	if err != nil {
+		return err
+	}
+	newLength, perr := strconv.ParseInt(query.Get("newlength"), 10, 64)
+	if perr != nil || newLength < 0 || newLength > int64(len(n.content)) {
+		return newRemoteError(http.StatusBadRequest, "HadoopIllegalArgumentException",
+			"org.apache.hadoop.HadoopIllegalArgumentException",
+			fmt.Sprintf("Cannot truncate to a larger file size. Current size: %d, truncate size: %s.", len(n.content), query.Get("newlength")))
+	}
+	n.content = n.content[:newLength]
+	n.status.ModificationTime = time.Now().UnixNano() / int64(time.Millisecond)
+	writeBoolean(w, true)
+	return nil
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+	n, ok := s.nodes[path]
+	if !ok || path == "/" {
+		writeBoolean(w, false)
+		return nil
+	}
+	recursive := query.Get("recursive") == "true"
+	if n.status.Type == typeDir && len(s.children(path)) > 0 && !recursive {
+		return newRemoteError(http.StatusForbidden, "PathIsNotEmptyDirectoryException",
+			"org.apache.hadoop.fs.PathIsNotEmptyDirectoryException", "`"+path+" is non empty': Directory is not empty")
+	}
+	for p := range s.nodes {
+		if p == path || strings.HasPrefix(p, path+"/") {
+			delete(s.nodes, p)
+		}
+	}
+	writeBoolean(w, true)
+	return nil
+}
+
+func (s *Server) createSymlink(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+	return newRemoteError(http.StatusBadRequest, "UnsupportedOperationException", "java.lang.UnsupportedOperationException",
+		"Symlinks not supported")
+}
+
+// checkXattrName 检查xattr的namespace
+func checkXattrName(name string) *remoteError {
+	for _, prefix := range []string{"user.", "trusted.", "system.", "security.", "raw."} {
+		if strings.HasPrefix(strings.ToLower(name), prefix) && len(name) > len(prefix) {
+			return nil
+		}
+	}
+	return newRemoteError(http.StatusBadRequest, "HadoopIllegalArgumentException",
+		"org.apache.hadoop.HadoopIllegalArgumentException",
+		"An XAttr name must be prefixed with user/trusted/security/system/raw, followed by a '.'")
+}
+
+// decodeXattrValue 与 XAttrCodec.decodeValue 一致
+func decodeXattrValue(value string) []byte {
+	if len(value) >= 2 && strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"") {
+		return []byte(value[1 : len(value)-1])
+	}
+	return []byte(value)
+}
+
+func (s *Server) setXattr(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+	n, ok := s.nodes[path]
+	if !ok {
+		return fileNotFound(path)
+	}
+	name := query.Get("xattr.name")
+	if err := checkXattrName(name); err != nil {
+		return err
+	}
+
+	_, exist := n.xattrs[name]
+	flag := strings.ToUpper(query.Get("flag"))
+	if flag == "CREATE" && exist {
+		return newRemoteError(http.StatusForbidden, "IOException", "java.io.IOException",
+			"XAttr: "+name+" already exists. The REPLACE flag must be specified.")
+	}
+	if flag == "REPLACE" && !exist {
+		return newRemoteError(http.StatusForbidden, "IOException", "java.io.IOException",
+			"XAttr: "+name+" does not exist. The CREATE flag must be specified.")
+	}
+
+	n.xattrs[name] = decodeXattrValue(query.Get("xattr.value"))
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *Server) getXattrs(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+	n, ok := s.nodes[path]
+	if !ok {
+		return fileNotFound(path)
+	}
+
+	names := query["xattr.name"]
+	if len(names) == 0 {
+		for name := range n.xattrs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	xattrs := make([]map[string]string, 0)
+	for _, name := range names {
+		value, ok := n.xattrs[name]
+		if !ok {
+			return newRemoteError(http.StatusForbidden, "IOException", "java.io.IOException",
+				"At least one of the attributes provided was not found.")
+		}
+		// encoding=text 时与 XAttrCodec.encodeValue 一样用双引号括起来
+		xattrs = append(xattrs, map[string]string{"name": name, "value": "\"" + string(value) + "\""})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"XAttrs": xattrs})
+	return nil
+}
+
+func (s *Server) removeXattr(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+	n, ok := s.nodes[path]
+	if !ok {
+		return fileNotFound(path)
+	}
+	name := query.Get("xattr.name")
+	if err := checkXattrName(name); err != nil {
+		return err
+	}
+	if _, exist := n.xattrs[name]; !exist {
+		return newRemoteError(http.StatusForbidden, "IOException", "java.io.IOException",
+			"No matching attributes found for remove operation")
+	}
+	delete(n.xattrs, name)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *Server) getDelegationToken(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+	if query.Get("delegation") != "" {
+		return newRemoteError(http.StatusForbidden, "IOException", "java.io.IOException",
+			"Delegation Token can be issued only with kerberos or web authentication")
+	}
+
+	s.nextToken++
+	t := fmt.Sprintf("token-%d", s.nextToken)
+	now := time.Now()
+	s.tokens[t] = &token{
+		owner:      s.owner(query),
+		renewer:    query.Get("renewer"),
+		expiration: now.Add(s.TokenRenewInterval),
+		maxDate:    now.Add(s.TokenMaxLifetime),
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"Token": map[string]string{"urlString": t}})
+	return nil
+}
+
+func (s *Server) renewDelegationToken(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+	t := query.Get("token")
+	if err := s.checkToken(t); err != nil {
+		return err
+	}
+	tk := s.tokens[t]
+	if time.Now().After(tk.maxDate) {
+		return newRemoteError(http.StatusForbidden, "InvalidToken", "org.apache.hadoop.security.token.SecretManager$InvalidToken",
+			"token ("+t+") can't be renewed beyond max date "+tk.maxDate.String())
+	}
+	tk.expiration = time.Now().Add(s.TokenRenewInterval)
+	if tk.expiration.After(tk.maxDate) {
+		tk.expiration = tk.maxDate
+	}
+
+	writeJSON(w, http.StatusOK, map[string]int64{"long": tk.expiration.UnixNano() / int64(time.Millisecond)})
+	return nil
+}
+
+func (s *Server) cancelDelegationToken(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+	t := query.Get("token")
+	if _, ok := s.tokens[t]; !ok {
+		return s.checkToken(t)
+	}
+	delete(s.tokens, t)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *Server) serveDataNode(w http.ResponseWriter, r *http.Request) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	path, ferr := s.record(r, true)
+	if s.csrfRejected(w, r) {
+		return
+	}
+	if ferr != nil {
+		writeError(w, ferr)
+		return
+	}
+	query := r.URL.Query()
+
+	var err *remoteError
+	switch strings.ToUpper(query.Get("op")) {
+	case "OPEN":
+		err = s.open(w, path, query)
+	case "CREATE":
+		err = s.create(w, r, path, query)
+	case "APPEND":
+		err = s.append(w, r, path)
+	default:
+		err = newRemoteError(http.StatusBadRequest, "IllegalArgumentException", "java.lang.IllegalArgumentException",
+			"Invalid operation "+query.Get("op"))
+	}
+
+	if err != nil {
+		writeError(w, err)
+	}
+}
+
+func (s *Server) open(w http.ResponseWriter, path string, query url.Values) *remoteError {
+	n, err := s.file(path)
+	if err != nil {
+		return err
+	}
+
+	size := int64(len(n.content))
+	offset, _ := strconv.ParseInt(query.Get("offset"), 10, 64)
+	if offset < 0 || offset > size {
+		return newRemoteError(http.StatusForbidden, "IOException", "java.io.IOException",
+			fmt.Sprintf("Offset=%d out of the range [0, %d); OPEN, path=%s", offset, size, path))
+	}
+	end := size
+	if length, perr := strconv.ParseInt(query.Get("length"), 10, 64); perr == nil && offset+length < size {
+		end = offset + length
+	}
+
+	n.status.AccessTime = time.Now().UnixNano() / int64(time.Millisecond)
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	w.Write(n.content[offset:end])
+	return nil
+}
+
+func (s *Server) create(w http.ResponseWriter, r *http.Request, path string, query url.Values) *remoteError {
+	if err := s.mkdirs(parentPath(path), defaultDirPerm, s.owner(query)); err != nil {
+		return err
+	}
+
+	permission := query.Get("permission")
+	if permission == "" {
+		permission = defaultFilePerm
+	}
+
+	buf, _ := ioutil.ReadAll(r.Body)
+
+	n := s.newNode(baseName(path), typeFile, s.applyUmask(permission), s.owner(query))
+	n.content = buf
+	s.nodes[path] = n
+
+	w.Header().Set("Location", "hdfs://localhost:8020"+path)
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+func (s *Server) append(w http.ResponseWriter, r *http.Request, path string) *remoteError {
+	n, err := s.file(path)
+	if err != nil {
+		return err
+	}
+	buf, _ := ioutil.ReadAll(r.Body)
+	n.content = append(n.content, buf...)
+	n.status.ModificationTime = time.Now().UnixNano() / int64(time.Millisecond)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
@@ -0,0 +1,126 @@
+package webhdfstest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TokenServer 假的OAuth2 token endpoint，支持client_credentials和refresh_token授权，
+// 每次授权都会签发一个新的access token，之前的token失效
+type TokenServer struct {
+	*httptest.Server
+
+	ClientID     string
+	ClientSecret string
+
+	// RefreshToken refresh_token授权时接受的refresh token
+	RefreshToken string
+	// RotateRefreshToken 为true时每次refresh_token授权都返回新的refresh token，旧的失效
+	RotateRefreshToken bool
+
+	// ExpiresIn 返回的expires_in，为0时不返回
+	ExpiresIn int64
+
+	lock    sync.Mutex
+	issued  int
+	current string
+	grants  []string
+}
+
+// NewTokenServer 创建并启动一个假的token endpoint
+func NewTokenServer(clientID, clientSecret string) *TokenServer {
+	s := &TokenServer{ClientID: clientID, ClientSecret: clientSecret}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Valid access token 是否是最新签发的
+func (s *TokenServer) Valid(token string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return token != "" && token == s.current
+}
+
+// Authorize 可以作为 Server.Authorize，只接受最新签发的Bearer token
+func (s *TokenServer) Authorize(r *http.Request) bool {
+	return s.Valid(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+}
+
+// Revoke 让当前的access token失效，模拟token过期或者被撤销
+func (s *TokenServer) Revoke() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.current = ""
+}
+
+// SetClientSecret 更换client secret，模拟secret轮换
+func (s *TokenServer) SetClientSecret(secret string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.ClientSecret = secret
+}
+
+// Grants 收到的授权请求的grant_type
+func (s *TokenServer) Grants() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string(nil), s.grants...)
+}
+
+func (s *TokenServer) serve(w http.ResponseWriter, r *http.Request) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.ParseForm()
+
+	grant := r.PostForm.Get("grant_type")
+	s.grants = append(s.grants, grant)
+
+	fail := func(status int, code, description string) {
+		writeJSON(w, status, map[string]string{"error": code, "error_description": description})
+	}
+
+	if r.PostForm.Get("client_id") != s.ClientID {
+		fail(http.StatusUnauthorized, "invalid_client", "unknown client "+r.PostForm.Get("client_id"))
+		return
+	}
+
+	resp := map[string]interface{}{"token_type": "Bearer"}
+
+	switch grant {
+	case "client_credentials":
+		if s.ClientSecret == "" || r.PostForm.Get("client_secret") != s.ClientSecret {
+			fail(http.StatusUnauthorized, "invalid_client", "client authentication failed")
+			return
+		}
+	case "refresh_token":
+		if s.RefreshToken == "" || r.PostForm.Get("refresh_token") != s.RefreshToken {
+			fail(http.StatusBadRequest, "invalid_grant", "refresh token is invalid or expired")
+			return
+		}
+		if s.RotateRefreshToken {
+			s.RefreshToken = "refresh-" + strconv.Itoa(s.issued+1)
+			resp["refresh_token"] = s.RefreshToken
+		}
+	default:
+		fail(http.StatusBadRequest, "unsupported_grant_type", "grant type "+grant+" is not supported")
+		return
+	}
+
+	s.issued++
+	s.current = "access-" + strconv.Itoa(s.issued)
+	resp["access_token"] = s.current
+	if s.ExpiresIn > 0 {
+		resp["expires_in"] = s.ExpiresIn
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
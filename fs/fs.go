@@ -1,9 +1,12 @@
 package fs
 
 import (
+	"context"
 	"hadoop-fs/fs/config"
 	"hadoop-fs/fs/controler"
 	"hadoop-fs/fs/logger"
+	"hadoop-fs/fs/model"
+	"io"
 	"os"
 	"os/signal"
 	"syscall"
@@ -14,19 +17,43 @@ import (
 )
 
 var pathManager = util.FusePathManager{}
-var hadoopControler controler.HadoopController
+var hadoopControler controler.Backend
 var notExistManager = util.NotExistManager{}
+var requestManager = RequestManager{}
+var proxyUsers = ProxyUsers{}
+var statfsCache = StatfsCache{}
+var accessCache = AccessCache{}
+var identities = Identities{}
 
-// Service 服务开始
-func Service(cg config.Config) {
+// Service 服务开始，所有的文件操作都由backend完成
+func Service(cg config.Config, backend controler.Backend) {
 
-	hadoopControler = controler.HadoopController{}
-	hadoopControler.Init(false, cg.Hadoop.Host, cg.Hadoop.Port, cg.Hadoop.Username)
+	hadoopControler = backend
+
+	root, err := resolveRemoteRoot(context.Background(), cg.RemoteRoot)
+	if err != nil {
+		logger.Error.Println(err)
+		return
+	}
+	remoteRoot = root
+	controler.ROOT.Init(backend, remoteRoot)
+	logger.Info.Printf("mount HDFS [%s] on [%s]\n", remoteRoot, cg.Mountpoint)
 
 	notExistManager.Init(cg.NotExistCacheTimeout)
 
+	statfsCache.Init(cg.StatfsCacheTimeout)
+
+	accessCache.Init(cg.AccessCacheTimeout)
+
 	pathManager.Init()
 
+	requestManager.Init()
+
+	proxyUsers.Init(cg.ProxyUser, cg.ProxyUserMap, cg.ProxyUserDeny)
+
+	identities.Init(cg.UIDMap, cg.GIDMap)
+	model.SetIDMap(cg.UIDMap, cg.GIDMap)
+
 	opts := fuse.Opt{}
 	opts.Getattr = &getattr
 	opts.Opendir = &opendir
@@ -47,23 +74,35 @@ func Service(cg config.Config) {
 	opts.Getxattr = &getxattr
 	opts.Listxattr = &listxattr
 	opts.Removexattr = &removexattr
+	opts.Statfs = &statfs
+	opts.Access = &access
+	opts.Interrupt = &interrupt
+	opts.Readlink = &readlink
+
+	// HDFS默认不支持软连接，开启模拟时才可以创建
+	if cg.SymlinkEmulation {
+		opts.Symlink = &symlink
+		logger.Info.Println("symlink emulation enabled")
+	}
 
-	// Hadoop不支持，暂时去掉
-	// opts.Symlink = &symlink
+	if cg.ReadOnly {
+		readOnly(&opts)
+		logger.Info.Println("mount read-only")
+	}
 
 	se := fuse.NewFuseSession(cg.Mountpoint, &opts, 1024)
 
 	se.Debug = cg.Debug
 	se.FuseConfig.AttrTimeout = cg.Attrtimeout
 
-	err := mount.Mount(se, nil)
+	err = mount.Mount(se, mountOptions(cg.ReadOnly))
 
 	if err != nil {
 		logger.Error.Println(err)
 		return
 	}
 
-	signalChan := make(chan os.Signal)
+	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, os.Interrupt, os.Kill, syscall.SIGHUP, syscall.SIGTERM, syscall.SIGQUIT)
 	go exitSign(signalChan, se)
 
@@ -71,6 +110,13 @@ func Service(cg config.Config) {
 
 	se.Close()
 
+	// 释放后端的资源，比如取消delegation token
+	if closer, ok := hadoopControler.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			logger.Error.Println(err)
+		}
+	}
+
 }
 
 func umount(se *fuse.Session) {
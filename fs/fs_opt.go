@@ -2,44 +2,114 @@ package fs
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"hadoop-fs/fs/controler"
 	herr "hadoop-fs/fs/controler/hadoop_error"
 	"hadoop-fs/fs/logger"
+	"hadoop-fs/fs/model"
 	"hadoop-fs/fs/util"
+	"path"
+	"strings"
 	"syscall"
 
 	"github.com/mingforpc/fuse-go/fuse"
 	"github.com/mingforpc/fuse-go/fuse/errno"
 )
 
-// 统一的错误处理
-func recoverError(res *int32) {
-	if err := recover(); err != nil {
-		switch err {
-		case herr.ErrNoFound:
-			*res = errno.ENOENT
-		case herr.ErrExist:
-			*res = errno.EEXIST
-		case herr.ErrAccess:
-			*res = errno.ENOENT
-		case herr.ErrAgain:
-			*res = errno.EAGAIN
-		case herr.ErrNotsup:
-			*res = errno.ENOTSUP
-		case herr.ErrRange:
-			*res = errno.ERANGE
-		case herr.ErrNoAttr:
-			*res = errno.ENOATTR
-		default:
-			*res = errno.ENOSYS
+// errnoTable 后端返回的错误对应的errno，按顺序使用 errors.Is 判断
+var errnoTable = []struct {
+	err   error
+	errno int32
+}{
+	{herr.ErrNoFound, errno.ENOENT},
+	{herr.ErrExist, errno.EEXIST},
+	{herr.ErrAccess, errno.EACCES},
+	{herr.ErrAuth, errno.EACCES},
+	{herr.ErrAgain, errno.EAGAIN},
+	{herr.ErrNotsup, errno.ENOTSUP},
+	{herr.ErrRange, errno.ERANGE},
+	{herr.ErrNoAttr, errno.ENOATTR},
+	{herr.ErrNotDir, errno.ENOTDIR},
+	{herr.ErrNotEmpty, errno.ENOTEMPTY},
+	{herr.ErrQuota, errno.EDQUOT},
+	{herr.ErrSafeMode, errno.EROFS},
+	{herr.ErrLease, errno.EIO},
+	{herr.ErrCrossDevice, errno.EXDEV},
+	{herr.ErrPerm, errno.EPERM},
+	{herr.ErrInvalid, errno.EINVAL},
+	// 请求被INTERRUPT取消
+	{context.Canceled, errno.EINTR},
+	{context.DeadlineExceeded, errno.ETIMEDOUT},
+}
+
+// toErrno 把后端返回的错误转换成errno，无法识别的错误返回EIO并记录日志
+func toErrno(err error) int32 {
+	for _, entry := range errnoTable {
+		if errors.Is(err, entry.err) {
+			return entry.errno
 		}
+	}
+
+	logger.Error.Printf("unexpected error: %v\n", err)
+	return errno.EIO
+}
+
+// remoteRoot 挂载点对应的HDFS目录
+var remoteRoot = "/"
 
+// remotePath 把挂载点中的路径转换成HDFS中的路径，挂载点之外的路径不会被访问到
+func remotePath(localPath string) string {
+	if localPath == "" {
+		// 路径不存在时保持为空，不能变成挂载的目录
+		return ""
 	}
+	// 先相对 "/" 清理，".." 不能超出挂载的目录
+	return path.Join(remoteRoot, path.Clean("/"+localPath))
+}
+
+// resolveRemoteRoot 确定挂载的HDFS目录，"~" 开头时相对于用户的home目录(GETHOMEDIRECTORY)，
+// 目录需要存在
+func resolveRemoteRoot(ctx context.Context, root string) (string, error) {
+
+	if root == "" {
+		root = "/"
+	}
+
+	if root == "~" || strings.HasPrefix(root, "~/") {
+		home, err := hadoopControler.GetHomeDirectory(ctx)
+		if err != nil {
+			return "", fmt.Errorf("get home directory failed: %w", err)
+		}
+		root = home + root[1:]
+	}
+
+	if !strings.HasPrefix(root, "/") {
+		return "", fmt.Errorf("remote root [%s] must be an absolute path", root)
+	}
+	root = path.Clean(root)
+
+	file, err := hadoopControler.GetFileStatus(ctx, root)
+	if err != nil {
+		return "", fmt.Errorf("remote root [%s]: %w", root, err)
+	}
+	file.AdjustNormal()
+	if file.FileType != model.TypeDir {
+		return "", fmt.Errorf("remote root [%s] is not a directory: %w", root, herr.ErrNotDir)
+	}
+
+	return root, nil
 }
 
 var getattr = func(req fuse.Req, nodeid uint64) (fsStat *fuse.FileStat, result int32) {
 
-	defer recoverError(&result)
+	ctx, done := requestManager.Begin(req)
+	defer done()
+	ctx, err := proxyUsers.WithCaller(ctx, req)
+	if err != nil {
+		return nil, toErrno(err)
+	}
 
 	path := pathManager.Get(nodeid)
 
@@ -47,16 +117,20 @@ var getattr = func(req fuse.Req, nodeid uint64) (fsStat *fuse.FileStat, result i
 
 	if path == "/" {
 
-		rootfile := controler.ROOT.GetRoot(req)
+		rootfile, err := controler.ROOT.GetRoot(ctx, req)
+
+		if err != nil {
+			return nil, toErrno(err)
+		}
 
 		rootfile.WriteToStat(&fsStat.Stat)
 
 	} else {
 
-		file, err := hadoopControler.GetFileStatus(path)
+		file, err := hadoopControler.GetFileStatus(ctx, remotePath(path))
 
 		if err != nil {
-			panic(err)
+			return nil, toErrno(err)
 		}
 
 		file.AdjustNormal()
@@ -76,7 +150,12 @@ var opendir = func(req fuse.Req, nodeid uint64, fi *fuse.FileInfo) int32 {
 
 var readdir = func(req fuse.Req, nodeid uint64, size uint32, offset uint64, fi fuse.FileInfo) (fileList []fuse.Dirent, result int32) {
 
-	defer recoverError(&result)
+	ctx, done := requestManager.Begin(req)
+	defer done()
+	ctx, err := proxyUsers.WithCaller(ctx, req)
+	if err != nil {
+		return nil, toErrno(err)
+	}
 
 	path := pathManager.Get(nodeid)
 
@@ -109,7 +188,10 @@ var readdir = func(req fuse.Req, nodeid uint64, size uint32, offset uint64, fi f
 	fileOffset := uint64(2)
 
 	for {
-		remoteFiles, remain, _ := hadoopControler.List(path, lastPathSuffix)
+		remoteFiles, remain, err := hadoopControler.List(ctx, remotePath(path), lastPathSuffix)
+		if err != nil {
+			return nil, toErrno(err)
+		}
 
 		for _, val := range remoteFiles {
 
@@ -147,8 +229,6 @@ var readdir = func(req fuse.Req, nodeid uint64, size uint32, offset uint64, fi f
 
 var release = func(req fuse.Req, nodeid uint64, fi fuse.FileInfo) (result int32) {
 
-	defer recoverError(&result)
-
 	path := pathManager.Get(nodeid)
 
 	if path != "/" {
@@ -161,24 +241,29 @@ var release = func(req fuse.Req, nodeid uint64, fi fuse.FileInfo) (result int32)
 
 var lookup = func(req fuse.Req, parentId uint64, name string) (fsStat *fuse.FileStat, result int32) {
 
-	defer recoverError(&result)
+	ctx, done := requestManager.Begin(req)
+	defer done()
+	ctx, err := proxyUsers.WithCaller(ctx, req)
+	if err != nil {
+		return nil, toErrno(err)
+	}
 
 	parentPath := pathManager.Get(parentId)
 	filePath := util.MergePath(parentPath, name)
 
 	if notExistManager.IsNotExist(filePath) == false {
 		// 文件不存在
-		panic(herr.ErrNoFound)
-		// return errno.ENOENT
+		return nil, errno.ENOENT
 	}
 
-	file, err := hadoopControler.GetFileStatus(filePath)
+	file, err := hadoopControler.GetFileStatus(ctx, remotePath(filePath))
 
-	if err != nil {
+	if errors.Is(err, herr.ErrNoFound) {
 		// 不存在的文件会缓存 notExistManager 中的秒数
 		notExistManager.Set(filePath, notExistManager.NegativeTimeout)
-		// return errno.ENOENT
-		panic(herr.ErrNoFound)
+		return nil, errno.ENOENT
+	} else if err != nil {
+		return nil, toErrno(err)
 	}
 
 	file.AdjustNormal()
@@ -204,7 +289,12 @@ var open = func(req fuse.Req, nodeid uint64, fi *fuse.FileInfo) int32 {
 
 var read = func(req fuse.Req, nodeid uint64, size uint32, offset uint64, fi fuse.FileInfo) (content []byte, result int32) {
 
-	defer recoverError(&result)
+	ctx, done := requestManager.Begin(req)
+	defer done()
+	ctx, err := proxyUsers.WithCaller(ctx, req)
+	if err != nil {
+		return nil, toErrno(err)
+	}
 
 	path := pathManager.Get(nodeid)
 
@@ -215,11 +305,10 @@ var read = func(req fuse.Req, nodeid uint64, size uint32, offset uint64, fi fuse
 		return nil, errno.ENOENT
 	}
 
-	content, err := hadoopControler.Read(path, offset, size, 0)
+	content, err = hadoopControler.Read(ctx, remotePath(path), offset, size, 0)
 
-	if err != nil && err != herr.ErrEOF {
-		// TODO: 出错
-		panic(err)
+	if err != nil && !errors.Is(err, herr.ErrEOF) {
+		return nil, toErrno(err)
 	}
 
 	result = errno.SUCCESS
@@ -228,25 +317,30 @@ var read = func(req fuse.Req, nodeid uint64, size uint32, offset uint64, fi fuse
 
 var mkdir = func(req fuse.Req, parentid uint64, name string, mode uint32) (stat *fuse.FileStat, result int32) {
 
-	defer recoverError(&result)
+	ctx, done := requestManager.Begin(req)
+	defer done()
+	ctx, err := proxyUsers.WithCaller(ctx, req)
+	if err != nil {
+		return nil, toErrno(err)
+	}
 
 	path := pathManager.Get(parentid)
 	filePath := util.MergePath(path, name)
 
 	modeStr := util.ModeToStr(mode)
 
-	success, err := hadoopControler.MakeDir(filePath, modeStr)
+	success, err := hadoopControler.MakeDir(ctx, remotePath(filePath), modeStr)
 
 	if err != nil {
-		panic(err)
+		return nil, toErrno(err)
 	} else if !success {
-		panic(herr.ErrAccess)
+		return nil, errno.EACCES
 	}
 
-	file, err := hadoopControler.GetFileStatus(filePath)
+	file, err := hadoopControler.GetFileStatus(ctx, remotePath(filePath))
 
 	if err != nil {
-		panic(err)
+		return nil, toErrno(err)
 	}
 
 	stat = &fuse.FileStat{}
@@ -267,7 +361,12 @@ var mkdir = func(req fuse.Req, parentid uint64, name string, mode uint32) (stat
 
 var create = func(req fuse.Req, parentid uint64, name string, mode uint32, fi *fuse.FileInfo) (stat *fuse.FileStat, result int32) {
 
-	defer recoverError(&result)
+	ctx, done := requestManager.Begin(req)
+	defer done()
+	ctx, err := proxyUsers.WithCaller(ctx, req)
+	if err != nil {
+		return nil, toErrno(err)
+	}
 
 	logger.Trace.Printf(" parentid[%d], name[%s], mode[%d], fi[%+v] \n", parentid, name, mode, fi)
 
@@ -282,16 +381,16 @@ var create = func(req fuse.Req, parentid uint64, name string, mode uint32, fi *f
 
 	modeStr := util.ModeToStr(mode)
 
-	err := hadoopControler.Create(filePath, modeStr)
+	err = hadoopControler.Create(ctx, remotePath(filePath), modeStr)
 
 	if err != nil {
-		panic(err)
+		return nil, toErrno(err)
 	}
 
-	file, err := hadoopControler.GetFileStatus(filePath)
+	file, err := hadoopControler.GetFileStatus(ctx, remotePath(filePath))
 
 	if err != nil {
-		panic(err)
+		return nil, toErrno(err)
 	}
 
 	stat = &fuse.FileStat{}
@@ -313,7 +412,12 @@ var create = func(req fuse.Req, parentid uint64, name string, mode uint32, fi *f
 
 var setattr = func(req fuse.Req, nodeid uint64, attr fuse.FileStat, toSet uint32) (result int32) {
 
-	defer recoverError(&result)
+	ctx, done := requestManager.Begin(req)
+	defer done()
+	ctx, err := proxyUsers.WithCaller(ctx, req)
+	if err != nil {
+		return toErrno(err)
+	}
 
 	filepath := pathManager.Get(nodeid)
 
@@ -340,9 +444,9 @@ var setattr = func(req fuse.Req, nodeid uint64, attr fuse.FileStat, toSet uint32
 	if atime > 0 || mtime > 0 {
 		logger.Trace.Printf("atime[%d], mtime[%d] \n", atime, mtime)
 
-		err := hadoopControler.ModificationTime(filepath, atime, mtime)
+		err := hadoopControler.ModificationTime(ctx, remotePath(filepath), atime, mtime)
 		if err != nil {
-			panic(err)
+			return toErrno(err)
 		}
 
 	}
@@ -351,54 +455,70 @@ var setattr = func(req fuse.Req, nodeid uint64, attr fuse.FileStat, toSet uint32
 		// 设置文件的permission
 
 		modeStr := util.ModeToStr(attr.Stat.Mode)
-		err := hadoopControler.SetPermission(filepath, modeStr)
+		err := hadoopControler.SetPermission(ctx, remotePath(filepath), modeStr)
+		accessCache.Clear()
+
+		if err != nil {
+			return toErrno(err)
+		}
+	}
+
+	if toSet&(fuse.FuseSetAttrUid|fuse.FuseSetAttrGid) > 0 {
+		// 设置文件的owner和group
+
+		err := chown(ctx, remotePath(filepath), attr.Stat.Uid, attr.Stat.Gid, toSet)
+		accessCache.Clear()
 
 		if err != nil {
-			panic(err)
+			return toErrno(err)
 		}
 	}
 
 	// 由于hadoopControler中没有ctime所以忽略
-	// 忽略UID, GID，因为由启动的参数决定的
 
 	return errno.SUCCESS
 }
 
 var write = func(req fuse.Req, nodeid uint64, buf []byte, offset uint64, fi fuse.FileInfo) (size uint32, result int32) {
 
-	defer recoverError(&result)
+	ctx, done := requestManager.Begin(req)
+	defer done()
+	ctx, err := proxyUsers.WithCaller(ctx, req)
+	if err != nil {
+		return 0, toErrno(err)
+	}
 
 	filepath := pathManager.Get(nodeid)
 
 	logger.Trace.Printf("nodeid[%d], filepath[%s], buf[%s], offset[%d], fi[%+v]\n", nodeid, filepath, buf, offset, fi)
 
-	file, err := hadoopControler.GetFileStatus(filepath)
+	file, err := hadoopControler.GetFileStatus(ctx, remotePath(filepath))
 
 	if err != nil {
-		panic(err)
+		return 0, toErrno(err)
 	}
 
 	file.AdjustNormal()
 
 	if offset == uint64(file.StSize) {
 		// 直接追加
-		err = hadoopControler.AppendFile(filepath, buf)
+		err = hadoopControler.AppendFile(ctx, remotePath(filepath), buf)
 	} else {
 		// 先Truncate到offset的位置，再追加
 		success := false
-		success, err = hadoopControler.TruncateFile(filepath, int64(offset))
+		success, err = hadoopControler.TruncateFile(ctx, remotePath(filepath), int64(offset))
 
 		if err != nil {
-			panic(err)
+			return 0, toErrno(err)
 		} else if !success {
-			panic(herr.ErrAccess)
+			return 0, errno.EACCES
 		} else {
-			err = hadoopControler.AppendFile(filepath, buf)
+			err = hadoopControler.AppendFile(ctx, remotePath(filepath), buf)
 		}
 	}
 
 	if err != nil {
-		panic(err)
+		return 0, toErrno(err)
 	}
 
 	size = uint32(len(buf))
@@ -407,7 +527,13 @@ var write = func(req fuse.Req, nodeid uint64, buf []byte, offset uint64, fi fuse
 }
 
 func _rmFileOrDir(req fuse.Req, parentid uint64, name string) (result int32) {
-	defer recoverError(&result)
+
+	ctx, done := requestManager.Begin(req)
+	defer done()
+	ctx, err := proxyUsers.WithCaller(ctx, req)
+	if err != nil {
+		return toErrno(err)
+	}
 
 	parentPath := pathManager.Get(parentid)
 
@@ -415,20 +541,21 @@ func _rmFileOrDir(req fuse.Req, parentid uint64, name string) (result int32) {
 
 	filePath := util.MergePath(parentPath, name)
 
-	file, err := hadoopControler.GetFileStatus(filePath)
+	file, err := hadoopControler.GetFileStatus(ctx, remotePath(filePath))
 	if err != nil {
-		panic(err)
+		return toErrno(err)
 	}
 	file.AdjustNormal()
 
-	success, err := hadoopControler.Delete(filePath)
+	success, err := hadoopControler.Delete(ctx, remotePath(filePath))
 	if err != nil {
-		panic(err)
+		return toErrno(err)
 	} else if !success {
-		panic(herr.ErrAccess)
+		return errno.EACCES
 	}
 
 	pathManager.Del(uint64(file.StIno))
+	accessCache.Clear()
 
 	return errno.SUCCESS
 }
@@ -446,7 +573,12 @@ var rmdir = func(req fuse.Req, parentid uint64, name string) (result int32) {
 // 重命名文件
 var rename = func(req fuse.Req, parentid uint64, name string, newparentid uint64, newname string) (result int32) {
 
-	defer recoverError(&result)
+	ctx, done := requestManager.Begin(req)
+	defer done()
+	ctx, err := proxyUsers.WithCaller(ctx, req)
+	if err != nil {
+		return toErrno(err)
+	}
 
 	parentPath := pathManager.Get(parentid)
 	newParentPath := pathManager.Get(newparentid)
@@ -457,24 +589,24 @@ var rename = func(req fuse.Req, parentid uint64, name string, newparentid uint64
 	newFilePath := util.MergePath(newParentPath, newname)
 
 	// 获取文件信息
-	file, err := hadoopControler.GetFileStatus(filePath)
+	file, err := hadoopControler.GetFileStatus(ctx, remotePath(filePath))
 	if err != nil {
-		panic(err)
+		return toErrno(err)
 	}
 	file.AdjustNormal()
 
 	// Rename 文件
-	success, err := hadoopControler.Rename(filePath, newFilePath)
+	success, err := hadoopControler.Rename(ctx, remotePath(filePath), remotePath(newFilePath))
 	if err != nil {
-		panic(err)
+		return toErrno(err)
 	} else if !success {
-		panic(herr.ErrAccess)
+		return errno.EACCES
 	}
 
 	// 获取Rename后文件的信息
-	newfile, err := hadoopControler.GetFileStatus(newFilePath)
+	newfile, err := hadoopControler.GetFileStatus(ctx, remotePath(newFilePath))
 	if err != nil {
-		panic(err)
+		return toErrno(err)
 	}
 	newfile.AdjustNormal()
 
@@ -482,6 +614,7 @@ var rename = func(req fuse.Req, parentid uint64, name string, newparentid uint64
 	pathManager.Del(uint64(file.StIno))
 	pathManager.Set(uint64(newfile.StIno), newFilePath)
 	notExistManager.Del(newFilePath)
+	accessCache.Clear()
 
 	return errno.SUCCESS
 }
@@ -489,12 +622,28 @@ var rename = func(req fuse.Req, parentid uint64, name string, newparentid uint64
 // 设置文件额外属性
 var setxattr = func(req fuse.Req, nodeid uint64, name string, value string, flags uint32) (result int32) {
 
-	defer recoverError(&result)
+	ctx, done := requestManager.Begin(req)
+	defer done()
+	ctx, err := proxyUsers.WithCaller(ctx, req)
+	if err != nil {
+		return toErrno(err)
+	}
 
 	filepath := pathManager.Get(nodeid)
 
 	logger.Trace.Printf("setxattr: nodeid[%d], filepath[%s], name[%s], value[%s], flags[%d]\n", nodeid, filepath, name, value, flags)
 
+	if isAclXattr(name) {
+		// ACL通过SETACL修改，会改变权限位
+		err := setAclXattr(ctx, remotePath(filepath), name, value)
+		accessCache.Clear()
+
+		if err != nil {
+			return toErrno(err)
+		}
+		return errno.SUCCESS
+	}
+
 	strFlag := "CREATE"
 
 	switch flags {
@@ -504,18 +653,14 @@ var setxattr = func(req fuse.Req, nodeid uint64, name string, value string, flag
 		strFlag = "REPLACE"
 	}
 
-	err := hadoopControler.Setxattr(filepath, name, value, strFlag)
+	err = hadoopControler.Setxattr(ctx, remotePath(filepath), name, value, strFlag)
 
+	if flags == 0 && errors.Is(err, herr.ErrExist) {
+		// 没有指定flag时，Xattr已经存在要用replace
+		err = hadoopControler.Setxattr(ctx, remotePath(filepath), name, value, "REPLACE")
+	}
 	if err != nil {
-		if err == herr.ErrExist {
-			// Xattr已经存在要用replace
-			err = hadoopControler.Setxattr(filepath, name, value, "REPLACE")
-			if err != nil {
-				panic(err)
-			}
-		} else {
-			panic(err)
-		}
+		return toErrno(err)
 	}
 
 	return errno.SUCCESS
@@ -524,34 +669,57 @@ var setxattr = func(req fuse.Req, nodeid uint64, name string, value string, flag
 // 获取指定名字的文件额外属性值
 var getxattr = func(req fuse.Req, nodeid uint64, name string, size uint32) (value string, result int32) {
 
-	defer recoverError(&result)
+	ctx, done := requestManager.Begin(req)
+	defer done()
+	ctx, err := proxyUsers.WithCaller(ctx, req)
+	if err != nil {
+		return "", toErrno(err)
+	}
 
 	filepath := pathManager.Get(nodeid)
 	logger.Trace.Printf("getxattr: nodeid[%d], filepath[%s], name[%s], size[%d]\n", nodeid, filepath, name, size)
 
-	value, err := hadoopControler.Getxattr(filepath, name)
+	if isAclXattr(name) {
+		value, err = getAclXattr(ctx, remotePath(filepath), name)
+	} else {
+		value, err = hadoopControler.Getxattr(ctx, remotePath(filepath), name)
+	}
 
 	if err != nil {
-		panic(err)
+		return "", toErrno(err)
 	}
 
 	if size > 0 && uint32(len(value)) > size {
-		panic(herr.ErrRange)
+		return "", errno.ERANGE
 	}
 
 	return value, errno.SUCCESS
 }
 
 var listxattr = func(req fuse.Req, nodeid uint64, size uint32) (list string, result int32) {
-	defer recoverError(&result)
+
+	ctx, done := requestManager.Begin(req)
+	defer done()
+	ctx, err := proxyUsers.WithCaller(ctx, req)
+	if err != nil {
+		return "", toErrno(err)
+	}
 
 	filepath := pathManager.Get(nodeid)
 	logger.Trace.Printf("listxattr: nodeid[%d], filepath[%s],  size[%d]\n", nodeid, filepath, size)
 
-	attrs, err := hadoopControler.Listxattr(filepath)
+	attrs, err := hadoopControler.Listxattr(ctx, remotePath(filepath))
 
 	if err != nil {
-		panic(err)
+		return "", toErrno(err)
+	}
+
+	acls, err := listAclXattrs(ctx, remotePath(filepath))
+	if err != nil {
+		return "", toErrno(err)
+	}
+	for _, name := range acls {
+		attrs = append(attrs, controler.Xattr{Name: name})
 	}
 
 	buf := bytes.NewBuffer(nil)
@@ -566,47 +734,67 @@ var listxattr = func(req fuse.Req, nodeid uint64, size uint32) (list string, res
 	list = buf.String()
 
 	if size > 0 && uint32(len(list)) > size {
-		panic(herr.ErrRange)
+		return "", errno.ERANGE
 	}
 
 	return list, errno.SUCCESS
 }
 
 var removexattr = func(req fuse.Req, nodeid uint64, name string) (result int32) {
-	defer recoverError(&result)
+
+	ctx, done := requestManager.Begin(req)
+	defer done()
+	ctx, err := proxyUsers.WithCaller(ctx, req)
+	if err != nil {
+		return toErrno(err)
+	}
 
 	filepath := pathManager.Get(nodeid)
 	logger.Trace.Printf("removexattr: nodeid[%d], filepath[%s],  name[%s]\n", nodeid, filepath, name)
 
-	err := hadoopControler.Removexattr(filepath, name)
+	if isAclXattr(name) {
+		err = removeAclXattr(ctx, remotePath(filepath), name)
+		accessCache.Clear()
+	} else {
+		err = hadoopControler.Removexattr(ctx, remotePath(filepath), name)
+	}
 
 	if err != nil {
-		panic(err)
+		return toErrno(err)
 	}
 
 	return errno.SUCCESS
 }
 
-// hadoopControler不支持
+// 创建软连接，HDFS默认不支持软连接(HDFS-4559)，开启 symlink_emulation 时才注册，由 controler.SymlinkEmulation 模拟
 var symlink = func(req fuse.Req, parentid uint64, link string, name string) (stat *fuse.FileStat, result int32) {
 
-	defer recoverError(&result)
+	ctx, done := requestManager.Begin(req)
+	defer done()
+	ctx, err := proxyUsers.WithCaller(ctx, req)
+	if err != nil {
+		return nil, toErrno(err)
+	}
 
 	parentPath := pathManager.Get(parentid)
 
 	logger.Trace.Printf("symlink: parentid[%d], parentPath[%s], link[%s], name[%s]\n", parentid, parentPath, link, name)
 
-	srcPath := util.MergePath(parentPath, link)
+	if parentPath == "" {
+		// 父目录不在路径缓存中
+		return nil, errno.ENOENT
+	}
+
 	symlinkPath := util.MergePath(parentPath, name)
 
-	err := hadoopControler.CreateSymlink(srcPath, symlinkPath)
+	err = hadoopControler.CreateSymlink(ctx, link, remotePath(symlinkPath))
 	if err != nil {
-		panic(err)
+		return nil, toErrno(err)
 	}
 
-	symlinkFile, err := hadoopControler.GetFileStatus(symlinkPath)
+	symlinkFile, err := hadoopControler.GetFileStatus(ctx, remotePath(symlinkPath))
 	if err != nil {
-		panic(err)
+		return nil, toErrno(err)
 	}
 	symlinkFile.AdjustNormal()
 
@@ -618,5 +806,48 @@ var symlink = func(req fuse.Req, parentid uint64, link string, name string) (sta
 	// 加入到路径的缓存
 	pathManager.Set(stat.Nodeid, symlinkPath)
 
+	// 删除不存在文件缓存
+	notExistManager.Del(symlinkPath)
+
 	return stat, errno.SUCCESS
 }
+
+// 读取软连接的目标，包括HDFS的软连接和模拟的软连接
+var readlink = func(req fuse.Req, nodeid uint64) (target string, result int32) {
+
+	ctx, done := requestManager.Begin(req)
+	defer done()
+	ctx, err := proxyUsers.WithCaller(ctx, req)
+	if err != nil {
+		return "", toErrno(err)
+	}
+
+	filepath := pathManager.Get(nodeid)
+
+	logger.Trace.Printf("readlink: nodeid[%d], filepath[%s]\n", nodeid, filepath)
+
+	if filepath == "" {
+		// 文件不在路径缓存中
+		return "", errno.ENOENT
+	}
+
+	file, err := hadoopControler.GetFileStatus(ctx, remotePath(filepath))
+	if err != nil {
+		return "", toErrno(err)
+	}
+	file.AdjustNormal()
+
+	if file.FileType != model.TypeSymlink {
+		return "", errno.EINVAL
+	}
+
+	return file.Symlink, errno.SUCCESS
+}
+
+// 取消还在处理中的请求
+var interrupt = func(req fuse.Req, unique uint64) {
+
+	logger.Trace.Printf("interrupt: unique[%d]\n", unique)
+
+	requestManager.Interrupt(unique)
+}
@@ -0,0 +1,48 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hadoop-fs/fs/controler"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"testing"
+
+	"github.com/mingforpc/fuse-go/fuse/errno"
+)
+
+func TestToErrno(t *testing.T) {
+	remote := func(javaClassName string) error {
+		return controler.HadoopException{RemoteException: controler.RemoteException{JavaClassName: javaClassName}}
+	}
+
+	cases := []struct {
+		err  error
+		want int32
+	}{
+		{herr.ErrNoFound, errno.ENOENT},
+		{herr.ErrAccess, errno.EACCES},
+		{herr.ErrAuth, errno.EACCES},
+		{herr.ErrExist, errno.EEXIST},
+		{fmt.Errorf("rename: %w", herr.ErrCrossDevice), errno.EXDEV},
+		{remote("java.io.FileNotFoundException"), errno.ENOENT},
+		{remote("org.apache.hadoop.security.AccessControlException"), errno.EACCES},
+		{remote("org.apache.hadoop.fs.FileAlreadyExistsException"), errno.EEXIST},
+		{remote("org.apache.hadoop.fs.ParentNotDirectoryException"), errno.ENOTDIR},
+		{remote("org.apache.hadoop.fs.PathIsNotEmptyDirectoryException"), errno.ENOTEMPTY},
+		{remote("org.apache.hadoop.hdfs.protocol.DSQuotaExceededException"), errno.EDQUOT},
+		{remote("org.apache.hadoop.hdfs.server.namenode.SafeModeException"), errno.EROFS},
+		{remote("org.apache.hadoop.hdfs.server.namenode.LeaseExpiredException"), errno.EIO},
+		{remote("java.lang.UnsupportedOperationException"), errno.ENOTSUP},
+		{remote("java.io.IOException"), errno.EIO},
+		{fmt.Errorf("list: %w", context.Canceled), errno.EINTR},
+		{context.DeadlineExceeded, errno.ETIMEDOUT},
+		{errors.New("connection refused"), errno.EIO},
+	}
+
+	for _, c := range cases {
+		if got := toErrno(c.err); got != c.want {
+			t.Errorf("toErrno(%v) = %d, want %d", c.err, got, c.want)
+		}
+	}
+}
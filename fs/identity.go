@@ -0,0 +1,117 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"os/user"
+	"strconv"
+
+	"github.com/mingforpc/fuse-go/fuse"
+)
+
+// Identities 把chown/chgrp的uid、gid转换成HDFS的用户和组
+type Identities struct {
+	// users, groups 配置的映射，没有配置的id使用本地的用户名和组名
+	users  map[uint32]string
+	groups map[uint32]string
+
+	// lookupUser, lookupGroup 根据id查询本地的名字
+	lookupUser  func(uid uint32) (string, error)
+	lookupGroup func(gid uint32) (string, error)
+}
+
+// Init 初始化，复制 users 和 groups
+func (ids *Identities) Init(users, groups map[uint32]string) {
+
+	ids.users = make(map[uint32]string, len(users))
+	for uid, name := range users {
+		ids.users[uid] = name
+	}
+
+	ids.groups = make(map[uint32]string, len(groups))
+	for gid, name := range groups {
+		ids.groups[gid] = name
+	}
+
+	ids.lookupUser = lookupUsername
+	ids.lookupGroup = lookupGroupname
+}
+
+// lookupGroupname 查询gid在本地的组名
+func lookupGroupname(gid uint32) (string, error) {
+	g, err := user.LookupGroupId(strconv.FormatUint(uint64(gid), 10))
+	if err != nil {
+		return "", err
+	}
+	return g.Name, nil
+}
+
+// User 返回uid对应的HDFS用户，无法转换时返回 herr.ErrPerm
+func (ids *Identities) User(uid uint32) (string, error) {
+
+	if name, ok := ids.users[uid]; ok {
+		return name, nil
+	}
+
+	name, err := ids.lookupUser(uid)
+	if err != nil {
+		return "", fmt.Errorf("uid [%d] has no HDFS user: %v: %w", uid, err, herr.ErrPerm)
+	}
+	return name, nil
+}
+
+// Group 返回gid对应的HDFS组，无法转换时返回 herr.ErrPerm
+func (ids *Identities) Group(gid uint32) (string, error) {
+
+	if name, ok := ids.groups[gid]; ok {
+		return name, nil
+	}
+
+	name, err := ids.lookupGroup(gid)
+	if err != nil {
+		return "", fmt.Errorf("gid [%d] has no HDFS group: %v: %w", gid, err, herr.ErrPerm)
+	}
+	return name, nil
+}
+
+// chown 按 toSet 中的 FuseSetAttrUid、FuseSetAttrGid 修改文件的owner和group。
+// 没有变化的不发送，使非超级用户的 chown 自己:组 也可以成功。HDFS拒绝时返回 herr.ErrPerm
+func chown(ctx context.Context, filepath string, uid, gid uint32, toSet uint32) error {
+
+	var owner, group string
+	var err error
+
+	if toSet&fuse.FuseSetAttrUid > 0 {
+		if owner, err = identities.User(uid); err != nil {
+			return err
+		}
+	}
+	if toSet&fuse.FuseSetAttrGid > 0 {
+		if group, err = identities.Group(gid); err != nil {
+			return err
+		}
+	}
+
+	file, err := hadoopControler.GetFileStatus(ctx, filepath)
+	if err != nil {
+		return err
+	}
+	if owner == file.HadoopOwner {
+		owner = ""
+	}
+	if group == file.HadoopGroup {
+		group = ""
+	}
+	if owner == "" && group == "" {
+		return nil
+	}
+
+	err = hadoopControler.SetOwner(ctx, filepath, owner, group)
+	if errors.Is(err, herr.ErrAccess) {
+		// chown的权限错误是EPERM，而不是EACCES
+		return fmt.Errorf("%v: %w", err, herr.ErrPerm)
+	}
+	return err
+}
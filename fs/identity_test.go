@@ -0,0 +1,114 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"hadoop-fs/fs/model"
+	"os/user"
+	"strconv"
+	"testing"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+)
+
+func TestIdentities(t *testing.T) {
+	ids := Identities{}
+	ids.Init(map[uint32]string{1000: "alice"}, map[uint32]string{100: "analysts"})
+	ids.lookupUser = func(uid uint32) (string, error) {
+		if uid == 1001 {
+			return "bob", nil
+		}
+		return "", errors.New("unknown uid")
+	}
+	ids.lookupGroup = func(gid uint32) (string, error) {
+		return "", errors.New("unknown gid")
+	}
+
+	if name, err := ids.User(1000); err != nil || name != "alice" {
+		t.Errorf("User(1000) = %q, %v", name, err)
+	}
+	if name, err := ids.User(1001); err != nil || name != "bob" {
+		t.Errorf("User(1001) should fall back to the local user: %q, %v", name, err)
+	}
+	if _, err := ids.User(1002); !errors.Is(err, herr.ErrPerm) {
+		t.Errorf("User(1002): got %v, want ErrPerm", err)
+	}
+	if name, err := ids.Group(100); err != nil || name != "analysts" {
+		t.Errorf("Group(100) = %q, %v", name, err)
+	}
+	if _, err := ids.Group(101); !errors.Is(err, herr.ErrPerm) {
+		t.Errorf("Group(101): got %v, want ErrPerm", err)
+	}
+}
+
+func TestSetattrChown(t *testing.T) {
+	memory := newRemoteRootBackend(t)
+	ctx := context.Background()
+
+	pathManager.Init()
+	pathManager.Set(2, "/user/alice/file")
+	requestManager.Init()
+	accessCache.Init(5)
+	identities.Init(map[uint32]string{1000: "alice", 1001: "bob"}, map[uint32]string{100: "analysts"})
+
+	attr := fuse.FileStat{}
+	attr.Stat.Uid = 1001
+	attr.Stat.Gid = 100
+	if result := setattr(fuse.Req{}, 2, attr, fuse.FuseSetAttrUid|fuse.FuseSetAttrGid); result != errno.SUCCESS {
+		t.Fatalf("chown bob:analysts: got %d", result)
+	}
+	file, _ := memory.GetFileStatus(ctx, "/user/alice/file")
+	if file.HadoopOwner != "bob" || file.HadoopGroup != "analysts" {
+		t.Errorf("owner and group after chown: %s:%s", file.HadoopOwner, file.HadoopGroup)
+	}
+
+	attr.Stat.Gid = 4242
+	if result := setattr(fuse.Req{}, 2, attr, fuse.FuseSetAttrGid); result != errno.EPERM {
+		t.Errorf("chgrp to an unknown gid: got %d, want EPERM", result)
+	}
+
+	// 代理的用户不是超级用户，不能修改owner，但是不修改owner的chown可以成功
+	proxyUsers.Init(true, map[uint32]string{1000: "alice", 1001: "bob"}, nil)
+	t.Cleanup(func() { proxyUsers.Init(false, nil, nil) })
+
+	attr.Stat.Uid = 1000
+	if result := setattr(fuse.Req{Uid: 1001}, 2, attr, fuse.FuseSetAttrUid); result != errno.EPERM {
+		t.Errorf("chown by non-superuser: got %d, want EPERM", result)
+	}
+	attr.Stat.Uid = 1001
+	if result := setattr(fuse.Req{Uid: 1001}, 2, attr, fuse.FuseSetAttrUid); result != errno.SUCCESS {
+		t.Errorf("chown to the same owner by non-superuser: got %d", result)
+	}
+}
+
+func TestAdjustNormalOwnerGroup(t *testing.T) {
+	setTestIDMap(t)
+
+	// owner和group不同时gid来自group
+	file := model.FileModel{HadoopType: model.HadoopFile, HadoopPermission: "644", HadoopOwner: "bob", HadoopGroup: "analysts"}
+	file.AdjustNormal()
+	if file.StUID != 1001 || file.StGid != 100 {
+		t.Errorf("bob:analysts: got %d:%d, want 1001:100", file.StUID, file.StGid)
+	}
+
+	// 不在 gid_map 中的group使用本地的同名组，而不是与owner同名的组
+	daemon, err := user.LookupGroup("daemon")
+	if err != nil {
+		t.Skip("no local group daemon")
+	}
+	file = model.FileModel{HadoopType: model.HadoopFile, HadoopPermission: "644", HadoopOwner: "root", HadoopGroup: "daemon"}
+	file.AdjustNormal()
+	if gid := strconv.Itoa(int(file.StGid)); gid != daemon.Gid {
+		t.Errorf("root:daemon: got gid %s, want %s", gid, daemon.Gid)
+	}
+
+	// 本地也没有的group使用nogroup
+	nogroup, _ := model.LookupGID("nogroup")
+	file = model.FileModel{HadoopType: model.HadoopFile, HadoopPermission: "644", HadoopOwner: "root", HadoopGroup: "no-such-group-in-test"}
+	file.AdjustNormal()
+	if file.StGid != uint(nogroup) {
+		t.Errorf("unknown group: got gid %d, want %d", file.StGid, nogroup)
+	}
+}
@@ -0,0 +1,70 @@
+package fs
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mingforpc/fuse-go/fuse"
+)
+
+// 请求处理完成后才到达的INTERRUPT不会再被使用，超过这个数量时清空
+const maxPendingInterrupts = 1024
+
+// RequestManager 记录正在处理的FUSE请求，收到INTERRUPT时取消对应请求的context，
+// 使得还在等待WebHDFS响应的操作能尽快返回EINTR
+type RequestManager struct {
+	lock    sync.Mutex
+	running map[uint64]context.CancelFunc
+	// 在请求开始处理之前就收到的INTERRUPT
+	interrupted map[uint64]struct{}
+}
+
+// Init 初始化
+func (manager *RequestManager) Init() {
+	manager.running = make(map[uint64]context.CancelFunc)
+	manager.interrupted = make(map[uint64]struct{})
+}
+
+// Begin 开始处理一个请求，返回的done必须在请求处理完成后调用
+func (manager *RequestManager) Begin(req fuse.Req) (ctx context.Context, done func()) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+
+	if _, ok := manager.interrupted[req.Unique]; ok {
+		delete(manager.interrupted, req.Unique)
+		cancel()
+	} else {
+		manager.running[req.Unique] = cancel
+	}
+
+	done = func() {
+		manager.lock.Lock()
+		delete(manager.running, req.Unique)
+		manager.lock.Unlock()
+
+		cancel()
+	}
+
+	return ctx, done
+}
+
+// Interrupt 取消unique对应的请求，请求还没开始处理时先记录下来
+func (manager *RequestManager) Interrupt(unique uint64) {
+
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+
+	if cancel, ok := manager.running[unique]; ok {
+		delete(manager.running, unique)
+		cancel()
+		return
+	}
+
+	if len(manager.interrupted) >= maxPendingInterrupts {
+		manager.interrupted = make(map[uint64]struct{})
+	}
+	manager.interrupted[unique] = struct{}{}
+}
@@ -0,0 +1,159 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ACL项的类型
+const (
+	AclUser  = "user"
+	AclGroup = "group"
+	AclMask  = "mask"
+	AclOther = "other"
+)
+
+// aclDefaultPrefix default ACL项的前缀
+const aclDefaultPrefix = "default:"
+
+// AclEntry HDFS ACL中的一项，格式与 hdfs dfs -setfacl 一样，例如 default:user:alice:rwx
+type AclEntry struct {
+	Default bool
+	Type    string
+	// Name 为空表示文件的owner或者group
+	Name string
+	// Perm rwx 对应 4、2、1
+	Perm uint32
+}
+
+// ParseAclEntry 解析一个ACL项
+func ParseAclEntry(spec string) (AclEntry, error) {
+
+	entry := AclEntry{}
+
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, aclDefaultPrefix) {
+		entry.Default = true
+		spec = spec[len(aclDefaultPrefix):]
+	}
+
+	fields := strings.Split(spec, ":")
+	if len(fields) != 3 {
+		return entry, fmt.Errorf("invalid ACL entry [%s]", spec)
+	}
+
+	entry.Type, entry.Name = fields[0], fields[1]
+	switch entry.Type {
+	case AclUser, AclGroup:
+	case AclMask, AclOther:
+		if entry.Name != "" {
+			return entry, fmt.Errorf("invalid ACL entry [%s], %s can not have a name", spec, entry.Type)
+		}
+	default:
+		return entry, fmt.Errorf("invalid ACL entry [%s], unknown type [%s]", spec, entry.Type)
+	}
+
+	perm := fields[2]
+	if len(perm) != 3 || strings.Trim(perm, "rwx-") != "" {
+		return entry, fmt.Errorf("invalid ACL entry [%s], invalid permission [%s]", spec, perm)
+	}
+	for i, bit := range []uint32{4, 2, 1} {
+		if perm[i] != '-' {
+			entry.Perm |= bit
+		}
+	}
+
+	return entry, nil
+}
+
+// ParseAclSpec 解析逗号分隔的多个ACL项
+func ParseAclSpec(spec string) ([]AclEntry, error) {
+
+	entries := make([]AclEntry, 0)
+	for _, item := range strings.Split(spec, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		entry, err := ParseAclEntry(item)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// ParseAclRemoveSpec 解析删除ACL项时(REMOVEACLENTRIES)的aclspec，每一项没有权限，例如 user:alice
+func ParseAclRemoveSpec(spec string) ([]AclEntry, error) {
+
+	entries := make([]AclEntry, 0)
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		entry, err := ParseAclEntry(item + ":---")
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// PermString 权限位对应的 rwx 字符串
+func PermString(perm uint32) string {
+	buf := []byte("---")
+	for i, c := range "rwx" {
+		if perm&(4>>uint(i)) != 0 {
+			buf[i] = byte(c)
+		}
+	}
+	return string(buf)
+}
+
+func (entry AclEntry) String() string {
+	spec := entry.Type + ":" + entry.Name + ":" + PermString(entry.Perm)
+	if entry.Default {
+		spec = aclDefaultPrefix + spec
+	}
+	return spec
+}
+
+// AclSpec 把ACL项转换成逗号分隔的字符串
+func AclSpec(entries []AclEntry) string {
+	specs := make([]string, len(entries))
+	for i, entry := range entries {
+		specs[i] = entry.String()
+	}
+	return strings.Join(specs, ",")
+}
+
+// AclRemoveSpec 把ACL项转换成删除时(REMOVEACLENTRIES)的aclspec，不带权限
+func AclRemoveSpec(entries []AclEntry) string {
+	specs := make([]string, len(entries))
+	for i, entry := range entries {
+		specs[i] = entry.Type + ":" + entry.Name
+		if entry.Default {
+			specs[i] = aclDefaultPrefix + specs[i]
+		}
+	}
+	return strings.Join(specs, ",")
+}
+
+// SortAcl 按HDFS的顺序排序：access在default之前，按 user、group、mask、other，没有名字的在前，再按名字
+func SortAcl(entries []AclEntry) {
+	order := map[string]int{AclUser: 0, AclGroup: 1, AclMask: 2, AclOther: 3}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Default != b.Default {
+			return !a.Default
+		}
+		if a.Type != b.Type {
+			return order[a.Type] < order[b.Type]
+		}
+		return a.Name < b.Name
+	})
+}
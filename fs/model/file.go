@@ -16,6 +16,9 @@ const (
 	TypeSymlink = syscall.S_IFLNK
 )
 
+// permissionAclBit Hadoop 2.x 的 WebHDFS 在 permission 中表示文件有ACL的位
+const permissionAclBit = 1 << 12
+
 // Hadoop中的文件类型
 const (
 	HadoopDir     = "DIRECTORY"
@@ -23,12 +26,61 @@ const (
 	HadoopSymlink = "SYMLINK"
 )
 
+// uidMap, gidMap HDFS的用户、组对应的uid、gid，由 SetIDMap 在挂载前设置
+var (
+	uidMap = map[string]uint32{}
+	gidMap = map[string]uint32{}
+)
+
+// SetIDMap 设置uid、gid到HDFS用户、组的映射，AdjustNormal 优先按映射转换文件的owner和group。
+// 同一个名字对应多个id时使用最小的id
+func SetIDMap(users, groups map[uint32]string) {
+	uidMap = reverseIDMap(users)
+	gidMap = reverseIDMap(groups)
+}
+
+func reverseIDMap(ids map[uint32]string) map[string]uint32 {
+	names := make(map[string]uint32, len(ids))
+	for id, name := range ids {
+		if old, ok := names[name]; !ok || id < old {
+			names[name] = id
+		}
+	}
+	return names
+}
+
+// LookupUID 返回HDFS用户对应的uid，先使用 SetIDMap 的映射，再查询本地的同名用户
+func LookupUID(name string) (uint32, bool) {
+	if uid, ok := uidMap[name]; ok {
+		return uid, true
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return 0, false
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	return uint32(uid), err == nil
+}
+
+// LookupGID 返回HDFS组对应的gid，先使用 SetIDMap 的映射，再查询本地的同名组
+func LookupGID(name string) (uint32, bool) {
+	if gid, ok := gidMap[name]; ok {
+		return gid, true
+	}
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return 0, false
+	}
+	gid, err := strconv.ParseUint(g.Gid, 10, 32)
+	return uint32(gid), err == nil
+}
+
 // FileModel 保存文件信息的类
 type FileModel struct {
 	Name      string `json:"pathSuffix"`
 	FileType  int
 	StMode    uint
-	StIno     uint32 `json:"fileId"`
+	StIno     uint64 `json:"fileId"`
 	StDev     uint32
 	StRdev    uint32
 	StNlink   uint32
@@ -46,6 +98,10 @@ type FileModel struct {
 	HadoopType       string `json:"type"`
 	HadoopPermission string `json:"permission"`
 	ChildrenNum      int    `json:"childrenNum"`
+	// Symlink HDFS软连接的目标，模拟的软连接由 controler.SymlinkEmulation 设置
+	Symlink string `json:"symlink"`
+	// AclBit 文件有ACL，Hadoop 2.x 的 permission 中用 1<<12 表示
+	AclBit bool `json:"aclBit"`
 }
 
 // WriteToStat 将FileModel中的信息写入stat中
@@ -87,29 +143,40 @@ func (file *FileModel) AdjustNormal() {
 	case HadoopFile:
 		file.FileType = TypeFile
 		file.StNlink = 1
+		if file.Symlink != "" {
+			// 模拟的软连接，文件的长度就是目标的长度
+			file.FileType = TypeSymlink
+		}
 	case HadoopSymlink:
 		file.FileType = TypeSymlink
+		file.StNlink = 1
+		file.StSize = int64(len(file.Symlink))
 	}
 
 	file.StCtime = file.StMtime
 
 	mode, _ := strconv.ParseUint(file.HadoopPermission, 8, 16)
 
-	file.StMode = uint(mode)
+	if mode&permissionAclBit != 0 {
+		file.AclBit = true
+	}
+	file.StMode = uint(mode & 07777)
+	if file.FileType == TypeSymlink {
+		// 与Linux一样软连接的权限总是0777
+		file.StMode = 0777
+	}
 
 	// user, group
-	fileUser, err := user.Lookup(file.HadoopOwner)
-	if err != nil {
-		fileUser, _ = user.Lookup("nobody")
+	uid, ok := LookupUID(file.HadoopOwner)
+	if !ok {
+		uid, _ = LookupUID("nobody")
 	}
-	uid, _ := strconv.Atoi(fileUser.Uid)
 	file.StUID = uint(uid)
 
-	fileGroup, err := user.LookupGroup(file.HadoopOwner)
-	if err != nil {
-		fileGroup, _ = user.LookupGroup("nogroup")
+	gid, ok := LookupGID(file.HadoopGroup)
+	if !ok {
+		gid, _ = LookupGID("nogroup")
 	}
-	gid, _ := strconv.Atoi(fileGroup.Gid)
 	file.StGid = uint(gid)
 }
 
@@ -119,7 +186,8 @@ func (file *FileModel) ToFuseDirent() fuse.Dirent {
 
 	ent.Ino = uint64(file.StIno)
 	ent.NameLen = uint32(len(file.Name))
-	ent.DirType = uint32(file.StMode)
+	// DT_DIR、DT_REG、DT_LNK 等，与 IFTODT 一样由文件类型转换
+	ent.DirType = uint32(file.FileType) >> 12
 	ent.Name = file.Name
 
 	return ent
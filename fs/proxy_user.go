@@ -0,0 +1,140 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"hadoop-fs/fs/controler"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"hadoop-fs/fs/logger"
+	"os/user"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mingforpc/fuse-go/fuse"
+)
+
+// 查询本地用户失败的结果缓存的时间，之后重新查询，新添加的用户或者LDAP等暂时的故障不需要重新挂载
+const proxyUserFailureTTL = time.Minute
+
+// ProxyUsers 把FUSE请求调用者的uid转换成HDFS的用户，后端以这个用户的身份(doas)访问HDFS，
+// 使HDFS按真实的用户检查权限和设置文件的owner
+type ProxyUsers struct {
+	enabled bool
+
+	// mapping uid对应的HDFS用户，没有配置的uid使用本地的用户名
+	mapping map[uint32]string
+	// deny 不允许代理的uid或者用户名(本地的或者HDFS的)
+	deny map[string]struct{}
+
+	// lookup 根据uid查询本地的用户名
+	lookup func(uid uint32) (string, error)
+
+	lock  sync.Mutex
+	cache map[uint32]proxyUser
+}
+
+// proxyUser uid转换的结果，被拒绝时err不为空，expire 为零时一直有效
+type proxyUser struct {
+	name   string
+	err    error
+	expire time.Time
+}
+
+// Init 初始化，enabled 为false时所有请求都使用挂载的用户
+func (proxy *ProxyUsers) Init(enabled bool, mapping map[uint32]string, deny []string) {
+
+	proxy.enabled = enabled
+
+	proxy.mapping = make(map[uint32]string, len(mapping))
+	for uid, name := range mapping {
+		proxy.mapping[uid] = name
+	}
+
+	proxy.deny = make(map[string]struct{}, len(deny))
+	for _, name := range deny {
+		proxy.deny[name] = struct{}{}
+	}
+
+	proxy.lookup = lookupUsername
+	proxy.cache = make(map[uint32]proxyUser)
+}
+
+// lookupUsername 查询uid在本地的用户名
+func lookupUsername(uid uint32) (string, error) {
+	u, err := user.LookupId(strconv.FormatUint(uint64(uid), 10))
+	if err != nil {
+		return "", err
+	}
+	return u.Username, nil
+}
+
+// Resolve 返回uid对应的HDFS用户，uid或者用户在deny中、无法查到用户名时返回 herr.ErrAccess
+func (proxy *ProxyUsers) Resolve(uid uint32) (string, error) {
+
+	proxy.lock.Lock()
+	defer proxy.lock.Unlock()
+
+	if cached, ok := proxy.cache[uid]; ok && (cached.expire.IsZero() || time.Now().Before(cached.expire)) {
+		return cached.name, cached.err
+	}
+
+	name, err := proxy.resolve(uid)
+	cached := proxyUser{name: name, err: err}
+	if err != nil {
+		logger.Warning.Printf("proxy user: %v\n", err)
+		cached.expire = time.Now().Add(proxyUserFailureTTL)
+	} else {
+		logger.Info.Printf("proxy user: uid [%d] access HDFS as [%s]\n", uid, name)
+	}
+
+	// 本地用户很少变化，成功的结果一直缓存
+	proxy.cache[uid] = cached
+
+	return name, err
+}
+
+func (proxy *ProxyUsers) resolve(uid uint32) (string, error) {
+
+	if proxy.denied(strconv.FormatUint(uint64(uid), 10)) {
+		return "", fmt.Errorf("uid [%d] is denied: %w", uid, herr.ErrAccess)
+	}
+
+	name, ok := proxy.mapping[uid]
+	if !ok {
+		local, err := proxy.lookup(uid)
+		if err != nil {
+			return "", fmt.Errorf("uid [%d] has no local user: %v: %w", uid, err, herr.ErrAccess)
+		}
+		if proxy.denied(local) {
+			return "", fmt.Errorf("user [%s] is denied: %w", local, herr.ErrAccess)
+		}
+		name = local
+	}
+
+	if proxy.denied(name) {
+		return "", fmt.Errorf("HDFS user [%s] of uid [%d] is denied: %w", name, uid, herr.ErrAccess)
+	}
+
+	return name, nil
+}
+
+func (proxy *ProxyUsers) denied(name string) bool {
+	_, ok := proxy.deny[name]
+	return ok
+}
+
+// WithCaller 返回以请求调用者身份访问HDFS的ctx，调用者不允许代理时返回的错误对应EACCES
+func (proxy *ProxyUsers) WithCaller(ctx context.Context, req fuse.Req) (context.Context, error) {
+
+	if !proxy.enabled {
+		return ctx, nil
+	}
+
+	name, err := proxy.Resolve(req.Uid)
+	if err != nil {
+		return ctx, err
+	}
+
+	return controler.WithProxyUser(ctx, name), nil
+}
@@ -0,0 +1,109 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"testing"
+	"time"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+)
+
+func newTestProxyUsers(mapping map[uint32]string, deny []string) (*ProxyUsers, map[uint32]int) {
+	lookups := make(map[uint32]int)
+	local := map[uint32]string{0: "root", 1000: "alice", 1001: "bob", 1002: "hdfs"}
+
+	proxy := &ProxyUsers{}
+	proxy.Init(true, mapping, deny)
+	proxy.lookup = func(uid uint32) (string, error) {
+		lookups[uid]++
+		if name, ok := local[uid]; ok {
+			return name, nil
+		}
+		return "", errors.New("unknown userid")
+	}
+
+	return proxy, lookups
+}
+
+func TestProxyUsersResolve(t *testing.T) {
+	proxy, lookups := newTestProxyUsers(map[uint32]string{1001: "robert", 2000: "carol"}, []string{"0", "hdfs"})
+
+	cases := []struct {
+		uid  uint32
+		want string
+	}{
+		{1000, "alice"},  // 本地用户名
+		{1001, "robert"}, // 配置的映射优先
+		{2000, "carol"},  // 没有本地用户也可以映射
+		{0, ""},          // uid被拒绝
+		{1002, ""},       // 用户名被拒绝
+		{3000, ""},       // 没有本地用户
+	}
+
+	for _, c := range cases {
+		name, err := proxy.Resolve(c.uid)
+		if c.want == "" {
+			if !errors.Is(err, herr.ErrAccess) {
+				t.Errorf("Resolve(%d): got %q, err %v, want ErrAccess", c.uid, name, err)
+			}
+			continue
+		}
+		if err != nil || name != c.want {
+			t.Errorf("Resolve(%d): got %q, err %v, want %q", c.uid, name, err, c.want)
+		}
+	}
+
+	// 结果会被缓存
+	proxy.Resolve(1000)
+	proxy.Resolve(3000)
+	if lookups[1000] != 1 || lookups[3000] != 1 {
+		t.Errorf("lookups = %v, want each uid looked up once", lookups)
+	}
+
+	// 失败的结果过期后重新查询
+	entry := proxy.cache[3000]
+	entry.expire = time.Now().Add(-time.Second)
+	proxy.cache[3000] = entry
+	lookup := proxy.lookup
+	proxy.lookup = func(uid uint32) (string, error) {
+		if uid == 3000 {
+			return "dave", nil
+		}
+		return lookup(uid)
+	}
+	if name, err := proxy.Resolve(3000); err != nil || name != "dave" {
+		t.Errorf("Resolve(3000) after the failure expired: got %q, err %v, want dave", name, err)
+	}
+	if !proxy.cache[1000].expire.IsZero() {
+		t.Errorf("successful lookup should not expire")
+	}
+}
+
+func TestProxyUsersWithCaller(t *testing.T) {
+	ctx := context.Background()
+
+	disabled := &ProxyUsers{}
+	disabled.Init(false, nil, nil)
+	if got, err := disabled.WithCaller(ctx, fuse.Req{Uid: 1000}); got != ctx || err != nil {
+		t.Errorf("WithCaller: disabled proxy user should not change ctx")
+	}
+
+	proxy, _ := newTestProxyUsers(nil, []string{"0"})
+
+	// 被拒绝时返回的错误对应EACCES
+	call := func(uid uint32) int32 {
+		if _, err := proxy.WithCaller(ctx, fuse.Req{Uid: uid}); err != nil {
+			return toErrno(err)
+		}
+		return errno.SUCCESS
+	}
+	if got := call(1000); got != errno.SUCCESS {
+		t.Errorf("WithCaller(1000) = %d, want SUCCESS", got)
+	}
+	if got := call(0); got != errno.EACCES {
+		t.Errorf("WithCaller(0) = %d, want EACCES", got)
+	}
+}
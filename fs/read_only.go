@@ -0,0 +1,83 @@
+package fs
+
+import (
+	"syscall"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+)
+
+// readOnly 只读挂载：所有会修改HDFS的操作都直接返回EROFS，不会发送到后端。
+// 内核也会以 ro 挂载(见 mountOptions)
+func readOnly(opts *fuse.Opt) {
+
+	opts.Open = &readOnlyOpen
+	opts.Create = &readOnlyCreate
+	opts.Write = &readOnlyWrite
+	opts.Mkdir = &readOnlyMkdir
+	opts.Unlink = &readOnlyUnlink
+	opts.Rmdir = &readOnlyRmdir
+	opts.Rename = &readOnlyRename
+	opts.Setattr = &readOnlySetattr
+	opts.Setxattr = &readOnlySetxattr
+	opts.Removexattr = &readOnlyRemovexattr
+	opts.Symlink = &readOnlySymlink
+}
+
+// mountOptions 传给内核的挂载参数
+func mountOptions(ro bool) []string {
+	if ro {
+		return []string{"ro"}
+	}
+	return nil
+}
+
+// readOnlyOpen 只允许以只读方式打开
+var readOnlyOpen = func(req fuse.Req, nodeid uint64, fi *fuse.FileInfo) int32 {
+
+	if fi.Flags&syscall.O_ACCMODE != syscall.O_RDONLY || fi.Flags&syscall.O_TRUNC != 0 {
+		return errno.EROFS
+	}
+
+	return open(req, nodeid, fi)
+}
+
+var readOnlyCreate = func(req fuse.Req, parentid uint64, name string, mode uint32, fi *fuse.FileInfo) (*fuse.FileStat, int32) {
+	return nil, errno.EROFS
+}
+
+var readOnlyWrite = func(req fuse.Req, nodeid uint64, buf []byte, offset uint64, fi fuse.FileInfo) (uint32, int32) {
+	return 0, errno.EROFS
+}
+
+var readOnlyMkdir = func(req fuse.Req, parentid uint64, name string, mode uint32) (*fuse.FileStat, int32) {
+	return nil, errno.EROFS
+}
+
+var readOnlyUnlink = func(req fuse.Req, parentid uint64, name string) int32 {
+	return errno.EROFS
+}
+
+var readOnlyRmdir = func(req fuse.Req, parentid uint64, name string) int32 {
+	return errno.EROFS
+}
+
+var readOnlyRename = func(req fuse.Req, parentid uint64, name string, newparentid uint64, newname string) int32 {
+	return errno.EROFS
+}
+
+var readOnlySetattr = func(req fuse.Req, nodeid uint64, attr fuse.FileStat, toSet uint32) int32 {
+	return errno.EROFS
+}
+
+var readOnlySetxattr = func(req fuse.Req, nodeid uint64, name string, value string, flags uint32) int32 {
+	return errno.EROFS
+}
+
+var readOnlyRemovexattr = func(req fuse.Req, nodeid uint64, name string) int32 {
+	return errno.EROFS
+}
+
+var readOnlySymlink = func(req fuse.Req, parentid uint64, link string, name string) (*fuse.FileStat, int32) {
+	return nil, errno.EROFS
+}
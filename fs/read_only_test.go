@@ -0,0 +1,51 @@
+package fs
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+)
+
+func TestReadOnly(t *testing.T) {
+	opts := fuse.Opt{Getattr: &getattr, Read: &read}
+	readOnly(&opts)
+
+	req := fuse.Req{}
+	results := map[string]int32{}
+
+	_, results["create"] = (*opts.Create)(req, 1, "file", 0644, &fuse.FileInfo{})
+	_, results["write"] = (*opts.Write)(req, 2, []byte("data"), 0, fuse.FileInfo{})
+	_, results["mkdir"] = (*opts.Mkdir)(req, 1, "dir", 0755)
+	results["unlink"] = (*opts.Unlink)(req, 1, "file")
+	results["rmdir"] = (*opts.Rmdir)(req, 1, "dir")
+	results["rename"] = (*opts.Rename)(req, 1, "a", 1, "b")
+	results["setattr"] = (*opts.Setattr)(req, 2, fuse.FileStat{}, fuse.FuseSetAttrSize)
+	results["setxattr"] = (*opts.Setxattr)(req, 2, "user.a", "1", 0)
+	results["removexattr"] = (*opts.Removexattr)(req, 2, "user.a")
+	_, results["symlink"] = (*opts.Symlink)(req, 1, "target", "link")
+
+	for _, flags := range []int{syscall.O_WRONLY, syscall.O_RDWR, syscall.O_RDONLY | syscall.O_TRUNC} {
+		results["open"] = (*opts.Open)(req, 2, &fuse.FileInfo{Flags: uint32(flags)})
+		for op, result := range results {
+			if result != errno.EROFS {
+				t.Errorf("%s (open flags %#o): got %d, want EROFS", op, flags, result)
+			}
+		}
+	}
+
+	if result := (*opts.Open)(req, 2, &fuse.FileInfo{Flags: syscall.O_RDONLY}); result != errno.SUCCESS {
+		t.Errorf("open O_RDONLY: got %d", result)
+	}
+	if opts.Getattr != &getattr || opts.Read != &read {
+		t.Errorf("read operations should not be replaced")
+	}
+
+	if got := mountOptions(true); len(got) != 1 || got[0] != "ro" {
+		t.Errorf("mountOptions(true) = %v", got)
+	}
+	if got := mountOptions(false); len(got) != 0 {
+		t.Errorf("mountOptions(false) = %v", got)
+	}
+}
@@ -0,0 +1,100 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"hadoop-fs/fs/controler"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"hadoop-fs/fs/model"
+	"testing"
+
+	"github.com/mingforpc/fuse-go/fuse"
+)
+
+func newRemoteRootBackend(t *testing.T) *controler.MemoryController {
+	t.Helper()
+
+	memory := &controler.MemoryController{}
+	memory.Init("alice")
+
+	ctx := context.Background()
+	if _, err := memory.MakeDir(ctx, "/user/alice/data", "750"); err != nil {
+		t.Fatal(err)
+	}
+	if err := memory.Create(ctx, "/user/alice/file", "644"); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := hadoopControler
+	hadoopControler = memory
+	t.Cleanup(func() { hadoopControler = saved })
+
+	return memory
+}
+
+func TestResolveRemoteRoot(t *testing.T) {
+	newRemoteRootBackend(t)
+	ctx := context.Background()
+
+	cases := map[string]string{
+		"":                  "/",
+		"/":                 "/",
+		"/user/alice/data/": "/user/alice/data",
+		"~":                 "/user/alice",
+		"~/data":            "/user/alice/data",
+	}
+	for root, want := range cases {
+		if got, err := resolveRemoteRoot(ctx, root); err != nil || got != want {
+			t.Errorf("resolveRemoteRoot(%q): got %q, err %v, want %q", root, got, err, want)
+		}
+	}
+
+	if _, err := resolveRemoteRoot(ctx, "/missing"); !errors.Is(err, herr.ErrNoFound) {
+		t.Errorf("resolveRemoteRoot missing: got err %v, want ErrNoFound", err)
+	}
+	if _, err := resolveRemoteRoot(ctx, "~/file"); !errors.Is(err, herr.ErrNotDir) {
+		t.Errorf("resolveRemoteRoot file: got err %v, want ErrNotDir", err)
+	}
+	if _, err := resolveRemoteRoot(ctx, "relative"); err == nil {
+		t.Errorf("resolveRemoteRoot relative: expected error")
+	}
+}
+
+func TestRemotePath(t *testing.T) {
+	saved := remoteRoot
+	defer func() { remoteRoot = saved }()
+
+	remoteRoot = "/user/alice/data"
+	cases := map[string]string{
+		"/":        "/user/alice/data",
+		"/a/b":     "/user/alice/data/a/b",
+		"/a/../..": "/user/alice/data",
+		"":         "",
+	}
+	for local, want := range cases {
+		if got := remotePath(local); got != want {
+			t.Errorf("remotePath(%q) = %q, want %q", local, got, want)
+		}
+	}
+}
+
+func TestGetRootRemoteAttrs(t *testing.T) {
+	memory := newRemoteRootBackend(t)
+
+	root := controler.RootController{}
+	root.Init(memory, "/user/alice/data")
+
+	file, err := root.GetRoot(context.Background(), fuse.Req{})
+	if err != nil {
+		t.Fatalf("GetRoot: %v", err)
+	}
+	if file.StIno != 1 || file.FileType != model.TypeDir || file.StMode&0777 != 0750 {
+		t.Errorf("GetRoot: got ino %d, type %o, mode %o, want the attributes of the remote directory",
+			file.StIno, file.FileType, file.StMode)
+	}
+
+	root.Init(memory, "/missing")
+	if _, err = root.GetRoot(context.Background(), fuse.Req{}); !errors.Is(err, herr.ErrNoFound) {
+		t.Errorf("GetRoot of missing directory: got err %v, want ErrNoFound", err)
+	}
+}
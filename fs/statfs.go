@@ -0,0 +1,183 @@
+package fs
+
+import (
+	"errors"
+	"hadoop-fs/fs/controler"
+	herr "hadoop-fs/fs/controler/hadoop_error"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+)
+
+const (
+	// statfs 报告的块大小
+	statfsBlockSize = 4096
+	// 文件名的最大长度，与 dfs.namenode.fs-limits.max-component-length 的默认值一致
+	statfsNameLen = 255
+	// HDFS没有inode数量的限制，没有文件数配额时报告的文件数
+	statfsMaxFiles = 1 << 32
+	// 不知道集群的容量(不支持GETSTATUS)时报告的剩余空间，1PiB
+	statfsUnknownFree = 1 << 50
+)
+
+// StatfsCache 缓存statfs的结果，df 和写入前检查剩余空间的程序会频繁调用statfs
+type StatfsCache struct {
+	timeout time.Duration
+
+	lock    sync.Mutex
+	entries map[string]statfsEntry
+}
+
+type statfsEntry struct {
+	stat   fuse.Statfs
+	expire time.Time
+}
+
+// Init 初始化，timeout 为缓存的秒数，为0时不缓存
+func (cache *StatfsCache) Init(timeout int) {
+	cache.timeout = time.Duration(timeout) * time.Second
+	cache.entries = make(map[string]statfsEntry)
+}
+
+// Get 获取没有过期的结果
+func (cache *StatfsCache) Get(root string) (fuse.Statfs, bool) {
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	entry, ok := cache.entries[root]
+	if !ok || time.Now().After(entry.expire) {
+		return fuse.Statfs{}, false
+	}
+
+	return entry.stat, true
+}
+
+// Set 缓存结果
+func (cache *StatfsCache) Set(root string, stat fuse.Statfs) {
+
+	if cache.timeout <= 0 {
+		return
+	}
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	cache.entries[root] = statfsEntry{stat: stat, expire: time.Now().Add(cache.timeout)}
+}
+
+// statfsRoot statfs统计的HDFS目录：挂载的子目录，或者挂载表中的挂载点，为 "/" 时只使用集群的容量
+func statfsRoot(localPath string) string {
+
+	if remoteRoot != "/" {
+		return remoteRoot
+	}
+	backend := hadoopControler
+	if emulation, ok := backend.(*controler.SymlinkEmulation); ok {
+		backend = emulation.Backend
+	}
+	if table, ok := backend.(*controler.MountTable); ok {
+		return table.MountPoint(remotePath(localPath))
+	}
+
+	return "/"
+}
+
+// hasQuota 是否设置了配额，HDFS没有配额时为-1，根目录的文件数配额默认是 Long.MAX_VALUE
+func hasQuota(quota int64) bool {
+	return quota >= 0 && quota != math.MaxInt64
+}
+
+// newStatfs 根据集群的容量和目录的配额计算statfs：
+// 有空间配额时 f_blocks 是配额，剩余空间不超过集群的剩余空间，有文件数配额时 f_files 是配额。
+// 不知道集群的容量时(不支持GETSTATUS)剩余空间是 statfsUnknownFree，不能让 df 和写入前的检查认为没有空间。
+// 空间包括所有的副本，与 hdfs dfs -count -q 一致
+func newStatfs(status controler.FsStatus, usage *controler.QuotaUsage) *fuse.Statfs {
+
+	total, free := status.Capacity, status.Remaining
+	files, used := int64(statfsMaxFiles), int64(0)
+
+	if total == 0 {
+		total, free = statfsUnknownFree, statfsUnknownFree
+		if usage != nil {
+			total += usage.SpaceConsumed
+		}
+	}
+
+	if usage != nil {
+		if hasQuota(usage.SpaceQuota) {
+			quotaFree := usage.SpaceQuota - usage.SpaceConsumed
+			if quotaFree < free {
+				free = quotaFree
+			}
+			total = usage.SpaceQuota
+		}
+		if hasQuota(usage.Quota) {
+			files = usage.Quota
+		}
+		used = usage.FileAndDirectoryCount
+	}
+
+	if free < 0 {
+		free = 0
+	}
+	ffree := files - used
+	if ffree < 0 {
+		ffree = 0
+	}
+
+	return &fuse.Statfs{
+		Blocks:  uint64(total / statfsBlockSize),
+		Bfree:   uint64(free / statfsBlockSize),
+		Bavail:  uint64(free / statfsBlockSize),
+		Files:   uint64(files),
+		Ffree:   uint64(ffree),
+		Bsize:   statfsBlockSize,
+		Frsize:  statfsBlockSize,
+		NameLen: statfsNameLen,
+	}
+}
+
+var statfs = func(req fuse.Req, nodeid uint64) (stat *fuse.Statfs, result int32) {
+
+	ctx, done := requestManager.Begin(req)
+	defer done()
+	ctx, err := proxyUsers.WithCaller(ctx, req)
+	if err != nil {
+		return nil, toErrno(err)
+	}
+
+	path := pathManager.Get(nodeid)
+	if path == "" {
+		path = "/"
+	}
+	root := statfsRoot(path)
+
+	if cached, ok := statfsCache.Get(root); ok {
+		return &cached, errno.SUCCESS
+	}
+
+	status, err := hadoopControler.GetStatus(ctx, root)
+	if err != nil && !errors.Is(err, herr.ErrNotsup) {
+		return nil, toErrno(err)
+	}
+
+	// 不知道集群的容量时用根目录的使用量作为已用空间
+	var usage *controler.QuotaUsage
+	if root != "/" || status.Capacity == 0 {
+		quota, err := hadoopControler.GetQuotaUsage(ctx, root)
+		if err != nil {
+			return nil, toErrno(err)
+		}
+		usage = &quota
+	}
+
+	stat = newStatfs(status, usage)
+	statfsCache.Set(root, *stat)
+
+	result = errno.SUCCESS
+	return stat, result
+}
@@ -0,0 +1,145 @@
+package fs
+
+import (
+	"context"
+	"hadoop-fs/fs/controler"
+	"hadoop-fs/fs/controler/webhdfstest"
+	"math"
+	"testing"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+)
+
+func TestNewStatfs(t *testing.T) {
+	const block = statfsBlockSize
+	status := controler.FsStatus{Capacity: 1000 * block, Used: 400 * block, Remaining: 600 * block}
+
+	cases := []struct {
+		name  string
+		usage *controler.QuotaUsage
+		want  fuse.Statfs
+	}{
+		{"no quota", nil,
+			fuse.Statfs{Blocks: 1000, Bfree: 600, Bavail: 600, Files: statfsMaxFiles, Ffree: statfsMaxFiles}},
+		{"default quota of root", &controler.QuotaUsage{FileAndDirectoryCount: 10, Quota: math.MaxInt64, SpaceQuota: -1},
+			fuse.Statfs{Blocks: 1000, Bfree: 600, Bavail: 600, Files: statfsMaxFiles, Ffree: statfsMaxFiles - 10}},
+		{"quota", &controler.QuotaUsage{FileAndDirectoryCount: 10, Quota: 100, SpaceConsumed: 50 * block, SpaceQuota: 200 * block},
+			fuse.Statfs{Blocks: 200, Bfree: 150, Bavail: 150, Files: 100, Ffree: 90}},
+		{"quota larger than remaining", &controler.QuotaUsage{SpaceConsumed: 50 * block, SpaceQuota: 2000 * block, Quota: -1},
+			fuse.Statfs{Blocks: 2000, Bfree: 600, Bavail: 600, Files: statfsMaxFiles, Ffree: statfsMaxFiles}},
+		{"quota exceeded", &controler.QuotaUsage{FileAndDirectoryCount: 120, Quota: 100, SpaceConsumed: 300 * block, SpaceQuota: 200 * block},
+			fuse.Statfs{Blocks: 200, Bfree: 0, Bavail: 0, Files: 100, Ffree: 0}},
+	}
+
+	for _, c := range cases {
+		c.want.Bsize, c.want.Frsize, c.want.NameLen = block, block, statfsNameLen
+		if got := newStatfs(status, c.usage); *got != c.want {
+			t.Errorf("%s: got %+v, want %+v", c.name, *got, c.want)
+		}
+	}
+
+	// 不支持GETSTATUS时有空间配额则只使用配额，没有配额时剩余空间是 statfsUnknownFree
+	got := newStatfs(controler.FsStatus{}, &controler.QuotaUsage{SpaceConsumed: 50 * block, SpaceQuota: 200 * block, Quota: -1})
+	if got.Blocks != 200 || got.Bavail != 150 {
+		t.Errorf("without GETSTATUS: got %+v", *got)
+	}
+	got = newStatfs(controler.FsStatus{}, &controler.QuotaUsage{SpaceConsumed: 50 * block, SpaceQuota: -1, Quota: -1})
+	if got.Blocks != statfsUnknownFree/block+50 || got.Bavail != statfsUnknownFree/block {
+		t.Errorf("without GETSTATUS and space quota: got %+v", *got)
+	}
+}
+
+func TestStatfsBeforeHadoop34(t *testing.T) {
+	server := webhdfstest.NewServer()
+	defer server.Close()
+	server.AddFile("/data/a", make([]byte, statfsBlockSize))
+	server.Disable("GETSTATUS")
+
+	hadoop := &controler.HadoopController{}
+	hadoop.Init(false, server.Host(), server.Port(), "alice")
+
+	saved := hadoopControler
+	hadoopControler = hadoop
+	defer func() { hadoopControler = saved }()
+	statfsCache.Init(0)
+	pathManager.Init()
+	requestManager.Init()
+
+	for i := 0; i < 2; i++ {
+		stat, result := statfs(fuse.Req{}, 1)
+		if result != errno.SUCCESS {
+			t.Fatalf("statfs: got %d", result)
+		}
+		// 已用空间是根目录的使用量(3个副本)
+		if stat.Bavail != statfsUnknownFree/statfsBlockSize || stat.Blocks != stat.Bavail+3 {
+			t.Errorf("statfs without GETSTATUS: got %+v", *stat)
+		}
+	}
+
+	count := 0
+	for _, request := range server.Requests() {
+		if request.Op == "GETSTATUS" {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("GETSTATUS requests: got %d, want 1", count)
+	}
+}
+
+func TestStatfsOnRemoteRoot(t *testing.T) {
+	memory := newRemoteRootBackend(t)
+	if err := memory.SetQuota("/user/alice/data", 100, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+
+	savedRoot := remoteRoot
+	remoteRoot = "/user/alice/data"
+	statfsCache.Init(30)
+	pathManager.Init()
+	requestManager.Init()
+	defer func() { remoteRoot = savedRoot }()
+
+	stat, result := statfs(fuse.Req{}, 1)
+	if result != errno.SUCCESS {
+		t.Fatalf("statfs: got %d", result)
+	}
+	if stat.Blocks != (1<<20)/statfsBlockSize || stat.Files != 100 || stat.Ffree != 99 {
+		t.Errorf("statfs: got %+v", *stat)
+	}
+
+	// 结果被缓存
+	if err := memory.Create(context.Background(), "/user/alice/data/new", "644"); err != nil {
+		t.Fatal(err)
+	}
+	if stat, _ = statfs(fuse.Req{}, 1); stat.Ffree != 99 {
+		t.Errorf("statfs should be cached: got %+v", *stat)
+	}
+}
+
+func TestStatfsRootOfMountTable(t *testing.T) {
+	memory := &controler.MemoryController{}
+	memory.Init("alice")
+	table := &controler.MountTable{}
+	table.Init()
+	if err := table.AddCluster("sales", memory); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Mount("/sales", "sales", "/data/sales"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 开启软连接模拟时挂载表被 SymlinkEmulation 包装
+	emulation := &controler.SymlinkEmulation{}
+	emulation.Init(table)
+
+	saved := hadoopControler
+	defer func() { hadoopControler = saved }()
+	for _, backend := range []controler.Backend{table, emulation} {
+		hadoopControler = backend
+		if root := statfsRoot("/sales/2024"); root != "/sales" {
+			t.Errorf("%T: statfsRoot got %s, want /sales", backend, root)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package fs
+
+import (
+	"context"
+	"hadoop-fs/fs/controler"
+	"hadoop-fs/fs/model"
+	"syscall"
+	"testing"
+
+	"github.com/mingforpc/fuse-go/fuse"
+	"github.com/mingforpc/fuse-go/fuse/errno"
+)
+
+func TestSymlinkEmulation(t *testing.T) {
+	memory := newRemoteRootBackend(t)
+	emulation := &controler.SymlinkEmulation{}
+	emulation.Init(memory)
+	hadoopControler = emulation
+	ctx := context.Background()
+
+	pathManager.Init()
+	pathManager.Set(2, "/user/alice/data")
+	pathManager.Set(3, "/user/alice/file")
+	requestManager.Init()
+	notExistManager.Init(5)
+
+	stat, result := symlink(fuse.Req{}, 2, "../file", "link")
+	if result != errno.SUCCESS {
+		t.Fatalf("symlink: got %d", result)
+	}
+	if stat.Stat.Mode != syscall.S_IFLNK|0777 || stat.Stat.Size != int64(len("../file")) {
+		t.Errorf("symlink stat: mode %o, size %d", stat.Stat.Mode, stat.Stat.Size)
+	}
+
+	// 保存为带有标记xattr的普通文件
+	if value, err := memory.Getxattr(ctx, "/user/alice/data/link", controler.SymlinkXattr); err != nil || value != "../file" {
+		t.Fatalf("marker xattr: got %q, %v", value, err)
+	}
+
+	if target, result := readlink(fuse.Req{}, stat.Nodeid); result != errno.SUCCESS || target != "../file" {
+		t.Errorf("readlink: got %q, %d", target, result)
+	}
+	if _, result := readlink(fuse.Req{}, 3); result != errno.EINVAL {
+		t.Errorf("readlink of a regular file: got %d, want EINVAL", result)
+	}
+
+	file, _ := emulation.GetFileStatus(ctx, "/user/alice/data/link")
+	file.AdjustNormal()
+	if ent := file.ToFuseDirent(); ent.DirType != syscall.DT_LNK {
+		t.Errorf("readdir type of symlink: got %d, want DT_LNK", ent.DirType)
+	}
+
+	// 关闭模拟时是普通文件
+	file, _ = memory.GetFileStatus(ctx, "/user/alice/data/link")
+	file.AdjustNormal()
+	if file.FileType != model.TypeFile || file.ToFuseDirent().DirType != syscall.DT_REG {
+		t.Errorf("marker file without emulation: type %o", file.FileType)
+	}
+}
+
+func TestHadoopSymlink(t *testing.T) {
+	file := model.FileModel{HadoopType: model.HadoopSymlink, HadoopPermission: "777", Symlink: "/data/a"}
+	file.AdjustNormal()
+
+	stat := syscall.Stat_t{}
+	file.WriteToStat(&stat)
+	if stat.Mode != syscall.S_IFLNK|0777 || stat.Size != int64(len("/data/a")) {
+		t.Errorf("HDFS symlink stat: mode %o, size %d", stat.Mode, stat.Size)
+	}
+}
@@ -1,13 +1,23 @@
 package main
 
 import (
+	"fmt"
 	"hadoop-fs/fs"
 	"hadoop-fs/fs/config"
+	"hadoop-fs/fs/controler"
+	"os"
 )
 
 func main() {
 
 	cg := config.ParseFromCmd()
-	fs.Service(cg)
+
+	backend, err := controler.NewBackend(cg)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+
+	fs.Service(cg, backend)
 
 }